@@ -1,19 +1,44 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"github.com/snupai/cngt-cli/internal/batch"
+	"github.com/snupai/cngt-cli/internal/bundle"
 	"github.com/snupai/cngt-cli/internal/cngt"
+	"github.com/snupai/cngt-cli/internal/cngt/progress"
 	"github.com/snupai/cngt-cli/internal/config"
 	"github.com/snupai/cngt-cli/internal/deps"
+	"github.com/snupai/cngt-cli/internal/glyph"
+	"github.com/snupai/cngt-cli/internal/glyph/lint"
+	"github.com/snupai/cngt-cli/internal/glyph/preview"
+	"github.com/snupai/cngt-cli/internal/glyph/render"
+	"github.com/snupai/cngt-cli/internal/project"
+	"github.com/snupai/cngt-cli/internal/prompt"
+	"github.com/snupai/cngt-cli/internal/python"
 	"github.com/snupai/cngt-cli/internal/updater"
 	"github.com/snupai/cngt-cli/internal/version"
 )
 
+// usesModels marks commands that resolve phone models. They fail when
+// models.yaml is invalid, other commands warn and keep the built-in models,
+// so that config, setup and models still work to fix it.
+const usesModels = "uses-models"
+
+var modelAnnotation = map[string]string{usesModels: "true"}
+
 var rootCmd = &cobra.Command{
 	Use:   "cngt-cli",
 	Short: "CLI tool for Custom Nothing Glyph Tools",
@@ -21,125 +46,1750 @@ var rootCmd = &cobra.Command{
 providing easy installation, dependency management, and usage from any directory.`,
 	Version: version.GetVersion(),
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if mode, _ := cmd.Flags().GetString("progress"); mode != "" {
+			config.Override("progress", mode)
+			if _, err := config.Load(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if cfg, err := config.Load(); err == nil {
+			if err := glyph.LoadModelFile(filepath.Join(cfg.DataDir, glyph.ModelFileName)); err != nil {
+				if cmd.Annotations[usesModels] != "" {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Fprintf(os.Stderr, "⚠️  %v, using the built-in phone models\n", err)
+			}
+		}
+
 		// Check for updates on any command run
 		checkForUpdatesAsync()
 	},
 }
 
-var migrateCmd = &cobra.Command{
-	Use:   "migrate [args...]",
-	Short: "Run GlyphMigrate.py with the given arguments",
-	Long:  "Execute the GlyphMigrate.py script from the CNGT repository",
+var migrateCmd = &cobra.Command{
+	Use:                "migrate [args...]",
+	Short:              "Run GlyphMigrate.py with the given arguments",
+	Long:               "Execute the GlyphMigrate.py script from the CNGT repository." + scriptFlagHelp,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		args = applyScriptFlags(args)
+		checkModelArgs(args)
+		if err := performSetupIfNeeded(); err != nil {
+			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+			os.Exit(1)
+		}
+		
+		if err := cngt.RunScript("GlyphMigrate.py", args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running migrate: %v\n", err)
+			os.Exit(1)
+		}
+	},
+	Annotations: modelAnnotation,
+}
+
+var modderCmd = &cobra.Command{
+	Use:                "modder [args...]",
+	Short:              "Run GlyphModder.py with the given arguments",
+	Long:               "Execute the GlyphModder.py script from the CNGT repository." + scriptFlagHelp + modderEngineHelp,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		args = applyScriptFlags(applyEngineFlag(args))
+		if cfg, err := config.Load(); err == nil && cfg.ModderEngine == "native" {
+			runNativeModder(args)
+			return
+		}
+		if err := performSetupIfNeeded(); err != nil {
+			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+			os.Exit(1)
+		}
+		
+		if err := cngt.RunScript("GlyphModder.py", args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running modder: %v\n", err)
+			os.Exit(1)
+		}
+	},
+	Annotations: modelAnnotation,
+}
+
+const modderEngineHelp = `
+
+Use --engine=native to set the title or replace the glyph data (-t, -w) or
+extract it (no options) without Python, or --engine=python to force
+GlyphModder.py (default: modder_engine setting).`
+
+// applyEngineFlag removes --engine from modder arguments and applies it for
+// the rest of the process
+func applyEngineFlag(args []string) []string {
+	rest := make([]string, 0, len(args))
+	engine := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			rest = append(rest, args[i:]...)
+			i = len(args)
+		case arg == "--engine" && i+1 < len(args):
+			engine = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--engine="):
+			engine = strings.TrimPrefix(arg, "--engine=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	if engine != "" {
+		if err := config.Override("modder_engine", engine); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := config.Load(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	return rest
+}
+
+func runNativeModder(args []string) {
+	a, err := glyph.ParseModderArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := glyph.RunModder(a, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running modder: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+var translatorCmd = &cobra.Command{
+	Use:                "translator [args...]",
+	Short:              "Run GlyphTranslator.py with the given arguments",
+	Long:               "Execute the GlyphTranslator.py script from the CNGT repository." + scriptFlagHelp + translatorLintHelp,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		args = applyScriptFlags(applyLintFlag(args))
+		if cfg, err := config.Load(); err == nil && cfg.LintLabels {
+			lintScriptArgs(args)
+		}
+		if err := performSetupIfNeeded(); err != nil {
+			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+			os.Exit(1)
+		}
+		
+		if err := cngt.RunScript("GlyphTranslator.py", args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running translator: %v\n", err)
+			os.Exit(1)
+		}
+	},
+	Annotations: modelAnnotation,
+}
+
+const translatorLintHelp = `
+
+Label files (.txt arguments) are checked with 'cngt-cli lint' first, use
+--no-lint to skip the check (default: lint_labels setting).`
+
+// applyLintFlag removes --no-lint from translator arguments and applies it
+// for the rest of the process
+func applyLintFlag(args []string) []string {
+	rest := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if arg == "--no-lint" {
+			config.Override("lint_labels", "false")
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest
+}
+
+// lintScriptArgs checks the label files among script arguments and exits
+// when one of them has issues
+func lintScriptArgs(args []string) {
+	failed := false
+	for _, arg := range args {
+		if !strings.EqualFold(filepath.Ext(arg), ".txt") {
+			continue
+		}
+		if info, err := os.Stat(arg); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		r, err := lint.File(arg, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !printLintResult(arg, r) {
+			failed = true
+		}
+	}
+	if failed {
+		fmt.Fprintln(os.Stderr, "Fix the labels or pass --no-lint to run GlyphTranslator.py anyway")
+		os.Exit(1)
+	}
+}
+
+// printLintResult prints the issues of a label file and reports whether
+// there were none
+func printLintResult(path string, r *lint.Result) bool {
+	for _, issue := range r.Issues {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", path, issue)
+	}
+	return r.OK()
+}
+
+const scriptFlagHelp = `
+
+Use --toolchain <name> to run the script from a named installation (see
+'cngt-cli toolchain list'). Before running, the checkout is checked for
+modified or missing files, use --no-verify to skip the check. All other
+arguments are passed to the script.`
+
+// applyScriptFlags removes --toolchain and --no-verify from script arguments
+// and applies them for the rest of the process
+func applyScriptFlags(args []string) []string {
+	rest := make([]string, 0, len(args))
+	name := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			rest = append(rest, args[i:]...)
+			i = len(args)
+		case arg == "--toolchain" && i+1 < len(args):
+			name = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--toolchain="):
+			name = strings.TrimPrefix(arg, "--toolchain=")
+		case arg == "--no-verify":
+			config.Override("verify_checkout", "false")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	if name != "" {
+		useToolchain(name)
+	}
+	return rest
+}
+
+// useToolchain makes a named toolchain active for the rest of the process
+func useToolchain(name string) {
+	if ok, err := cngt.ToolchainExists(name); err != nil || !ok {
+		fmt.Fprintf(os.Stderr, "Error: toolchain %s does not exist (see 'cngt-cli toolchain list')\n", name)
+		os.Exit(1)
+	}
+	if err := config.Override("toolchain", name); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// batchScripts maps the commands batch can run to their scripts
+var batchScripts = map[string]string{
+	"translator": "GlyphTranslator.py",
+	"migrate":    "GlyphMigrate.py",
+	"modder":     "GlyphModder.py",
+}
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <translator|migrate|modder> <pattern>... [-- script args...]",
+	Short: "Run translator, migrate or modder on many files in parallel",
+	Long: `Run a script once for every file matching the patterns, with up to --jobs
+runs at a time. Patterns are quoted globs, ** matches any number of
+directories. Arguments after -- are passed to every run before the file.
+
+With --manifest, every line of the file holds the arguments of one run
+instead, quoted like in a shell. Relative paths are resolved against the
+directory of the manifest.
+
+The output of every run goes to its own log in --log-dir. A summary table
+follows the last run, and the exit code is 1 when any run failed.`,
+	Example: `  cngt-cli batch translator --jobs 4 'songs/**/*.txt'
+  cngt-cli batch modder 'ringtones/*.ogg' -- -t "Demo"
+  cngt-cli batch migrate --manifest jobs.txt`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var common []string
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			common = args[dash:]
+			args = args[:dash]
+		}
+		if len(args) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: expected translator, migrate or modder before --\n")
+			os.Exit(1)
+		}
+		name, patterns := args[0], args[1:]
+		scriptName, ok := batchScripts[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: cannot batch %q, expected translator, migrate or modder\n", name)
+			os.Exit(1)
+		}
+
+		if toolchain, _ := cmd.Flags().GetString("toolchain"); toolchain != "" {
+			useToolchain(toolchain)
+		}
+		if noVerify, _ := cmd.Flags().GetBool("no-verify"); noVerify {
+			config.Override("verify_checkout", "false")
+		}
+		if noLint, _ := cmd.Flags().GetBool("no-lint"); noLint {
+			config.Override("lint_labels", "false")
+		}
+		if name == "migrate" {
+			checkModelArgs(common)
+		}
+
+		argLists, err := batchArgLists(cmd, patterns, common)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		logDir, _ := cmd.Flags().GetString("log-dir")
+		if logDir == "" {
+			logDir = filepath.Join("cngt-batch-logs", time.Now().Format("20060102-150405"))
+		}
+		jobs := batch.NewJobs(argLists, logDir)
+
+		run, err := batchRunner(name, scriptName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		workers, _ := cmd.Flags().GetInt("jobs")
+		fmt.Printf("🚀 Running %s on %d files with %d jobs, logs in %s\n", name, len(jobs), max(workers, 1), logDir)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		finished := 0
+		results := batch.Run(ctx, jobs, workers, run, func(r *batch.Result) {
+			finished++
+			switch r.Status {
+			case batch.StatusOK:
+				fmt.Printf("[%d/%d] ✅ %s (%.1fs)\n", finished, len(jobs), r.Job.Input, r.Duration.Seconds())
+			case batch.StatusFailed:
+				fmt.Printf("[%d/%d] ❌ %s: %v\n", finished, len(jobs), r.Job.Input, r.Err)
+			}
+		})
+
+		fmt.Println()
+		batch.PrintSummary(os.Stdout, results)
+		if batch.Failed(results) > 0 {
+			os.Exit(1)
+		}
+	},
+	Annotations: modelAnnotation,
+}
+
+var buildCmd = &cobra.Command{
+	Use:   "build [composition...]",
+	Short: "Build the compositions declared in cngt.yaml",
+	Long: `Build the compositions of the cngt.yaml project file in the current
+directory or its parents, or only the named ones.
+
+For every composition, GlyphTranslator.py turns the labels into glyph data,
+which is written into the audio together with the title and author. Each
+further target model is made with GlyphMigrate.py. Outputs go to the dist
+directory as <name>-<model>.ogg.
+
+Compositions whose audio, labels, declaration and CNGT commit did not change
+since their last successful build are skipped, unless --force is given.
+Logs are kept in .cngt-build/logs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		p, err := project.Find(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if toolchain, _ := cmd.Flags().GetString("toolchain"); toolchain != "" {
+			useToolchain(toolchain)
+		}
+		if noVerify, _ := cmd.Flags().GetBool("no-verify"); noVerify {
+			config.Override("verify_checkout", "false")
+		}
+		if err := performSetupIfNeeded(); err != nil {
+			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Checking the checkout once for every script up front keeps prompts
+		// out of the workers
+		scripts := map[string]*cngt.Script{}
+		for _, name := range project.Scripts {
+			script, err := cngt.PrepareScript(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			scripts[name] = script
+		}
+		translator := scripts[project.TranslatorScript]
+		run := func(ctx context.Context, name string, args []string, log io.Writer) error {
+			script, ok := scripts[name]
+			if !ok {
+				return fmt.Errorf("%s was not prepared", name)
+			}
+
+			c := script.Command(ctx, args)
+			c.Stdout = log
+			c.Stderr = log
+			return c.Run()
+		}
+
+		opts := project.BuildOptions{Only: args, Run: run, Fingerprint: version.Version + " " + translator.Commit}
+		opts.Force, _ = cmd.Flags().GetBool("force")
+		opts.Jobs, _ = cmd.Flags().GetInt("jobs")
+		opts.Progress = func(r *project.Result) {
+			name := r.Composition.Name
+			switch r.Status {
+			case project.StatusBuilt:
+				fmt.Printf("✅ %s → %s (%.1fs)\n", name, strings.Join(r.Outputs, ", "), r.Duration.Seconds())
+			case project.StatusUpToDate:
+				fmt.Printf("⏭️  %s is up to date\n", name)
+			case project.StatusFailed:
+				fmt.Printf("❌ %s: %v\n   See %s\n", name, r.Err, r.Log)
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		results, err := p.Build(ctx, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		counts := map[string]int{}
+		for _, r := range results {
+			counts[r.Status]++
+		}
+		fmt.Printf("\n%d built, %d up to date, %d failed", counts[project.StatusBuilt], counts[project.StatusUpToDate], counts[project.StatusFailed])
+		if counts[project.StatusSkipped] > 0 {
+			fmt.Printf(", %d skipped", counts[project.StatusSkipped])
+		}
+		fmt.Println()
+		if counts[project.StatusFailed]+counts[project.StatusSkipped] > 0 {
+			os.Exit(1)
+		}
+	},
+	Annotations: modelAnnotation,
+}
+
+// batchArgLists returns the arguments of every batch run, from the
+// manifest or the files matching patterns. Paths are made absolute since
+// scripts run in the checkout.
+func batchArgLists(cmd *cobra.Command, patterns, common []string) ([][]string, error) {
+	manifest, _ := cmd.Flags().GetString("manifest")
+	if manifest != "" {
+		if len(patterns) > 0 {
+			return nil, fmt.Errorf("pass either patterns or --manifest")
+		}
+		lines, err := batch.ReadManifest(manifest)
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("%s lists no runs", manifest)
+		}
+		base := filepath.Dir(manifest)
+		argLists := make([][]string, len(lines))
+		for i, line := range lines {
+			args := append([]string{}, common...)
+			for _, arg := range line {
+				path := arg
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(base, arg)
+				}
+				if _, err := os.Stat(path); err == nil && !strings.HasPrefix(arg, "-") {
+					arg, _ = filepath.Abs(path)
+				}
+				args = append(args, arg)
+			}
+			argLists[i] = args
+		}
+		return argLists, nil
+	}
+
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("expected file patterns or --manifest")
+	}
+	files, err := batch.Expand(patterns)
+	if err != nil {
+		return nil, err
+	}
+	argLists := make([][]string, len(files))
+	for i, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		argLists[i] = append(append([]string{}, common...), abs)
+	}
+	return argLists, nil
+}
+
+// batchRunner returns the function that runs a single batch job, after
+// the checks every run of the script would do
+func batchRunner(name, scriptName string) (batch.RunFunc, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if name == "modder" && cfg.ModderEngine == "native" {
+		return func(ctx context.Context, job *batch.Job, log io.Writer) error {
+			a, err := glyph.ParseModderArgs(job.Args)
+			if err != nil {
+				return err
+			}
+			return glyph.RunModder(a, log)
+		}, nil
+	}
+
+	if err := performSetupIfNeeded(); err != nil {
+		return nil, fmt.Errorf("setup failed: %w", err)
+	}
+	script, err := cngt.PrepareScript(scriptName)
+	if err != nil {
+		return nil, err
+	}
+	lintLabels := name == "translator" && cfg.LintLabels
+
+	return func(ctx context.Context, job *batch.Job, log io.Writer) error {
+		if lintLabels {
+			if err := lintJob(job, log); err != nil {
+				return err
+			}
+		}
+		c := script.Command(ctx, job.Args)
+		c.Stdout = log
+		c.Stderr = log
+		return c.Run()
+	}, nil
+}
+
+// lintJob checks the label files of a translator job, writing issues to log
+func lintJob(job *batch.Job, log io.Writer) error {
+	for _, arg := range job.Args {
+		if !strings.EqualFold(filepath.Ext(arg), ".txt") {
+			continue
+		}
+		if info, err := os.Stat(arg); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		r, err := lint.File(arg, nil)
+		if err != nil {
+			return err
+		}
+		for _, issue := range r.Issues {
+			fmt.Fprintf(log, "%s:%s\n", arg, issue)
+		}
+		if !r.OK() {
+			return fmt.Errorf("%s has %d label issues", filepath.Base(arg), len(r.Issues))
+		}
+	}
+	return nil
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update [--rollback [n]]",
+	Short: "Update the CNGT repository to the latest version",
+	Long: `Pull the latest changes from the configured remote and branch (see 'cngt-cli config list').
+
+Use --to to pin the checkout to a tag or commit instead. Running update
+without --to afterwards returns to the branch head.
+
+Every change of the installed commit is recorded. Use --history to list the
+entries and --rollback [n] to restore the commit that was installed before
+entry n (default 1, the latest change).`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if rollback, _ := cmd.Flags().GetBool("rollback"); rollback {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		return cobra.NoArgs(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if showHistory, _ := cmd.Flags().GetBool("history"); showHistory {
+			printUpdateHistory()
+			return
+		}
+
+		if err := performSetupIfNeeded(); err != nil {
+			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+			os.Exit(1)
+		}
+
+		before := loadRequirements()
+		if rollback, _ := cmd.Flags().GetBool("rollback"); rollback {
+			n := 1
+			if len(args) > 0 {
+				var err error
+				if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+					fmt.Fprintf(os.Stderr, "Error: invalid history entry %q, expected a number from 1 (see 'cngt-cli update --history')\n", args[0])
+					os.Exit(1)
+				}
+			}
+			entry, err := cngt.Rollback(n, dirtyAction(cmd))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error rolling back CNGT: %v\n", err)
+				os.Exit(1)
+			}
+			if entry == nil {
+				fmt.Println("CNGT repository is already at that commit")
+				return
+			}
+			fmt.Printf("CNGT repository rolled back to %s\n", entry.To[:7])
+			checkRequirementsChange(before)
+			return
+		}
+
+		to, _ := cmd.Flags().GetString("to")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		changelog, err := cngt.Update(cngt.UpdateOptions{To: to, DryRun: dryRun, OnDirty: dirtyAction(cmd)})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating CNGT: %v\n", err)
+			os.Exit(1)
+		}
+
+		switch {
+		case dryRun:
+			fmt.Println("Dry run, the CNGT checkout was not changed")
+		case changelog.Empty():
+			// PrintChangelog already reported that nothing changed
+		case to != "":
+			fmt.Printf("CNGT repository pinned to %s (%s)\n", to, changelog.To[:7])
+			fmt.Println("Run 'cngt-cli lock' in your project to record this commit in cngt.lock")
+		default:
+			fmt.Printf("CNGT repository updated successfully (%s -> %s, %d new commits)\n",
+				changelog.From[:7], changelog.To[:7], len(changelog.Added))
+		}
+		if !dryRun && !changelog.Empty() {
+			checkRequirementsChange(before)
+		}
+	},
+}
+
+// loadRequirements returns the Python requirements of the checkout, nil
+// when they cannot be read
+func loadRequirements() *deps.Requirements {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil
+	}
+	reqs, err := deps.LoadRequirements(cfg.CNGTPath)
+	if err != nil {
+		return nil
+	}
+	return reqs
+}
+
+// checkRequirementsChange compares the Python requirements of the checkout
+// with those before an update and offers to sync the environment when they
+// changed
+func checkRequirementsChange(before *deps.Requirements) {
+	after := loadRequirements()
+	if after == nil {
+		fmt.Println("⚠️  The Python requirements of the new checkout cannot be read, run 'cngt-cli status' to check them")
+		return
+	}
+	if before != nil {
+		diff := deps.DiffRequirements(before, after)
+		if len(diff) == 0 {
+			return
+		}
+		fmt.Printf("\n📦 Python requirements changed (%s):\n", after.Source)
+		for _, line := range diff {
+			fmt.Printf("   %s\n", line)
+		}
+	}
+	if deps.AreInstalled() {
+		fmt.Println("✅ The Python environment already satisfies them")
+		return
+	}
+
+	if !prompt.Confirm("Sync the Python environment now? (Y/n): ", true) {
+		fmt.Println("Run 'cngt-cli setup' to sync it later")
+		return
+	}
+	if err := deps.Sync(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error syncing Python dependencies: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("✅ Python dependencies synced")
+}
+
+// dirtyAction maps the --stash and --discard flags to a cngt.Dirty* action
+func dirtyAction(cmd *cobra.Command) string {
+	if stash, _ := cmd.Flags().GetBool("stash"); stash {
+		return cngt.DirtyStash
+	}
+	if discard, _ := cmd.Flags().GetBool("discard"); discard {
+		return cngt.DirtyDiscard
+	}
+	return cngt.DirtyAsk
+}
+
+func printUpdateHistory() {
+	entries, err := cngt.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading update history: %v\n", err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No updates recorded yet")
+		return
+	}
+
+	for i, entry := range entries {
+		ref := ""
+		if entry.Ref != "" {
+			ref = " (" + entry.Ref + ")"
+		}
+		fmt.Printf("%3d  %s  %-8s  %s -> %s%s  %s\n",
+			i+1, entry.Time.Local().Format("2006-01-02 15:04"), entry.Action,
+			entry.From[:7], entry.To[:7], ref, entry.Summary)
+	}
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Record the installed CNGT commit in cngt.lock",
+	Long: `Write the commit of the CNGT checkout to cngt.lock in the current directory.
+
+The migrate, modder and translator commands look for cngt.lock in the current
+directory and its parents, and refuse to run (or offer to switch) when the
+installed checkout does not match it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadInstalledConfig()
+
+		lock, err := cngt.LockFromCheckout(cfg.CNGTPath, cfg.RepoRemote)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		path, _ := cmd.Flags().GetString("file")
+		if err := cngt.WriteLock(path, lock); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Locked CNGT to %s in %s\n", lock.Commit[:7], path)
+	},
+}
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Update the cngt-cli tool itself",
+	Long:  "Check for and install updates to the cngt-cli tool",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := updater.SelfUpdate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating CLI: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show status of CNGT installation and dependencies",
+	Long:  "Display information about the CNGT repository and Python dependencies",
+	Run: func(cmd *cobra.Command, args []string) {
+		status := cngt.GetStatus()
+		fmt.Printf("CNGT CLI Version: %s\n", version.GetFullVersion())
+		if cfg, err := config.Load(); err == nil && cfg.Toolchain != "" {
+			fmt.Printf("Toolchain: %s\n", cfg.Toolchain)
+		}
+		fmt.Printf("CNGT Repository: %s\n", status.RepoStatus)
+		fmt.Printf("Python: %s\n", status.PythonStatus)
+		fmt.Printf("Dependencies: %s\n", status.DepsStatus)
+		deps.PrintPackages(os.Stdout, "  ", status.Packages)
+
+		if cwd, err := os.Getwd(); err == nil {
+			if lockPath, lock, err := cngt.FindLock(cwd); err != nil {
+				fmt.Printf("Lockfile: %v\n", err)
+			} else if lock != nil && lock.Matches(status.Commit) {
+				fmt.Printf("Lockfile: %s (matches)\n", lockPath)
+			} else if lock != nil {
+				fmt.Printf("Lockfile: %s (expects %s, run 'cngt-cli update --to %s')\n", lockPath, lock.Commit, lock.Commit)
+			}
+		}
+		
+		// If nothing is installed, offer to set up
+		if status.RepoStatus == "Not installed" {
+			fmt.Println()
+			fmt.Println("💡 Tip: Run 'cngt-cli setup' to install CNGT and dependencies interactively")
+		} else if status.Packages == nil {
+			fmt.Println()
+			fmt.Println("💡 Tip: Run 'cngt-cli setup' to create the Python environment")
+		} else if !deps.PackagesOK(status.Packages) {
+			fmt.Println()
+			fmt.Println("💡 Tip: Run 'cngt-cli setup' to install or upgrade the packages marked ✗")
+		}
+	},
+}
+
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Interactive setup of CNGT repository and dependencies",
+	Long: `Guides you through the installation of the CNGT repository and Python dependencies.
+
+Use --from-bundle to install from a file created by 'cngt-cli bundle create'
+without any network access.
+
+Use --mirror to clone from another git URL or a local repository, e.g. an
+internal mirror. The clone keeps that URL as its remote, so later updates
+fetch from the mirror as well. Set repo_mirror to make it the default.
+
+Use --shallow to clone only the latest commit, e.g. on CI runners. Older
+history is fetched automatically when 'update --to' or '--rollback' needs it.
+Set clone_depth for a different number of commits.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if mirror, _ := cmd.Flags().GetString("mirror"); mirror != "" {
+			config.Override("repo_mirror", mirror)
+		}
+		if shallow, _ := cmd.Flags().GetBool("shallow"); shallow {
+			config.Override("clone_depth", "1")
+		}
+		if bundlePath, _ := cmd.Flags().GetString("from-bundle"); bundlePath != "" {
+			if err := setupFromBundle(bundlePath); err != nil {
+				fmt.Fprintf(os.Stderr, "Setup failed: %v\n", err)
+				os.Exit(1)
+			}
+		} else if err := interactiveSetup(); err != nil {
+			fmt.Fprintf(os.Stderr, "Setup failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("✅ Setup completed successfully!")
+		fmt.Println("You can now use cngt-cli commands like 'cngt-cli migrate --help'")
+	},
+}
+
+var repoCmd = &cobra.Command{
+	Use:   "repo",
+	Short: "Manage the remotes of the CNGT repository checkout",
+}
+
+var repoSetRemoteCmd = &cobra.Command{
+	Use:   "set-remote <url>",
+	Short: "Point the checkout at a different repository, e.g. a fork",
+	Long: `Re-point a remote of the existing CNGT checkout without deleting it.
+
+When the remote is the one update pulls from (repo_remote), the URL is also
+stored as repo_url so future installs clone from it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadInstalledConfig()
+		name, _ := cmd.Flags().GetString("name")
+		if name == "" {
+			name = cfg.RepoRemote
+		}
+
+		if err := cngt.SetRemote(cfg.CNGTPath, name, args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if name == cfg.RepoRemote {
+			if err := config.Set("repo_url", args[0]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Remote %s now points to %s\n", name, args[0])
+	},
+}
+
+var repoAddRemoteCmd = &cobra.Command{
+	Use:   "add-remote <name> <url>",
+	Short: "Add an extra remote to the checkout",
+	Long:  "Add an extra remote to the checkout. Use 'cngt-cli config set repo_remote <name>' to update from it.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadInstalledConfig()
+		if err := cngt.AddRemote(cfg.CNGTPath, args[0], args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var repoRemoveRemoteCmd = &cobra.Command{
+	Use:   "remove-remote <name>",
+	Short: "Remove a remote from the checkout",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadInstalledConfig()
+		if args[0] == cfg.RepoRemote {
+			fmt.Fprintf(os.Stderr, "Error: %s is the configured update remote (repo_remote)\n", args[0])
+			os.Exit(1)
+		}
+		if err := cngt.RemoveRemote(cfg.CNGTPath, args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var repoRemotesCmd = &cobra.Command{
+	Use:   "remotes",
+	Short: "List the remotes of the checkout",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadInstalledConfig()
+		remotes, err := cngt.ListRemotes(cfg.CNGTPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, remote := range remotes {
+			marker := " "
+			if remote.Name == cfg.RepoRemote {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\t%s\n", marker, remote.Name, strings.Join(remote.URLs, ", "))
+		}
+	},
+}
+
+var repoRestorePatchCmd = &cobra.Command{
+	Use:   "restore-patch [patch]",
+	Short: "Re-apply local modifications saved by 'update --stash'",
+	Long: `Re-apply a patch saved when updating a modified checkout.
+
+Without an argument the newest patch is applied. Use 'cngt-cli repo patches'
+to list the saved patches.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		loadInstalledConfig()
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		path, err := cngt.RestorePatch(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Applied %s\n", path)
+	},
+}
+
+var repoVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the CNGT checkout for modified, missing and untracked files",
+	Long: `Compare every file of the CNGT checkout against the commit it is on.
+
+Use --repair to restore modified and missing files. Their current content is
+saved as a patch first (see 'cngt-cli repo patches'). Untracked files are
+only deleted with --remove-untracked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		loadInstalledConfig()
+		repair, _ := cmd.Flags().GetBool("repair")
+		removeUntracked, _ := cmd.Flags().GetBool("remove-untracked")
+
+		result, err := cngt.Verify(true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if result.OK() && len(result.Untracked) == 0 {
+			fmt.Printf("✅ Checkout matches commit %s\n", result.Commit[:7])
+			return
+		}
+		fmt.Printf("Checkout differs from commit %s:\n", result.Commit[:7])
+		cngt.PrintVerifyResult(os.Stdout, result)
+
+		if !repair {
+			if !result.OK() {
+				fmt.Println("💡 Run 'cngt-cli repo verify --repair' to restore the files")
+				os.Exit(1)
+			}
+			return
+		}
+
+		patch, err := cngt.Repair(result, removeUntracked)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if patch != "" {
+			fmt.Printf("💾 Saved the previous content to %s\n", patch)
+		}
+		fmt.Println("✅ Checkout repaired")
+	},
+}
+
+var repoPatchesCmd = &cobra.Command{
+	Use:   "patches",
+	Short: "List saved patches of local modifications",
+	Run: func(cmd *cobra.Command, args []string) {
+		patches, err := cngt.ListPatches()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(patches) == 0 {
+			fmt.Println("No saved patches")
+			return
+		}
+		for _, path := range patches {
+			fmt.Println(filepath.Base(path))
+		}
+	},
+}
+
+// loadInstalledConfig loads the config and exits if CNGT is not installed
+func loadInstalledConfig() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if !cngt.IsInstalled(cfg.CNGTPath) {
+		fmt.Fprintln(os.Stderr, "CNGT repository is not installed. Run 'cngt-cli setup' first.")
+		os.Exit(1)
+	}
+	return cfg
+}
+
+var toolchainCmd = &cobra.Command{
+	Use:   "toolchain",
+	Short: "Manage side-by-side CNGT installations",
+	Long: `Manage named CNGT installations, each with its own checkout and Python
+environment. The installation at cngt_path is called "default".`,
+}
+
+var toolchainAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Install a new named toolchain",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+
+		opts := cngt.InstallOptionsFromConfig(cfg)
+		if url, _ := cmd.Flags().GetString("url"); url != "" {
+			opts.URL = url
+		}
+		if branch, _ := cmd.Flags().GetString("branch"); branch != "" {
+			opts.Branch = branch
+		}
+		ref, _ := cmd.Flags().GetString("ref")
+
+		fmt.Printf("📦 Installing toolchain %s from %s...\n", args[0], opts.URL)
+		tc, err := cngt.AddToolchain(args[0], opts, ref)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error adding toolchain: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Install the Python environment of the new toolchain
+		config.Override("toolchain", tc.Name)
+		if !deps.AreInstalled() {
+			fmt.Println("🐍 Installing Python dependencies...")
+			if err := deps.CheckInteractive(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error installing dependencies: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Printf("✅ Toolchain %s installed in %s\n", tc.Name, tc.Path)
+		fmt.Printf("Use it with 'cngt-cli toolchain use %s' or '--toolchain %s'\n", tc.Name, tc.Name)
+	},
+}
+
+var toolchainListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed toolchains",
+	Run: func(cmd *cobra.Command, args []string) {
+		toolchains, err := cngt.ListToolchains()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, tc := range toolchains {
+			marker := " "
+			if tc.Active {
+				marker = "*"
+			}
+			commit := "not installed"
+			if tc.Commit != "" {
+				commit = tc.Commit[:7]
+			}
+			ref := ""
+			if tc.Ref != "" {
+				ref = " (" + tc.Ref + ")"
+			}
+			if tc.Broken != "" {
+				commit, ref = "broken", ": "+tc.Broken
+			}
+			fmt.Printf("%s %-12s %s%s\t%s\n", marker, tc.Name, commit, ref, tc.Path)
+		}
+	},
+}
+
+var toolchainUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Make a toolchain the active one",
+	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := performSetupIfNeeded(); err != nil {
-			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+		name := args[0]
+		if ok, err := cngt.ToolchainExists(name); err != nil || !ok {
+			fmt.Fprintf(os.Stderr, "Error: toolchain %s does not exist (see 'cngt-cli toolchain list')\n", name)
 			os.Exit(1)
 		}
-		
-		if err := cngt.RunScript("GlyphMigrate.py", args); err != nil {
-			fmt.Fprintf(os.Stderr, "Error running migrate: %v\n", err)
+
+		value := name
+		if name == config.DefaultToolchain {
+			value = ""
+		}
+		if err := config.Set("toolchain", value); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Printf("Now using toolchain %s\n", name)
 	},
 }
 
-var modderCmd = &cobra.Command{
-	Use:   "modder [args...]",
-	Short: "Run GlyphModder.py with the given arguments",
-	Long:  "Execute the GlyphModder.py script from the CNGT repository",
+var toolchainRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Delete a named toolchain and its checkout",
+	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := performSetupIfNeeded(); err != nil {
-			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+		if err := cngt.RemoveToolchain(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		
-		if err := cngt.RunScript("GlyphModder.py", args); err != nil {
-			fmt.Fprintf(os.Stderr, "Error running modder: %v\n", err)
+		fmt.Printf("Removed toolchain %s\n", args[0])
+	},
+}
+
+var pythonCmd = &cobra.Command{
+	Use:   "python",
+	Short: "Choose the Python interpreter the environment is created from",
+	Long: `List the Python interpreters on this machine and choose the one the Python
+environment of the CNGT scripts is created from.
+
+Interpreters are looked up on PATH, in the pyenv and asdf installation
+directories, among those installed with 'install' and, on Windows, with the
+py launcher. Unless one was chosen with 'use', the newest CPython release
+built for this machine's architecture is picked. Versions older than
+python_min_version are never used.`,
+}
+
+var pythonListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the Python interpreters found on this machine",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 			os.Exit(1)
 		}
+
+		min := python.MinVersion(cfg)
+		list := python.Discover(python.ManagedDir(cfg))
+		var chosen *python.Interpreter
+		if cfg.Python != "" {
+			chosen, err = python.Use(cfg.Python, min)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Configured Python %s is not usable: %v\n", cfg.Python, err)
+			} else {
+				list = append([]*python.Interpreter{chosen}, list...)
+			}
+		} else {
+			chosen = python.Best(list, min)
+		}
+		if len(list) == 0 {
+			fmt.Println("No Python interpreters found")
+			return
+		}
+
+		seen := map[string]bool{}
+		fmt.Printf("  %-10s %-12s %-12s %s\n", "VERSION", "ARCH", "SOURCE", "PATH")
+		for _, i := range list {
+			if seen[i.Command()] {
+				continue
+			}
+			seen[i.Command()] = true
+
+			marker := " "
+			if chosen != nil && i.Command() == chosen.Command() {
+				marker = "*"
+			}
+			version, arch := "-", "-"
+			if i.Err == nil {
+				version = i.Version.String()
+				arch = fmt.Sprintf("%s/%d", i.Machine, i.Bits)
+			}
+			var notes []string
+			if problem := i.Problem(min); problem != "" {
+				notes = append(notes, problem)
+			}
+			if i.Err == nil && !i.Native() {
+				notes = append(notes, "not native")
+			}
+			if i.Err == nil && i.Implementation != "cpython" {
+				notes = append(notes, i.Implementation)
+			}
+			note := ""
+			if len(notes) > 0 {
+				note = " (" + strings.Join(notes, ", ") + ")"
+			}
+			fmt.Printf("%s %-10s %-12s %-12s %s%s\n", marker, version, arch, i.Source, i.Path, note)
+		}
 	},
 }
 
-var translatorCmd = &cobra.Command{
-	Use:   "translator [args...]",
-	Short: "Run GlyphTranslator.py with the given arguments",
-	Long:  "Execute the GlyphTranslator.py script from the CNGT repository",
+var pythonUseCmd = &cobra.Command{
+	Use:   "use <path|auto>",
+	Short: "Create the Python environment from the given interpreter",
+	Long: `Choose the interpreter the Python environment is created from, given as a
+path or a command on PATH such as python3.12. 'auto' returns to picking the
+best interpreter found.
+
+An existing environment is recreated with the new interpreter on request.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := performSetupIfNeeded(); err != nil {
-			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 			os.Exit(1)
 		}
-		
-		if err := cngt.RunScript("GlyphTranslator.py", args); err != nil {
-			fmt.Fprintf(os.Stderr, "Error running translator: %v\n", err)
+
+		value := ""
+		if args[0] != "auto" {
+			i, err := python.Use(args[0], python.MinVersion(cfg))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			value = i.Path
+		}
+		if err := config.Set("python", value); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg, err = config.Load(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		base, err := python.Find(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Using Python %s (%s)\n", base.Version, base.Command())
+
+		if !deps.HasVenv(cfg.VenvPath) {
+			return
+		}
+		if !prompt.Confirm("Recreate the existing Python environment with it now? (Y/n): ", true) {
+			fmt.Println("The environment keeps its current interpreter until it is recreated")
+			return
+		}
+		if err := deps.RemoveVenv(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := deps.Install(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error installing Python dependencies: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Println("✅ Python environment recreated")
 	},
 }
 
-var updateCmd = &cobra.Command{
-	Use:   "update",
-	Short: "Update the CNGT repository to the latest version",
-	Long:  "Pull the latest changes from the custom-nothing-glyph-tools repository",
+var pythonInstallCmd = &cobra.Command{
+	Use:   "install [version]",
+	Short: "Install a standalone Python into the data directory",
+	Long: `Install a standalone CPython build into the python directory of the data
+directory, for machines without a suitable Python. The version may be given
+as 3.12 or 3.12.7 and defaults to ` + python.DefaultInstallVersion + `, or python_min_version if that
+is newer.
+
+uv installs it when available. Otherwise the build is downloaded from the
+python-build-standalone releases on GitHub, or from python_mirror, a URL or
+local directory with the archives and their SHA256SUMS, and checked against
+the checksums before it is unpacked.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := performSetupIfNeeded(); err != nil {
-			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 			os.Exit(1)
 		}
-		
-		if err := cngt.Update(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error updating CNGT: %v\n", err)
+
+		version := python.InstallVersion(cfg)
+		if len(args) > 0 {
+			version = args[0]
+		}
+		i, err := python.Install(cfg, version, progress.FromConfig())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Println("CNGT repository updated successfully")
+		fmt.Printf("✅ Python %s installed at %s\n", i.Version, i.Command())
+		if problem := i.Problem(python.MinVersion(cfg)); problem != "" {
+			fmt.Printf("⚠️  It is not used for the Python environment: %s\n", problem)
+		} else if cfg.Python != "" {
+			fmt.Printf("💡 Run 'cngt-cli python use %s' to create the environment from it\n", i.Command())
+		}
 	},
 }
 
-var upgradeCmd = &cobra.Command{
-	Use:   "upgrade",
-	Short: "Update the cngt-cli tool itself",
-	Long:  "Check for and install updates to the cngt-cli tool",
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change cngt-cli settings",
+	Long: `Read and write settings stored in the config file in the data directory.
+
+Settings are resolved in this order, later sources overriding earlier ones:
+  1. built-in defaults
+  2. the config file (see 'cngt-cli config path')
+  3. CNGT_* environment variables (see 'cngt-cli config list')`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the resolved value of a setting",
+	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := updater.SelfUpdate(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error updating CLI: %v\n", err)
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+
+		value, err := cfg.Get(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Println(value)
 	},
 }
 
-var statusCmd = &cobra.Command{
-	Use:   "status",
-	Short: "Show status of CNGT installation and dependencies",
-	Long:  "Display information about the CNGT repository and Python dependencies",
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Store a setting in the config file (an empty value resets it)",
+	Args:  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		status := cngt.GetStatus()
-		fmt.Printf("CNGT CLI Version: %s\n", version.GetFullVersion())
-		fmt.Printf("CNGT Repository: %s\n", status.RepoStatus)
-		fmt.Printf("Python: %s\n", status.PythonStatus)
-		fmt.Printf("Dependencies: %s\n", status.DepsStatus)
-		
-		// If nothing is installed, offer to set up
-		if status.RepoStatus == "Not installed" {
-			fmt.Println()
-			fmt.Println("💡 Tip: Run 'cngt-cli setup' to install CNGT and dependencies interactively")
+		if err := config.Set(args[0], args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
 	},
 }
 
-var setupCmd = &cobra.Command{
-	Use:   "setup",
-	Short: "Interactive setup of CNGT repository and dependencies",
-	Long:  "Guides you through the installation of the CNGT repository and Python dependencies",
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings with their values and sources",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := interactiveSetup(); err != nil {
-			fmt.Fprintf(os.Stderr, "Setup failed: %v\n", err)
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Println("✅ Setup completed successfully!")
-		fmt.Println("You can now use cngt-cli commands like 'cngt-cli migrate --help'")
+
+		for _, s := range cfg.Settings() {
+			fmt.Printf("%s = %s (%s)\n", s.Key, s.Value, s.Source)
+			fmt.Printf("    %s [env: %s]\n", s.Usage, s.Env)
+		}
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file in $EDITOR",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load()
+		if err != nil {
+			// Still allow fixing a broken file
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			cfg = nil
+		}
+
+		path, err := configFilePath(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := os.WriteFile(path, []byte("# cngt-cli configuration, see 'cngt-cli config list'\n"), 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating config file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if err := openEditor(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running editor: %v\n", err)
+			os.Exit(1)
+		}
+
+		if _, err := config.Load(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: config file is invalid: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the location of the config file",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := config.Load()
+		path, err := configFilePath(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	},
+}
+
+// configFilePath works even when the config file itself fails to parse
+func configFilePath(cfg *config.Config) (string, error) {
+	if cfg != nil {
+		return cfg.FilePath(), nil
+	}
+	return config.DefaultFilePath()
+}
+
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		} else {
+			editor = "vi"
+		}
+	}
+
+	cmd := exec.Command(editor, path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Create offline installation bundles",
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create <out.tar.zst>",
+	Short: "Pack the CNGT checkout and Python wheels into a bundle",
+	Long: `Pack the CNGT checkout, a wheelhouse of its Python dependencies and metadata
+into a single archive. Install it on a machine without network access with
+'cngt-cli setup --from-bundle <file>'.
+
+The archive format follows the extension: .tar.zst, .tar.gz or .tar. Wheels
+are downloaded with pip unless --wheels points to an existing directory.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadInstalledConfig()
+
+		wheelDir, _ := cmd.Flags().GetString("wheels")
+		// tmp holds downloaded wheels. It is removed explicitly, since the
+		// error paths exit without running deferred calls.
+		tmp := ""
+		if wheelDir == "" {
+			var err error
+			tmp, err = os.MkdirTemp("", "cngt-wheels-*")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Println("⬇️  Downloading Python wheels...")
+			if err := deps.DownloadWheels(tmp); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.RemoveAll(tmp)
+				os.Exit(1)
+			}
+			wheelDir = tmp
+		}
+
+		meta, err := bundle.Create(args[0], bundle.CreateOptions{
+			CheckoutPath: cfg.CNGTPath,
+			WheelDir:     wheelDir,
+			RemoteName:   cfg.RepoRemote,
+		})
+		if tmp != "" {
+			os.RemoveAll(tmp)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating bundle: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Created %s (commit %s, %d wheels)\n", args[0], meta.Commit[:7], len(meta.Wheels))
+	},
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <file.ogg>",
+	Short: "Show the glyph data of a composition",
+	Long: `Read a glyph composition without Python and print its phone model, duration,
+frame count and how much each zone is used.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		glyph.PrintSummary(os.Stdout, readComposition(cmd, args[0]))
+	},
+	Annotations: modelAnnotation,
+}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview <file.ogg>",
+	Short: "Play a composition on a drawing of the phone in the terminal",
+	Long: `Animate the glyph lights of a composition in the terminal, drawn on an
+approximate layout of the phone it was made for.
+
+Keys: space pauses, left/right seek by a second, , and . step single frames,
+- and + change the speed, 0 restarts and q quits. --audio plays the sound
+with ffplay or mpv while running at normal speed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := readComposition(cmd, args[0])
+
+		opts := preview.Options{}
+		opts.Speed, _ = cmd.Flags().GetFloat64("speed")
+		opts.Rows, _ = cmd.Flags().GetInt("rows")
+		opts.Loop, _ = cmd.Flags().GetBool("loop")
+		opts.Audio, _ = cmd.Flags().GetBool("audio")
+		if opts.Speed < preview.MinSpeed || opts.Speed > preview.MaxSpeed {
+			fmt.Fprintf(os.Stderr, "Error: --speed must be between %g and %g\n", preview.MinSpeed, preview.MaxSpeed)
+			os.Exit(1)
+		}
+
+		if err := preview.Run(c, args[0], opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+	Annotations: modelAnnotation,
+}
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List the phone models known to the glyph commands",
+	Long: `List and describe the phone models that inspect, preview, render, lint and
+migrate accept for --model.
+
+More models can be added without recompiling in models.yaml inside the data
+directory. Entries use the fields shown by 'cngt-cli models show --yaml', a
+model with the name of a built-in one replaces it.`,
+}
+
+var modelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the known phone models",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("%-10s %-16s %-12s %s\n", "NAME", "DISPLAY NAME", "CODENAME", "ZONES")
+		for _, m := range glyph.Models {
+			zones := make([]string, len(m.Columns))
+			for i, n := range m.Columns {
+				zones[i] = fmt.Sprint(n)
+			}
+			source := ""
+			if m.Source != "" {
+				source = "  (" + m.Source + ")"
+			}
+			fmt.Printf("%-10s %-16s %-12s %s%s\n", m.Name, m.DisplayName, m.Codename, strings.Join(zones, ", "), source)
+		}
+	},
+}
+
+var modelsShowCmd = &cobra.Command{
+	Use:   "show <model>",
+	Short: "Show the zones, compatibility modes and glyphs of a phone model",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m := lookupModel(args[0])
+		if asYAML, _ := cmd.Flags().GetBool("yaml"); asYAML {
+			data, err := yaml.Marshal(map[string][]*glyph.Model{"models": {m}})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Stdout.Write(data)
+			return
+		}
+		glyph.PrintModel(os.Stdout, m)
+	},
+}
+
+// lookupModel finds a phone model or exits
+func lookupModel(name string) *glyph.Model {
+	m, err := glyph.LookupModel(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (see 'cngt-cli models list')\n", err)
+		os.Exit(1)
+	}
+	return m
+}
+
+// modelFlag returns the model named by --model, nil when it is not set
+func modelFlag(cmd *cobra.Command) *glyph.Model {
+	name, _ := cmd.Flags().GetString("model")
+	if name == "" {
+		return nil
+	}
+	return lookupModel(name)
+}
+
+// readComposition reads a composition and applies --model, which overrides
+// the model detected from its tags
+func readComposition(cmd *cobra.Command, path string) *glyph.Composition {
+	c, err := glyph.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if m := modelFlag(cmd); m != nil {
+		if !m.SupportsColumns(c.Columns) {
+			fmt.Fprintf(os.Stderr, "Error: %s does not support %d zones (see 'cngt-cli models show %s')\n", m.DisplayName, c.Columns, m.Name)
+			os.Exit(1)
+		}
+		c.Model = m
+	}
+	return c
+}
+
+// checkModelArgs validates --model values of script arguments against the
+// known phone models
+func checkModelArgs(args []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return
+		}
+		name, ok := strings.CutPrefix(arg, "--model=")
+		if !ok && arg == "--model" && i+1 < len(args) {
+			name, ok = args[i+1], true
+		}
+		if ok {
+			lookupModel(name)
+		}
+	}
+}
+
+const modelFlagUsage = "phone model, see 'cngt-cli models list'"
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <labels.txt>...",
+	Short: "Check Audacity label files for GlyphTranslator",
+	Long: `Check the syntax of Audacity label files exported for GlyphTranslator.py
+and report problems with their line and column: malformed times and labels,
+light levels, effect names, durations, and zones that are out of range or lit
+by overlapping labels.
+
+The phone model comes from the PHONE_MODEL label unless --model is given.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		model := modelFlag(cmd)
+
+		failed := false
+		for _, path := range args {
+			r, err := lint.File(path, model)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if !printLintResult(path, r) {
+				fmt.Printf("❌ %s: %d issues\n", path, len(r.Issues))
+				failed = true
+			} else if r.Model != nil {
+				fmt.Printf("✅ %s: no issues (%s)\n", path, r.Model.DisplayName)
+			} else {
+				fmt.Printf("✅ %s: no issues\n", path)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+	Annotations: modelAnnotation,
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render <file.ogg>",
+	Short: "Render a composition to an animated GIF, APNG or PNG sequence",
+	Long: `Draw the glyph lights of a composition on the phone layout and save them
+as an animated GIF, an animated PNG or a directory of numbered PNG files.
+
+The output defaults to the input name with a .gif or .png extension, or a
+directory named after the input for png-seq. The background accepts
+#rrggbb, #rrggbbaa, black, white or transparent.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := render.Options{}
+		opts.Format, _ = cmd.Flags().GetString("format")
+		opts.Height, _ = cmd.Flags().GetInt("height")
+		opts.FPS, _ = cmd.Flags().GetInt("fps")
+		background, _ := cmd.Flags().GetString("background")
+		out, _ := cmd.Flags().GetString("output")
+
+		var err error
+		if opts.Background, err = render.ParseColor(background); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if opts.Height <= 0 || opts.FPS <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: --height and --fps must be positive\n")
+			os.Exit(1)
+		}
+		if out == "" {
+			out = strings.TrimSuffix(args[0], filepath.Ext(args[0]))
+			switch opts.Format {
+			case render.FormatGIF:
+				out += ".gif"
+			case render.FormatAPNG:
+				out += ".png"
+			}
+		}
+
+		n, err := render.Render(readComposition(cmd, args[0]), out, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("🎞️  Wrote %s (%d images)\n", out, n)
 	},
+	Annotations: modelAnnotation,
 }
 
+func setupFromBundle(bundlePath string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	fmt.Printf("📦 Installing CNGT from %s...\n", bundlePath)
+	fmt.Printf("   Location: %s\n", cfg.CNGTPath)
+	meta, err := bundle.Install(bundlePath, cfg.CNGTPath, deps.InstallOffline)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Installed commit %s with %d bundled wheels\n", meta.Commit[:7], len(meta.Wheels))
+	return nil
+}
 
 func checkForUpdatesAsync() {
 	// Check for updates on first setup (weekly)
@@ -161,6 +1811,8 @@ func performSetupIfNeeded() error {
 	needsSetup := false
 	if !cngt.IsInstalled(cfg.CNGTPath) {
 		needsSetup = true
+	} else if err := cngt.RemoveGeneratedFiles(cfg.CNGTPath); err != nil {
+		return err
 	}
 	if !deps.AreInstalled() {
 		needsSetup = true
@@ -170,12 +1822,7 @@ func performSetupIfNeeded() error {
 		fmt.Println("📋 CNGT CLI - First Time Setup")
 		fmt.Println("This tool requires the CNGT repository and Python dependencies.")
 		fmt.Println()
-		fmt.Print("Would you like to install everything now? (Y/n): ")
-		
-		var response string
-		fmt.Scanln(&response)
-		
-		if response == "n" || response == "N" || response == "no" || response == "No" {
+		if !prompt.Confirm("Would you like to install everything now? (Y/n): ", true) {
 			fmt.Println("Setup cancelled. You can run 'cngt-cli setup' anytime to install.")
 			return fmt.Errorf("setup required but cancelled by user")
 		}
@@ -198,11 +1845,17 @@ func interactiveSetup() error {
 	// Install CNGT repository
 	if !cngt.IsInstalled(cfg.CNGTPath) {
 		fmt.Println("📦 Installing CNGT repository...")
-		fmt.Println("   Repository: https://github.com/SebiAi/custom-nothing-glyph-tools")
+		fmt.Printf("   Repository: %s\n", cfg.CloneURL())
+		if cfg.RepoBranch != "" {
+			fmt.Printf("   Branch: %s\n", cfg.RepoBranch)
+		}
+		if cfg.CloneDepth > 0 {
+			fmt.Printf("   History: last %d commit(s)\n", cfg.CloneDepth)
+		}
 		fmt.Printf("   Location: %s\n", cfg.CNGTPath)
 		fmt.Println()
 		
-		if err := cngt.Install(cfg.CNGTPath); err != nil {
+		if err := cngt.Install(cfg.CNGTPath, cngt.InstallOptionsFromConfig(cfg)); err != nil {
 			return fmt.Errorf("failed to install CNGT repository: %w", err)
 		}
 		fmt.Println("✅ CNGT repository installed successfully")
@@ -210,12 +1863,17 @@ func interactiveSetup() error {
 	} else {
 		fmt.Println("✅ CNGT repository already installed")
 		fmt.Println()
+		if err := cngt.RemoveGeneratedFiles(cfg.CNGTPath); err != nil {
+			return err
+		}
 	}
 
 	// Check and install Python dependencies
 	if !deps.AreInstalled() {
 		fmt.Println("🐍 Installing Python dependencies...")
-		fmt.Println("   Required packages: termcolor, mido, colorama>=0.4.6, cryptography>=42.0.5")
+		if reqs, err := deps.LoadRequirements(cfg.CNGTPath); err == nil {
+			fmt.Printf("   Required packages (%s): %s\n", reqs.Source, strings.Join(reqs.Strings(), ", "))
+		}
 		fmt.Println()
 		
 		if err := deps.CheckInteractive(); err != nil {
@@ -233,22 +1891,25 @@ func interactiveSetup() error {
 
 func shouldCheckForUpdates() bool {
 	cfg, err := config.Load()
-	if err != nil {
+	if err != nil || !cfg.CheckForUpdates {
 		return false
 	}
 
 	lastCheckFile := filepath.Join(cfg.DataDir, "last_update_check")
 	if info, err := os.Stat(lastCheckFile); err == nil {
-		// Check if more than a week has passed
-		return time.Since(info.ModTime()) > 7*24*time.Hour
+		// Check if the configured interval has passed
+		if time.Since(info.ModTime()) <= cfg.UpdateCheckInterval {
+			return false
+		}
 	}
 
-	// Create the file for future checks
+	// Record this check for future runs
 	os.WriteFile(lastCheckFile, []byte(time.Now().Format(time.RFC3339)), 0644)
 	return true
 }
 
 func init() {
+	rootCmd.PersistentFlags().String("progress", "", "progress output: auto, bar, plain or json (default: progress setting)")
 	rootCmd.AddCommand(migrateCmd)
 	rootCmd.AddCommand(modderCmd)
 	rootCmd.AddCommand(translatorCmd)
@@ -256,6 +1917,90 @@ func init() {
 	rootCmd.AddCommand(upgradeCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(setupCmd)
+
+	updateCmd.Flags().String("to", "", "pin the checkout to a tag or commit")
+	updateCmd.Flags().Bool("rollback", false, "restore the commit installed before history entry n, given as an argument (default 1)")
+	updateCmd.Flags().Bool("history", false, "list recorded updates")
+	updateCmd.Flags().Bool("dry-run", false, "show the upstream changelog without changing the checkout")
+	updateCmd.MarkFlagsMutuallyExclusive("to", "rollback", "history")
+	updateCmd.Flags().Bool("stash", false, "save local modifications of the checkout as a patch before updating")
+	updateCmd.Flags().Bool("discard", false, "discard local modifications of the checkout before updating")
+	updateCmd.MarkFlagsMutuallyExclusive("dry-run", "rollback")
+	updateCmd.MarkFlagsMutuallyExclusive("stash", "discard")
+	lockCmd.Flags().String("file", cngt.LockFileName, "lockfile to write")
+	rootCmd.AddCommand(lockCmd)
+
+	repoSetRemoteCmd.Flags().String("name", "", "remote to re-point (default: repo_remote)")
+	repoVerifyCmd.Flags().Bool("repair", false, "restore modified and missing files from the commit")
+	repoVerifyCmd.Flags().Bool("remove-untracked", false, "with --repair, also delete untracked files")
+	repoCmd.AddCommand(repoSetRemoteCmd)
+	repoCmd.AddCommand(repoAddRemoteCmd)
+	repoCmd.AddCommand(repoRemoveRemoteCmd)
+	repoCmd.AddCommand(repoRemotesCmd)
+	repoCmd.AddCommand(repoRestorePatchCmd)
+	repoCmd.AddCommand(repoPatchesCmd)
+	repoCmd.AddCommand(repoVerifyCmd)
+	rootCmd.AddCommand(repoCmd)
+
+	setupCmd.Flags().String("from-bundle", "", "install from an offline bundle")
+	setupCmd.Flags().String("mirror", "", "git URL or local path to clone from instead of repo_url")
+	setupCmd.Flags().Bool("shallow", false, "clone only the latest commit")
+	setupCmd.MarkFlagsMutuallyExclusive("from-bundle", "mirror")
+	setupCmd.MarkFlagsMutuallyExclusive("from-bundle", "shallow")
+	bundleCreateCmd.Flags().String("wheels", "", "directory of wheels to include instead of downloading them")
+	bundleCmd.AddCommand(bundleCreateCmd)
+	rootCmd.AddCommand(bundleCmd)
+	rootCmd.AddCommand(inspectCmd)
+	previewCmd.Flags().Float64("speed", 1, "playback speed")
+	previewCmd.Flags().Int("rows", preview.DefaultRows, "height of the phone in terminal rows")
+	previewCmd.Flags().Bool("loop", false, "start over at the end")
+	previewCmd.Flags().Bool("audio", false, "play the sound with ffplay or mpv")
+	rootCmd.AddCommand(previewCmd)
+	renderCmd.Flags().String("format", render.FormatGIF, "output format: "+strings.Join(render.Formats, ", "))
+	renderCmd.Flags().Int("height", render.DefaultHeight, "image height in pixels")
+	renderCmd.Flags().Int("fps", render.DefaultFPS, "frames per second")
+	renderCmd.Flags().String("background", "black", "background color")
+	renderCmd.Flags().StringP("output", "o", "", "output file, or directory for png-seq")
+	rootCmd.AddCommand(renderCmd)
+	lintCmd.Flags().String("model", "", modelFlagUsage)
+	inspectCmd.Flags().String("model", "", modelFlagUsage)
+	previewCmd.Flags().String("model", "", modelFlagUsage)
+	renderCmd.Flags().String("model", "", modelFlagUsage)
+	modelsShowCmd.Flags().Bool("yaml", false, "print the model in the format of models.yaml")
+	modelsCmd.AddCommand(modelsListCmd, modelsShowCmd)
+	rootCmd.AddCommand(modelsCmd)
+	batchCmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "number of runs at a time")
+	batchCmd.Flags().String("manifest", "", "file with the arguments of one run per line")
+	batchCmd.Flags().String("log-dir", "", "directory for the logs (default cngt-batch-logs/<time>)")
+	batchCmd.Flags().String("toolchain", "", "run the scripts of a named installation")
+	batchCmd.Flags().Bool("no-verify", false, "skip checking the checkout for modified files")
+	batchCmd.Flags().Bool("no-lint", false, "skip checking label files before translator runs")
+	rootCmd.AddCommand(batchCmd)
+	buildCmd.Flags().Bool("force", false, "rebuild compositions whose inputs did not change")
+	buildCmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "number of compositions built at a time")
+	buildCmd.Flags().String("toolchain", "", "run the scripts of a named installation")
+	buildCmd.Flags().Bool("no-verify", false, "skip checking the checkout for modified files")
+	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(lintCmd)
+
+	toolchainAddCmd.Flags().String("ref", "", "tag or commit to check out")
+	toolchainAddCmd.Flags().String("url", "", "repository to clone (default: repo_mirror or repo_url)")
+	toolchainAddCmd.Flags().String("branch", "", "branch to clone (default: repo_branch)")
+	toolchainCmd.AddCommand(toolchainAddCmd)
+	toolchainCmd.AddCommand(toolchainListCmd)
+	toolchainCmd.AddCommand(toolchainUseCmd)
+	toolchainCmd.AddCommand(toolchainRemoveCmd)
+	rootCmd.AddCommand(toolchainCmd)
+
+	pythonCmd.AddCommand(pythonListCmd, pythonUseCmd, pythonInstallCmd)
+	rootCmd.AddCommand(pythonCmd)
+
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configPathCmd)
+	rootCmd.AddCommand(configCmd)
 }
 
 func main() {
@@ -0,0 +1,211 @@
+// Package batch runs many script invocations in a bounded worker pool
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job is a single invocation of a script
+type Job struct {
+	// Index is the 1-based position of the job
+	Index int
+	// Input names the job in logs and the summary, usually its input file
+	Input string
+	Args  []string
+	// Log receives the standard output and error of the job
+	Log string
+}
+
+// Status of a finished job
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// Result is the outcome of a job
+type Result struct {
+	Job      *Job
+	Status   string
+	Err      error
+	Duration time.Duration
+}
+
+// RunFunc runs a job with its output going to log
+type RunFunc func(ctx context.Context, job *Job, log io.Writer) error
+
+// NewJobs creates one job per argument list, logging to logDir. The input
+// of a job is its last argument that names an existing file, or its last
+// argument, relative to the working directory when it is below it.
+func NewJobs(argLists [][]string, logDir string) []*Job {
+	jobs := make([]*Job, len(argLists))
+	for i, args := range argLists {
+		job := &Job{Index: i + 1, Args: args}
+		for j := len(args) - 1; j >= 0 && job.Input == ""; j-- {
+			if info, err := os.Stat(args[j]); err == nil && !info.IsDir() {
+				job.Input = args[j]
+			}
+		}
+		if job.Input == "" && len(args) > 0 {
+			job.Input = args[len(args)-1]
+		}
+		job.Input = relative(job.Input)
+		job.Log = filepath.Join(logDir, fmt.Sprintf("%03d-%s.log", job.Index, logName(job.Input)))
+		jobs[i] = job
+	}
+	return jobs
+}
+
+// relative shortens absolute paths below the working directory
+func relative(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
+
+// logName turns an input into a file name
+func logName(input string) string {
+	name := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." {
+		return "job"
+	}
+	return name
+}
+
+// Run runs the jobs with at most workers at a time and returns their
+// results in job order. done is called as each job finishes. Once ctx is
+// cancelled, jobs that have not started are skipped.
+func Run(ctx context.Context, jobs []*Job, workers int, run RunFunc, done func(*Result)) []*Result {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]*Result, len(jobs))
+	queue := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < min(workers, len(jobs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				r := runJob(ctx, jobs[i], run)
+				mu.Lock()
+				results[i] = r
+				if done != nil {
+					done(r)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+func runJob(ctx context.Context, job *Job, run RunFunc) *Result {
+	r := &Result{Job: job}
+	if ctx.Err() != nil {
+		r.Status = StatusSkipped
+		r.Err = ctx.Err()
+		return r
+	}
+
+	start := time.Now()
+	r.Err = runLogged(ctx, job, run)
+	r.Duration = time.Since(start)
+	r.Status = StatusOK
+	if r.Err != nil {
+		r.Status = StatusFailed
+	}
+	return r
+}
+
+func runLogged(ctx context.Context, job *Job, run RunFunc) error {
+	if err := os.MkdirAll(filepath.Dir(job.Log), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.Create(job.Log)
+	if err != nil {
+		return fmt.Errorf("failed to create log: %w", err)
+	}
+	defer f.Close()
+
+	if len(job.Args) > 0 {
+		fmt.Fprintf(f, "$ %s\n\n", strings.Join(job.Args, " "))
+	}
+	err = run(ctx, job, f)
+	if err != nil {
+		fmt.Fprintf(f, "\nError: %v\n", err)
+	}
+	return err
+}
+
+// Failed counts the results that did not succeed
+func Failed(results []*Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Status != StatusOK {
+			n++
+		}
+	}
+	return n
+}
+
+// PrintSummary writes a table of all results
+func PrintSummary(w io.Writer, results []*Result) {
+	width := len("Input")
+	for _, r := range results {
+		width = max(width, len(r.Job.Input))
+	}
+
+	fmt.Fprintf(w, "%-4s %-7s %8s  %-*s  %s\n", "#", "Status", "Time", width, "Input", "Log")
+	for _, r := range results {
+		status := r.Status
+		if r.Status == StatusFailed {
+			status = describeFailure(r.Err)
+		}
+		fmt.Fprintf(w, "%-4d %-7s %7.1fs  %-*s  %s\n", r.Job.Index, status, r.Duration.Seconds(), width, r.Job.Input, r.Job.Log)
+	}
+
+	failed := Failed(results)
+	fmt.Fprintf(w, "\n%d succeeded, %d failed\n", len(results)-failed, failed)
+}
+
+// describeFailure shortens exit errors to their exit code
+func describeFailure(err error) string {
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() > 0 {
+		return fmt.Sprintf("exit %d", exit.ExitCode())
+	}
+	return StatusFailed
+}
@@ -0,0 +1,172 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func touch(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, dir, "a.txt", "b.ogg", "songs/c.txt", "songs/deep/d.txt", "songs/deep/e.ogg")
+
+	rel := func(files []string) []string {
+		var out []string
+		for _, f := range files {
+			r, _ := filepath.Rel(dir, f)
+			out = append(out, filepath.ToSlash(r))
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"*.txt"}, []string{"a.txt"}},
+		{[]string{"**/*.txt"}, []string{"a.txt", "songs/c.txt", "songs/deep/d.txt"}},
+		{[]string{"songs/**/*.ogg", "b.ogg"}, []string{"songs/deep/e.ogg", "b.ogg"}},
+		{[]string{"songs/*/*", "songs/deep/d.txt"}, []string{"songs/deep/d.txt", "songs/deep/e.ogg"}},
+	} {
+		var patterns []string
+		for _, p := range tc.patterns {
+			patterns = append(patterns, filepath.Join(dir, p))
+		}
+		got, err := Expand(patterns)
+		if err != nil {
+			t.Errorf("%v: %v", tc.patterns, err)
+		} else if !slices.Equal(rel(got), tc.want) {
+			t.Errorf("%v = %v, want %v", tc.patterns, rel(got), tc.want)
+		}
+	}
+
+	for _, pattern := range []string{"*.wav", "missing.txt", "songs"} {
+		if _, err := Expand([]string{filepath.Join(dir, pattern)}); err == nil {
+			t.Errorf("%s: expected an error", pattern)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	for line, want := range map[string][]string{
+		`a.txt -o out.ogg`:             {"a.txt", "-o", "out.ogg"},
+		`"my song.txt"  --title 'A B'`: {"my song.txt", "--title", "A B"},
+		`it\'s "a \"b\"" ''`:           {"it's", `a "b"`, ""},
+	} {
+		got, err := SplitArgs(line)
+		if err != nil {
+			t.Errorf("%s: %v", line, err)
+		} else if !slices.Equal(got, want) {
+			t.Errorf("%s = %q, want %q", line, got, want)
+		}
+	}
+	if _, err := SplitArgs(`"open`); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.txt")
+	manifest := "# ringtones\n\na.txt\n  \"b c.txt\" --disableCompatibility\n"
+	if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || !slices.Equal(jobs[1], []string{"b c.txt", "--disableCompatibility"}) {
+		t.Errorf("got %q", jobs)
+	}
+}
+
+func TestRun(t *testing.T) {
+	logDir := t.TempDir()
+	var argLists [][]string
+	for i := 0; i < 10; i++ {
+		argLists = append(argLists, []string{"--flag", fmt.Sprintf("song%d.txt", i)})
+	}
+	jobs := NewJobs(argLists, logDir)
+	if jobs[3].Input != "song3.txt" || jobs[3].Log != filepath.Join(logDir, "004-song3.log") {
+		t.Errorf("unexpected job %+v", jobs[3])
+	}
+
+	var running, peak atomic.Int32
+	run := func(ctx context.Context, job *Job, log io.Writer) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		fmt.Fprintf(log, "processing %s\n", job.Input)
+		if job.Index%4 == 0 {
+			return fmt.Errorf("broken")
+		}
+		return nil
+	}
+
+	var finished int
+	results := Run(context.Background(), jobs, 3, run, func(*Result) { finished++ })
+	if peak.Load() > 3 {
+		t.Errorf("%d jobs ran at once, want at most 3", peak.Load())
+	}
+	if finished != 10 || Failed(results) != 2 {
+		t.Errorf("finished %d jobs with %d failures, want 10 and 2", finished, Failed(results))
+	}
+	for i, r := range results {
+		if r.Job != jobs[i] {
+			t.Fatal("results are not in job order")
+		}
+	}
+
+	log, err := os.ReadFile(jobs[3].Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), "$ --flag song3.txt") || !strings.Contains(string(log), "Error: broken") {
+		t.Errorf("unexpected log:\n%s", log)
+	}
+
+	var buf bytes.Buffer
+	PrintSummary(&buf, results)
+	if !strings.Contains(buf.String(), "8 succeeded, 2 failed") {
+		t.Errorf("unexpected summary:\n%s", buf.String())
+	}
+}
+
+func TestRunSkipsAfterCancel(t *testing.T) {
+	jobs := NewJobs([][]string{{"a"}, {"b"}, {"c"}}, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	run := func(ctx context.Context, job *Job, log io.Writer) error {
+		cancel()
+		return nil
+	}
+	results := Run(ctx, jobs, 1, run, nil)
+	if results[0].Status != StatusOK || results[1].Status != StatusSkipped || results[2].Status != StatusSkipped {
+		t.Errorf("statuses = %s %s %s, want ok skipped skipped", results[0].Status, results[1].Status, results[2].Status)
+	}
+}
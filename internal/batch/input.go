@@ -0,0 +1,193 @@
+package batch
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Expand returns the files matching the patterns, sorted and without
+// duplicates. Patterns use filepath.Match syntax, and a ** path element
+// matches any number of directories. A pattern without wildcards must name
+// an existing file.
+func Expand(patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	return files, nil
+}
+
+func glob(pattern string) ([]string, error) {
+	pattern = filepath.Clean(pattern)
+	if !hasMeta(pattern) {
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pattern, err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory", pattern)
+		}
+		return []string{pattern}, nil
+	}
+
+	// Walk from the longest directory prefix without wildcards
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	i := 0
+	for i < len(parts)-1 && !hasMeta(parts[i]) {
+		i++
+	}
+	root := filepath.FromSlash(strings.Join(parts[:i], "/"))
+	if root == "" {
+		root = "."
+	}
+	if strings.HasPrefix(pattern, "/") && i == 1 {
+		root = "/"
+	}
+	rest := parts[i:]
+
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		if match(rest, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to search %s: %w", root, err)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// match matches path elements against pattern elements
+func match(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if match(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, err := filepath.Match(pattern[0], path[0])
+	return err == nil && ok && match(pattern[1:], path[1:])
+}
+
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[`)
+}
+
+// ReadManifest reads a manifest file with the arguments of one job per
+// line. Arguments are separated by spaces and may be quoted with single or
+// double quotes. Empty lines and lines starting with # are ignored.
+func ReadManifest(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var jobs [][]string
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := SplitArgs(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		jobs = append(jobs, args)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return jobs, nil
+}
+
+// SplitArgs splits a line into arguments like a POSIX shell without
+// expansions: quotes group words and a backslash escapes the next
+// character outside single quotes
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("line ends with a backslash")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
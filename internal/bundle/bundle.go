@@ -0,0 +1,444 @@
+// Package bundle packs a CNGT checkout and a wheelhouse of its Python
+// dependencies into a single archive for installation without network access.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/klauspost/compress/zstd"
+	"github.com/snupai/cngt-cli/internal/version"
+)
+
+// FormatVersion is increased when the archive layout changes incompatibly
+const FormatVersion = 1
+
+// Archive layout
+const (
+	metadataName = "bundle.json"
+	repoDir      = "repo"
+	wheelsDir    = "wheels"
+)
+
+// Metadata describes the contents of a bundle
+type Metadata struct {
+	FormatVersion int       `json:"format_version"`
+	Created       time.Time `json:"created"`
+	CLIVersion    string    `json:"cli_version"`
+	Platform      string    `json:"platform"`
+	RepoURL       string    `json:"repo_url,omitempty"`
+	Commit        string    `json:"commit"`
+	Ref           string    `json:"ref,omitempty"`
+	Wheels        []string  `json:"wheels"`
+}
+
+// CreateOptions selects what goes into a bundle
+type CreateOptions struct {
+	CheckoutPath string
+	// WheelDir holds the wheels of all Python dependencies
+	WheelDir string
+	// RemoteName is the remote whose URL is recorded in the metadata
+	RemoteName string
+}
+
+// skippedDirs are never bundled, they are machine specific or regenerated
+var skippedDirs = map[string]bool{
+	".venv":       true,
+	"__pycache__": true,
+}
+
+// Create writes a bundle of the checkout and all wheels to out. The
+// compression is picked from the file extension (.tar.zst, .tar.gz or .tar).
+func Create(out string, opts CreateOptions) (*Metadata, error) {
+	checkoutPath, wheelDir := opts.CheckoutPath, opts.WheelDir
+	meta, err := describe(checkoutPath, wheelDir, opts.RemoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer f.Close()
+
+	cw, err := compressor(out, f)
+	if err != nil {
+		os.Remove(out)
+		return nil, err
+	}
+
+	if err := writeArchive(cw, meta, checkoutPath, wheelDir); err != nil {
+		cw.Close()
+		os.Remove(out)
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		os.Remove(out)
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return meta, f.Close()
+}
+
+func describe(checkoutPath, wheelDir, remoteName string) (*Metadata, error) {
+	repo, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	meta := &Metadata{
+		FormatVersion: FormatVersion,
+		Created:       time.Now().UTC(),
+		CLIVersion:    version.GetVersion(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		Commit:        head.Hash().String(),
+	}
+	if head.Name().IsBranch() {
+		meta.Ref = head.Name().Short()
+	}
+	if remote, err := repo.Remote(remoteName); err == nil && len(remote.Config().URLs) > 0 {
+		meta.RepoURL = remote.Config().URLs[0]
+	}
+
+	entries, err := os.ReadDir(wheelDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wheel directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			meta.Wheels = append(meta.Wheels, entry.Name())
+		}
+	}
+	if len(meta.Wheels) == 0 {
+		return nil, fmt.Errorf("wheel directory %s is empty", wheelDir)
+	}
+	sort.Strings(meta.Wheels)
+
+	return meta, nil
+}
+
+func writeArchive(w io.Writer, meta *Metadata, checkoutPath, wheelDir string) error {
+	tw := tar.NewWriter(w)
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	hdr := &tar.Header{Name: metadataName, Mode: 0644, Size: int64(len(data)), ModTime: meta.Created}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	if err := addTree(tw, checkoutPath, repoDir); err != nil {
+		return fmt.Errorf("failed to add checkout: %w", err)
+	}
+	for _, name := range meta.Wheels {
+		if err := addFile(tw, filepath.Join(wheelDir, name), path.Join(wheelsDir, name)); err != nil {
+			return fmt.Errorf("failed to add wheel %s: %w", name, err)
+		}
+	}
+
+	return tw.Close()
+}
+
+func addTree(tw *tar.Writer, root, prefix string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(prefix, filepath.ToSlash(rel))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir() && skippedDirs[d.Name()]:
+			return filepath.SkipDir
+		case d.IsDir():
+			return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0755, ModTime: info.ModTime()})
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: name, Linkname: target, ModTime: info.ModTime()})
+		case info.Mode().IsRegular():
+			return addFile(tw, p, name)
+		}
+		return nil
+	})
+}
+
+func addFile(tw *tar.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Extract unpacks a bundle into dest. The checkout ends up in
+// CheckoutDir(dest) and the wheels in WheelDir(dest).
+func Extract(bundlePath, dest string) (*Metadata, error) {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	r, err := decompressor(bundlePath, f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var meta *Metadata
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+
+		if hdr.Name == metadataName {
+			meta = &Metadata{}
+			if err := json.NewDecoder(tr).Decode(meta); err != nil {
+				return nil, fmt.Errorf("failed to parse bundle metadata: %w", err)
+			}
+			if meta.FormatVersion > FormatVersion {
+				return nil, fmt.Errorf("bundle format %d is newer than supported (%d), upgrade cngt-cli", meta.FormatVersion, FormatVersion)
+			}
+			continue
+		}
+
+		target, err := safeJoin(dest, hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := extractEntry(tr, hdr, dest, target); err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", hdr.Name, err)
+		}
+	}
+
+	if meta == nil {
+		return nil, fmt.Errorf("%s is not a cngt-cli bundle (missing %s)", bundlePath, metadataName)
+	}
+	return meta, nil
+}
+
+// Install extracts a bundle, moves the checkout to checkoutPath and hands the
+// wheel directory to installWheels before cleaning up. The checkout is
+// removed again when the wheels cannot be installed, so that the install
+// can be retried.
+func Install(bundlePath, checkoutPath string, installWheels func(wheelDir string) error) (*Metadata, error) {
+	if _, err := os.Stat(checkoutPath); err == nil {
+		return nil, fmt.Errorf("%s already exists, remove it first", checkoutPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(checkoutPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Extract next to the destination so the checkout can simply be renamed
+	work, err := os.MkdirTemp(filepath.Dir(checkoutPath), ".bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(work)
+
+	meta, err := Extract(bundlePath, work)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPlatform(meta); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(CheckoutDir(work), checkoutPath); err != nil {
+		return nil, fmt.Errorf("failed to install checkout: %w", err)
+	}
+
+	if err := installWheels(WheelDir(work)); err != nil {
+		os.RemoveAll(checkoutPath)
+		return meta, err
+	}
+	return meta, nil
+}
+
+// checkPlatform refuses bundles built for another platform, whose wheels
+// would fail to install. Bundles without a platform are accepted.
+func checkPlatform(meta *Metadata) error {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	if meta.Platform != "" && meta.Platform != platform {
+		return fmt.Errorf("bundle was created on %s and cannot be installed on %s, create one with 'cngt-cli bundle create' on a %s machine", meta.Platform, platform, platform)
+	}
+	return nil
+}
+
+// CheckoutDir returns where Extract put the CNGT checkout
+func CheckoutDir(dest string) string {
+	return filepath.Join(dest, repoDir)
+}
+
+// WheelDir returns where Extract put the wheels
+func WheelDir(dest string) string {
+	return filepath.Join(dest, wheelsDir)
+}
+
+// extractEntry writes a single archive entry to target inside dest.
+// Symlinks must point inside dest, and no entry is written through a
+// symlink extracted earlier.
+func extractEntry(tr *tar.Reader, hdr *tar.Header, dest, target string) error {
+	if err := checkParents(dest, target); err != nil {
+		return err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, 0755)
+	case tar.TypeSymlink:
+		if filepath.IsAbs(hdr.Linkname) || strings.HasPrefix(hdr.Linkname, "/") {
+			return fmt.Errorf("symlink to absolute path %s", hdr.Linkname)
+		}
+		rel, err := filepath.Rel(dest, filepath.Join(filepath.Dir(target), filepath.FromSlash(hdr.Linkname)))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("symlink to %s escapes the target directory", hdr.Linkname)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Symlink(hdr.Linkname, target)
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if info, err := os.Lstat(target); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", hdr.Name)
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode)&0777|0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+	return nil
+}
+
+// checkParents refuses targets whose parent directories below dest
+// include a symlink, which could lead outside dest
+func checkParents(dest, target string) error {
+	rel, err := filepath.Rel(dest, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+	dir := dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("parent %s is a symlink", dir)
+		}
+	}
+	return nil
+}
+
+// safeJoin refuses archive entries that would escape dest
+func safeJoin(dest, name string) (string, error) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dest, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("bundle entry %s escapes the target directory", name)
+	}
+	return target, nil
+}
+
+func compressor(name string, w io.Writer) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".tar.zst") || strings.HasSuffix(name, ".tzst"):
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		return zw, nil
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return gzip.NewWriter(w), nil
+	case strings.HasSuffix(name, ".tar"):
+		return nopWriteCloser{w}, nil
+	}
+	return nil, fmt.Errorf("unsupported bundle extension %s (use .tar.zst, .tar.gz or .tar)", filepath.Base(name))
+}
+
+func decompressor(name string, r io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".tar.zst") || strings.HasSuffix(name, ".tzst"):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd bundle: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip bundle: %w", err)
+		}
+		return gr, nil
+	case strings.HasSuffix(name, ".tar"):
+		return io.NopCloser(r), nil
+	}
+	return nil, fmt.Errorf("unsupported bundle extension %s (use .tar.zst, .tar.gz or .tar)", filepath.Base(name))
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
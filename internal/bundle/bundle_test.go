@@ -0,0 +1,241 @@
+package bundle
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func fixtureCheckout(t *testing.T) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"GlyphTranslator.py": "print('translate')\n",
+		"requirements.txt":   "termcolor\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := w.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Virtual environments are machine specific and must not be bundled
+	if err := os.MkdirAll(filepath.Join(dir, ".venv", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, hash.String()
+}
+
+func TestCreateAndInstall(t *testing.T) {
+	checkout, commit := fixtureCheckout(t)
+
+	wheels := t.TempDir()
+	if err := os.WriteFile(filepath.Join(wheels, "termcolor-2.4.0-py3-none-any.whl"), []byte("wheel"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ext := range []string{".tar.zst", ".tar.gz", ".tar"} {
+		t.Run(ext, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "cngt"+ext)
+			meta, err := Create(out, CreateOptions{CheckoutPath: checkout, WheelDir: wheels, RemoteName: "origin"})
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+			if meta.Commit != commit || len(meta.Wheels) != 1 {
+				t.Fatalf("unexpected metadata %+v", meta)
+			}
+
+			dest := filepath.Join(t.TempDir(), "data", "cngt")
+			var installed []string
+			meta, err = Install(out, dest, func(wheelDir string) error {
+				entries, err := os.ReadDir(wheelDir)
+				for _, e := range entries {
+					installed = append(installed, e.Name())
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Install failed: %v", err)
+			}
+			if len(installed) != 1 || installed[0] != meta.Wheels[0] {
+				t.Errorf("wheels handed to installer = %v", installed)
+			}
+
+			repo, err := git.PlainOpen(dest)
+			if err != nil {
+				t.Fatalf("installed checkout is not a repository: %v", err)
+			}
+			head, err := repo.Head()
+			if err != nil || head.Hash().String() != commit {
+				t.Errorf("installed HEAD = %v, want %s", head, commit)
+			}
+			if _, err := os.Stat(filepath.Join(dest, ".venv")); !os.IsNotExist(err) {
+				t.Error(".venv should not be bundled")
+			}
+
+			entries, _ := os.ReadDir(filepath.Dir(dest))
+			if len(entries) != 1 {
+				t.Errorf("temporary files left behind: %v", entries)
+			}
+		})
+	}
+}
+
+func TestInstallRefusesExistingCheckout(t *testing.T) {
+	dest := t.TempDir()
+	if _, err := Install("unused.tar.zst", dest, nil); err == nil {
+		t.Error("expected an error for an existing checkout")
+	}
+}
+
+func TestInstallRemovesCheckoutAfterFailure(t *testing.T) {
+	checkout, _ := fixtureCheckout(t)
+	wheels := t.TempDir()
+	if err := os.WriteFile(filepath.Join(wheels, "termcolor-2.4.0-py3-none-any.whl"), []byte("wheel"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "cngt.tar")
+	if _, err := Create(out, CreateOptions{CheckoutPath: checkout, WheelDir: wheels}); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "cngt")
+	_, err := Install(out, dest, func(string) error { return fmt.Errorf("pip failed") })
+	if err == nil {
+		t.Fatal("expected the wheel error")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("checkout left behind after failure: %v", err)
+	}
+	if _, err := Install(out, dest, func(string) error { return nil }); err != nil {
+		t.Errorf("retry failed: %v", err)
+	}
+}
+
+func TestInstallRefusesOtherPlatform(t *testing.T) {
+	out := writeBundle(t, `{"format_version": 1, "platform": "plan9/mips"}`,
+		&tar.Header{Name: "repo/GlyphTranslator.py", Typeflag: tar.TypeReg, Mode: 0644},
+	)
+	dest := filepath.Join(t.TempDir(), "cngt")
+	_, err := Install(out, dest, func(string) error {
+		t.Error("wheels installed for another platform")
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "plan9/mips") {
+		t.Errorf("got %v, want a platform error", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("checkout installed for another platform")
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	dest := t.TempDir()
+	if _, err := safeJoin(dest, "repo/GlyphModder.py"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := safeJoin(dest, "../../etc/passwd"); err == nil {
+		t.Error("expected an error for an entry outside the target")
+	}
+}
+
+// writeBundle writes a .tar bundle with the metadata meta and the given
+// entries, regular files having the content "x"
+func writeBundle(t *testing.T, meta string, entries ...*tar.Header) string {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "evil.tar")
+	f, err := os.Create(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	entries = append([]*tar.Header{{Name: metadataName, Mode: 0644, Size: int64(len(meta))}}, entries...)
+	for _, hdr := range entries {
+		if hdr.Typeflag == tar.TypeReg && hdr.Name != metadataName {
+			hdr.Size = 1
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case hdr.Name == metadataName:
+			tw.Write([]byte(meta))
+		case hdr.Typeflag == tar.TypeReg:
+			tw.Write([]byte("x"))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestExtractRejectsSymlinkEscapes(t *testing.T) {
+	outside := t.TempDir()
+	for name, entries := range map[string][]*tar.Header{
+		"absolute link": {
+			{Name: "repo/x", Typeflag: tar.TypeSymlink, Linkname: outside},
+			{Name: "repo/x/.bashrc", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"relative link": {
+			{Name: "repo/x", Typeflag: tar.TypeSymlink, Linkname: "../../../.."},
+		},
+		"write through link": {
+			{Name: "repo/x", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "repo/x/y", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"overwrite link": {
+			{Name: "repo/x", Typeflag: tar.TypeSymlink, Linkname: "y"},
+			{Name: "repo/x", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "work")
+			if _, err := Extract(writeBundle(t, `{"format_version": 1}`, entries...), dest); err == nil {
+				t.Error("malicious bundle was extracted")
+			}
+			if files, _ := os.ReadDir(outside); len(files) != 0 {
+				t.Errorf("wrote %v outside the target", files)
+			}
+		})
+	}
+
+	// Links within the checkout still work
+	dest := filepath.Join(t.TempDir(), "work")
+	_, err := Extract(writeBundle(t, `{"format_version": 1}`,
+		&tar.Header{Name: "repo/docs/README.md", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "repo/README.md", Typeflag: tar.TypeSymlink, Linkname: "docs/README.md"},
+	), dest)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "repo", "README.md")); err != nil || string(data) != "x" {
+		t.Errorf("link read %q, %v", data, err)
+	}
+}
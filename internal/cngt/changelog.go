@@ -0,0 +1,206 @@
+package cngt
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// maxChangelogCommits limits how many commits are printed per section
+const maxChangelogCommits = 50
+
+// ChangelogCommit is a single upstream commit
+type ChangelogCommit struct {
+	Hash    string
+	Author  string
+	Time    time.Time
+	Summary string
+}
+
+// FileChange is a file that differs between two commits
+type FileChange struct {
+	Path   string
+	Action string // added, modified or deleted
+}
+
+// Changelog describes what moving the checkout from one commit to another changes
+type Changelog struct {
+	From string
+	To   string
+	// Added are commits reachable from To but not from From
+	Added []ChangelogCommit
+	// Removed are commits reachable from From but not from To, e.g. when pinning an older tag
+	Removed []ChangelogCommit
+	Files   []FileChange
+}
+
+// Empty reports whether From and To are the same commit
+func (c *Changelog) Empty() bool {
+	return c.From == c.To
+}
+
+// Touches reports whether any changed file matches the glob pattern
+func (c *Changelog) Touches(pattern string) bool {
+	for _, f := range c.Files {
+		if ok, _ := path.Match(pattern, f.Path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func buildChangelog(repo *git.Repository, from, to plumbing.Hash) (*Changelog, error) {
+	log := &Changelog{From: from.String(), To: to.String()}
+	if from == to {
+		return log, nil
+	}
+
+	fromCommit, err := repo.CommitObject(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", shortHash(from.String()), err)
+	}
+	toCommit, err := repo.CommitObject(to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", shortHash(to.String()), err)
+	}
+
+	if log.Added, err = commitsBetween(repo, fromCommit, toCommit); err != nil {
+		return nil, err
+	}
+	if log.Removed, err = commitsBetween(repo, toCommit, fromCommit); err != nil {
+		return nil, err
+	}
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree: %w", err)
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree: %w", err)
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff trees: %w", err)
+	}
+
+	for _, ch := range changes {
+		action, err := ch.Action()
+		if err != nil {
+			return nil, err
+		}
+		switch action {
+		case merkletrie.Insert:
+			log.Files = append(log.Files, FileChange{Path: ch.To.Name, Action: "added"})
+		case merkletrie.Delete:
+			log.Files = append(log.Files, FileChange{Path: ch.From.Name, Action: "deleted"})
+		default:
+			log.Files = append(log.Files, FileChange{Path: ch.To.Name, Action: "modified"})
+		}
+	}
+	sort.Slice(log.Files, func(i, j int) bool { return log.Files[i].Path < log.Files[j].Path })
+
+	return log, nil
+}
+
+// commitsBetween walks the history of include, newest first, and returns
+// the commits that are not reachable from exclude.
+func commitsBetween(repo *git.Repository, exclude, include *object.Commit) ([]ChangelogCommit, error) {
+	seen := map[plumbing.Hash]bool{}
+	err := object.NewCommitPreorderIter(exclude, nil, nil).ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+	if err != nil && err != plumbing.ErrObjectNotFound {
+		return nil, fmt.Errorf("failed to walk history: %w", err)
+	}
+
+	iter, err := repo.Log(&git.LogOptions{From: include.Hash, Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+	defer iter.Close()
+
+	var commits []ChangelogCommit
+	err = iter.ForEach(func(c *object.Commit) error {
+		if seen[c.Hash] {
+			return nil
+		}
+		commits = append(commits, ChangelogCommit{
+			Hash:    c.Hash.String(),
+			Author:  c.Author.Name,
+			Time:    c.Author.When,
+			Summary: strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0],
+		})
+		return nil
+	})
+	if err != nil && err != plumbing.ErrObjectNotFound {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+	return commits, nil
+}
+
+// fileNote flags files that matter for glyph output or the Python environment
+func fileNote(name string) string {
+	if ok, _ := path.Match("Glyph*.py", name); ok {
+		return "glyph script"
+	}
+	if name == "requirements.txt" || name == "pyproject.toml" {
+		return "Python dependencies"
+	}
+	return ""
+}
+
+// PrintChangelog writes a human readable changelog
+func PrintChangelog(w io.Writer, log *Changelog) {
+	if log.Empty() {
+		fmt.Fprintf(w, "Already up to date (%s)\n", shortHash(log.To))
+		return
+	}
+
+	fmt.Fprintf(w, "📜 Changes from %s to %s\n", shortHash(log.From), shortHash(log.To))
+
+	printCommits := func(title string, commits []ChangelogCommit) {
+		if len(commits) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s (%d):\n", title, len(commits))
+		for i, c := range commits {
+			if i == maxChangelogCommits {
+				fmt.Fprintf(w, "   ... and %d more\n", len(commits)-i)
+				break
+			}
+			fmt.Fprintf(w, "   %s %s %-16s %s\n", shortHash(c.Hash), c.Time.Format("2006-01-02"), truncate(c.Author, 16), c.Summary)
+		}
+	}
+	printCommits("New commits", log.Added)
+	printCommits("Commits that will no longer be installed", log.Removed)
+
+	if len(log.Files) > 0 {
+		fmt.Fprintf(w, "\nFiles changed (%d):\n", len(log.Files))
+		for _, f := range log.Files {
+			line := fmt.Sprintf("   %-8s %s", f.Action, f.Path)
+			if note := fileNote(f.Path); note != "" {
+				line += "  ⚠️  " + note
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
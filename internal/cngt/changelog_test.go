@@ -0,0 +1,132 @@
+package cngt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestBuildChangelog(t *testing.T) {
+	dir := t.TempDir()
+	repo := commitFiles(t, dir, map[string]string{
+		"GlyphModder.py":   "print('v1')\n",
+		"requirements.txt": "termcolor\n",
+	})
+	base := headHash(t, repo)
+	ahead := addCommit(t, repo, "GlyphModder.py", "print('v2')\n")
+
+	// A second line of history that also starts at base
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Hash: base}); err != nil {
+		t.Fatal(err)
+	}
+	other := addCommit(t, repo, "requirements.txt", "termcolor\nmido\n")
+
+	for name, tc := range map[string]struct {
+		log            *Changelog
+		added, removed []string
+		files          []FileChange
+	}{
+		"ahead": {
+			log:   mustChangelog(t, repo, base, ahead),
+			added: []string{ahead.String()},
+			files: []FileChange{{Path: "GlyphModder.py", Action: "modified"}},
+		},
+		"behind": {
+			log:     mustChangelog(t, repo, ahead, base),
+			removed: []string{ahead.String()},
+			files:   []FileChange{{Path: "GlyphModder.py", Action: "modified"}},
+		},
+		"diverged": {
+			log:     mustChangelog(t, repo, ahead, other),
+			added:   []string{other.String()},
+			removed: []string{ahead.String()},
+			files:   []FileChange{{Path: "GlyphModder.py", Action: "modified"}, {Path: "requirements.txt", Action: "modified"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := commitHashes(tc.log.Added); !reflect.DeepEqual(got, tc.added) {
+				t.Errorf("added = %v, want %v", got, tc.added)
+			}
+			if got := commitHashes(tc.log.Removed); !reflect.DeepEqual(got, tc.removed) {
+				t.Errorf("removed = %v, want %v", got, tc.removed)
+			}
+			if !reflect.DeepEqual(tc.log.Files, tc.files) {
+				t.Errorf("files = %v, want %v", tc.log.Files, tc.files)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	PrintChangelog(&buf, mustChangelog(t, repo, ahead, other))
+	for _, want := range []string{
+		"Changes from " + ahead.String()[:7] + " to " + other.String()[:7],
+		"New commits (1):",
+		"Commits that will no longer be installed (1):",
+		"update requirements.txt",
+		"GlyphModder.py  ⚠️  glyph script",
+		"requirements.txt  ⚠️  Python dependencies",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("changelog lacks %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	PrintChangelog(&buf, mustChangelog(t, repo, base, base))
+	if !strings.HasPrefix(buf.String(), "Already up to date ("+base.String()[:7]+")") {
+		t.Errorf("unexpected output for an empty changelog: %q", buf.String())
+	}
+}
+
+func TestUpdateDryRun(t *testing.T) {
+	upstream := t.TempDir()
+	repo := commitFiles(t, upstream, map[string]string{"GlyphModder.py": "print('v1')\n"})
+	installed := headHash(t, repo)
+	cfg, checkout := installCheckout(t, upstream)
+
+	latest := addCommit(t, repo, "GlyphTranslator.py", "print('translator')\n")
+	changelog, err := Update(UpdateOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if changelog.From != installed.String() || changelog.To != latest.String() || len(changelog.Added) != 1 {
+		t.Errorf("unexpected changelog %+v", changelog)
+	}
+
+	if headHash(t, checkout) != installed {
+		t.Errorf("HEAD moved to %s", headHash(t, checkout))
+	}
+	if _, err := os.Stat(filepath.Join(cfg.CNGTPath, "GlyphTranslator.py")); !os.IsNotExist(err) {
+		t.Error("dry run changed the worktree")
+	}
+	if entries, _ := History(); len(entries) != 0 {
+		t.Errorf("dry run was recorded: %+v", entries)
+	}
+}
+
+func mustChangelog(t *testing.T, repo *git.Repository, from, to plumbing.Hash) *Changelog {
+	t.Helper()
+	log, err := buildChangelog(repo, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+func commitHashes(commits []ChangelogCommit) []string {
+	var hashes []string
+	for _, c := range commits {
+		hashes = append(hashes, c.Hash)
+	}
+	return hashes
+}
@@ -0,0 +1,107 @@
+package cngt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/snupai/cngt-cli/internal/deps"
+)
+
+// uvProjectFiles are written next to the pyproject.toml by 'uv init' and
+// 'uv add'
+var uvProjectFiles = []string{"uv.lock", ".python-version"}
+
+// uvHelloScripts are the sample scripts of 'uv init', main.py in newer
+// releases and hello.py in older ones
+var uvHelloScripts = []string{"main.py", "hello.py"}
+
+// RemoveGeneratedFiles deletes the uv project that older releases created
+// inside the checkout by running 'uv init' and 'uv add' there. Only files
+// that are not part of HEAD are touched, and only when the pyproject.toml
+// is still the one from 'uv init'.
+func RemoveGeneratedFiles(path string) error {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	return removeGeneratedFiles(repo)
+}
+
+func removeGeneratedFiles(repo *git.Repository) error {
+	generated, err := generatedFiles(repo)
+	if err != nil || len(generated) == 0 {
+		return err
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	for _, name := range generated {
+		if err := os.Remove(filepath.Join(w.Filesystem.Root(), name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	fmt.Printf("🧹 Removed %s, created in the checkout by an older cngt-cli\n", strings.Join(generated, ", "))
+	return nil
+}
+
+// generatedFiles lists the files of a uv project created in the checkout
+func generatedFiles(repo *git.Repository) ([]string, error) {
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	root := w.Filesystem.Root()
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree: %w", err)
+	}
+	untracked := func(name string) bool {
+		if _, err := tree.File(name); err == nil {
+			return false
+		}
+		_, err := os.Lstat(filepath.Join(root, name))
+		return err == nil
+	}
+
+	if !untracked("pyproject.toml") {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(root, "pyproject.toml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pyproject.toml: %w", err)
+	}
+	if !deps.GeneratedPyproject(data) {
+		return nil, nil
+	}
+
+	generated := []string{"pyproject.toml"}
+	for _, name := range uvProjectFiles {
+		if untracked(name) {
+			generated = append(generated, name)
+		}
+	}
+	for _, name := range uvHelloScripts {
+		if !untracked(name) {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(root, name)); err == nil && bytes.Contains(data, []byte(`print("Hello from `)) {
+			generated = append(generated, name)
+		}
+	}
+	return generated, nil
+}
@@ -0,0 +1,102 @@
+package cngt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snupai/cngt-cli/internal/deps"
+)
+
+// uvInitFiles is what 'uv init --no-readme' and 'uv add -r requirements.txt'
+// left in the checkout
+var uvInitFiles = map[string]string{
+	"pyproject.toml": `[project]
+name = "cngt"
+version = "0.1.0"
+description = "Add your description here"
+requires-python = ">=3.12"
+dependencies = [
+    "termcolor>=2.4.0",
+    "pyyaml>=6.0.1",
+]
+`,
+	"uv.lock":         "version = 1\nrequires-python = \">=3.12\"\n",
+	".python-version": "3.12\n",
+	"main.py":         "def main():\n    print(\"Hello from cngt!\")\n\n\nif __name__ == \"__main__\":\n    main()\n",
+}
+
+func TestRemoveGeneratedFiles(t *testing.T) {
+	dir := t.TempDir()
+	repo := commitFiles(t, dir, map[string]string{
+		"GlyphModder.py":   "print('modder')\n",
+		"requirements.txt": "termcolor\n",
+	})
+	for name, content := range uvInitFiles {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The generated project is neither a requirement source nor reported
+	reqs, err := deps.LoadRequirements(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqs.Source != deps.RequirementsFile || len(reqs.List) != 1 {
+		t.Errorf("got requirements %v from %s", reqs.Strings(), reqs.Source)
+	}
+	result, err := verifyCheckout(repo, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Untracked) != 1 || result.Untracked[0] != "notes.txt" {
+		t.Errorf("got untracked files %v, want only notes.txt", result.Untracked)
+	}
+
+	if err := RemoveGeneratedFiles(dir); err != nil {
+		t.Fatalf("RemoveGeneratedFiles failed: %v", err)
+	}
+	for name := range uvInitFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("notes.txt was removed: %v", err)
+	}
+}
+
+func TestRemoveGeneratedFilesKeepsProjectFiles(t *testing.T) {
+	// A pyproject.toml that upstream ships, even one still carrying the
+	// template description, is left alone together with the files next to it
+	dir := t.TempDir()
+	commitFiles(t, dir, map[string]string{"pyproject.toml": uvInitFiles["pyproject.toml"]})
+	if err := os.WriteFile(filepath.Join(dir, "uv.lock"), []byte(uvInitFiles["uv.lock"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveGeneratedFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pyproject.toml", "uv.lock"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was removed", name)
+		}
+	}
+
+	// An untracked pyproject.toml that was not written by 'uv init'
+	dir = t.TempDir()
+	commitFiles(t, dir, map[string]string{"requirements.txt": "termcolor\n"})
+	if err := os.WriteFile(filepath.Join(dir, "pyproject.toml"), []byte("[project]\nname = \"mine\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveGeneratedFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pyproject.toml")); err != nil {
+		t.Error("pyproject.toml was removed")
+	}
+}
@@ -0,0 +1,174 @@
+package cngt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+const historyFileName = "update_history.json"
+
+// History actions
+const (
+	ActionUpdate   = "update"
+	ActionPin      = "pin"
+	ActionRollback = "rollback"
+)
+
+// HistoryEntry records one change of the installed CNGT commit
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Ref     string    `json:"ref,omitempty"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Summary string    `json:"summary,omitempty"`
+}
+
+// History returns the recorded updates, newest first
+func History() ([]HistoryEntry, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	entries, err := readHistory(historyPath(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Rollback hard-resets the checkout to the commit that was installed before
+// the n-th most recent history entry (1 is the latest update). onDirty is one
+// of the Dirty* actions for local modifications.
+func Rollback(n int, onDirty string) (*HistoryEntry, error) {
+	entries, err := History()
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > len(entries) {
+		return nil, fmt.Errorf("no history entry %d (%d recorded, see 'cngt-cli update --history')", n, len(entries))
+	}
+	target := entries[n-1]
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	repo, err := git.PlainOpen(cfg.CNGTPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	from, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	hash := plumbing.NewHash(target.From)
+	if _, err := repo.CommitObject(hash); err != nil && isShallow(repo) {
+		mirror, _, err := upstream(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := deepen(repo, cfg.RepoRemote, mirror); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := repo.CommitObject(hash); err != nil {
+		return nil, fmt.Errorf("commit %s is no longer available: %w", shortHash(target.From), err)
+	}
+
+	if err := ensureClean(cfg, repo, onDirty); err != nil {
+		return nil, err
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset}); err != nil {
+		return nil, fmt.Errorf("failed to reset worktree: %w", err)
+	}
+
+	entry, err := recordChange(cfg, repo, ActionRollback, "", from.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// recordChange appends a history entry when HEAD moved away from previous.
+// ref names what was checked out and defaults to the current branch.
+func recordChange(cfg *config.Config, repo *git.Repository, action, ref string, previous plumbing.Hash) (*HistoryEntry, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if head.Hash() == previous {
+		return nil, nil
+	}
+
+	entry := HistoryEntry{
+		Time:   time.Now().UTC(),
+		Action: action,
+		From:   previous.String(),
+		To:     head.Hash().String(),
+		Ref:    ref,
+	}
+	if ref == "" && head.Name().IsBranch() {
+		entry.Ref = head.Name().Short()
+	}
+	if commit, err := repo.CommitObject(head.Hash()); err == nil {
+		entry.Summary = strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+	}
+
+	path := historyPath(cfg)
+	entries, err := readHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, entry)
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode update history: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write update history: %w", err)
+	}
+	return &entry, nil
+}
+
+func readHistory(path string) ([]HistoryEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read update history: %w", err)
+	}
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse update history %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+func historyPath(cfg *config.Config) string {
+	return filepath.Join(cfg.StateDir, historyFileName)
+}
@@ -0,0 +1,71 @@
+package cngt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistoryAndRollback(t *testing.T) {
+	upstream := t.TempDir()
+	repo := commitFiles(t, upstream, map[string]string{"GlyphModder.py": "print('v1')\n"})
+	v1 := headHash(t, repo)
+	cfg, checkout := installCheckout(t, upstream)
+
+	v2 := addCommit(t, repo, "GlyphModder.py", "print('v2')\n")
+	if _, err := Update(UpdateOptions{}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	v3 := addCommit(t, repo, "GlyphModder.py", "print('v3')\n")
+	if _, err := Update(UpdateOptions{}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// Newest first
+	entries, err := History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d history entries, want 2", len(entries))
+	}
+	for n, want := range []struct{ from, to string }{{v2.String(), v3.String()}, {v1.String(), v2.String()}} {
+		e := entries[n]
+		if e.Action != ActionUpdate || e.From != want.from || e.To != want.to || e.Ref != "master" {
+			t.Errorf("entry %d = %+v, want %s -> %s", n+1, e, want.from[:7], want.to[:7])
+		}
+	}
+	if entries[0].Summary != "update GlyphModder.py" {
+		t.Errorf("summary = %q", entries[0].Summary)
+	}
+
+	if _, err := Rollback(3, DirtyAbort); err == nil || !strings.Contains(err.Error(), "no history entry 3 (2 recorded") {
+		t.Errorf("got %v for an entry out of range", err)
+	}
+	if _, err := Rollback(0, DirtyAbort); err == nil {
+		t.Error("expected an error for entry 0")
+	}
+
+	// Entry 2 was installed on top of v1
+	entry, err := Rollback(2, DirtyAbort)
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if headHash(t, checkout) != v1 {
+		t.Errorf("HEAD = %s, want %s", headHash(t, checkout), v1)
+	}
+	if entry.Action != ActionRollback || entry.From != v3.String() || entry.To != v1.String() {
+		t.Errorf("unexpected rollback entry %+v", entry)
+	}
+	if entries, _ := History(); len(entries) != 3 || entries[0].Action != ActionRollback {
+		t.Errorf("rollback not recorded first: %+v", entries)
+	}
+
+	// Nothing is recorded when HEAD did not move
+	entry, err = recordChange(cfg, checkout, ActionUpdate, "", v1)
+	if err != nil || entry != nil {
+		t.Errorf("recordChange = %+v, %v, want nothing", entry, err)
+	}
+	if entries, _ := History(); len(entries) != 3 {
+		t.Errorf("got %d history entries after an unchanged HEAD, want 3", len(entries))
+	}
+}
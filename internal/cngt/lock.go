@@ -0,0 +1,130 @@
+package cngt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"gopkg.in/yaml.v3"
+)
+
+// LockFileName is the lockfile searched for from the working directory upwards
+const LockFileName = "cngt.lock"
+
+// minCommitLength is the shortest abbreviated commit a lockfile may pin
+const minCommitLength = 7
+
+// Lock pins a composition project to an exact CNGT commit
+type Lock struct {
+	Commit  string `yaml:"commit"`
+	Ref     string `yaml:"ref,omitempty"`
+	RepoURL string `yaml:"repo_url,omitempty"`
+}
+
+// FindLock looks for cngt.lock in dir and its parents. It returns an empty
+// path and a nil lock when there is none.
+func FindLock(dir string) (string, *Lock, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, LockFileName)
+		if _, err := os.Stat(path); err == nil {
+			lock, err := ReadLock(path)
+			return path, lock, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil, nil
+		}
+		dir = parent
+	}
+}
+
+func ReadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+
+	var lock Lock
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile %s: %w", path, err)
+	}
+	if lock.Commit == "" {
+		return nil, fmt.Errorf("lockfile %s does not contain a commit", path)
+	}
+	if !validCommit(lock.Commit) {
+		return nil, fmt.Errorf("lockfile %s pins %q, want a commit hash of %d to 40 hex digits", path, lock.Commit, minCommitLength)
+	}
+	return &lock, nil
+}
+
+func WriteLock(path string, lock *Lock) error {
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to encode lockfile: %w", err)
+	}
+
+	header := "# Generated by cngt-cli, pins the custom-nothing-glyph-tools commit\n"
+	if err := os.WriteFile(path, append([]byte(header), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	return nil
+}
+
+// LockFromCheckout builds a lock for the commit currently checked out at path
+func LockFromCheckout(path, remoteName string) (*Lock, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	lock := &Lock{Commit: head.Hash().String()}
+
+	if head.Name().IsBranch() {
+		lock.Ref = head.Name().Short()
+	} else if tags, err := repo.Tags(); err == nil {
+		// Prefer a tag name for detached checkouts created by 'update --to'
+		tags.ForEach(func(ref *plumbing.Reference) error {
+			if hash, err := repo.ResolveRevision(plumbing.Revision(ref.Name().String())); err == nil && *hash == head.Hash() {
+				lock.Ref = ref.Name().Short()
+			}
+			return nil
+		})
+	}
+
+	if remote, err := repo.Remote(remoteName); err == nil && len(remote.Config().URLs) > 0 {
+		lock.RepoURL = remote.Config().URLs[0]
+	}
+
+	return lock, nil
+}
+
+// Matches reports whether the lock pins the given commit hash
+func (l *Lock) Matches(commit string) bool {
+	return commit != "" && validCommit(l.Commit) && strings.HasPrefix(commit, strings.ToLower(l.Commit))
+}
+
+func validCommit(commit string) bool {
+	if len(commit) < minCommitLength || len(commit) > 40 {
+		return false
+	}
+	for _, c := range strings.ToLower(commit) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package cngt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindLock(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "songs", "ringtone")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path, lock, err := FindLock(nested)
+	if err != nil || lock != nil || path != "" {
+		t.Fatalf("expected no lock, got %q %v %v", path, lock, err)
+	}
+
+	want := &Lock{Commit: "3975090369b718044b179ed3604f5b2e429de188", Ref: "v1.5"}
+	if err := WriteLock(filepath.Join(root, LockFileName), want); err != nil {
+		t.Fatalf("WriteLock failed: %v", err)
+	}
+
+	path, lock, err = FindLock(nested)
+	if err != nil {
+		t.Fatalf("FindLock failed: %v", err)
+	}
+	if path != filepath.Join(root, LockFileName) {
+		t.Errorf("unexpected lock path %s", path)
+	}
+	if lock == nil || *lock != *want {
+		t.Errorf("got %+v, want %+v", lock, want)
+	}
+}
+
+func TestLockMatches(t *testing.T) {
+	lock := &Lock{Commit: "3975090"}
+
+	if !lock.Matches("3975090369b718044b179ed3604f5b2e429de188") {
+		t.Error("abbreviated lock commit should match the full hash")
+	}
+	if lock.Matches("774fc184d613de41251e2788cb2424083a79cfdf") {
+		t.Error("different commit should not match")
+	}
+	if lock.Matches("") {
+		t.Error("unknown commit should not match")
+	}
+	if (&Lock{Commit: "3"}).Matches("3975090369b718044b179ed3604f5b2e429de188") {
+		t.Error("a one-character commit should not match")
+	}
+}
+
+func TestReadLockRequiresCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+	if err := os.WriteFile(path, []byte("ref: main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadLock(path); err == nil {
+		t.Error("expected an error for a lockfile without commit")
+	}
+}
+
+func TestReadLockRejectsShortCommits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+	for _, commit := range []string{"3", "397509", "3975090xyz", "3975090369b718044b179ed3604f5b2e429de1880"} {
+		if err := os.WriteFile(path, []byte("commit: "+commit+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadLock(path); err == nil {
+			t.Errorf("expected an error for commit %q", commit)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("commit: 3975090\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadLock(path); err != nil {
+		t.Errorf("abbreviated commit was rejected: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package cngt
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,18 +9,22 @@ import (
 	"strings"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/snupai/cngt-cli/internal/cngt/progress"
 	"github.com/snupai/cngt-cli/internal/config"
 	"github.com/snupai/cngt-cli/internal/deps"
-)
-
-const (
-	repoURL = "https://github.com/SebiAi/custom-nothing-glyph-tools.git"
+	"github.com/snupai/cngt-cli/internal/prompt"
 )
 
 type Status struct {
 	RepoStatus    string
 	PythonStatus  string
 	DepsStatus    string
+	// Packages are the required Python packages and their installed
+	// versions, nil when the environment could not be queried
+	Packages []deps.Package
+	// Commit is the full hash of the checked out commit, if known
+	Commit string
 }
 
 func IsInstalled(path string) bool {
@@ -27,78 +32,152 @@ func IsInstalled(path string) bool {
 	return err == nil
 }
 
-func Install(path string) error {
+// InstallOptions controls where the CNGT repository is cloned from
+type InstallOptions struct {
+	URL        string
+	RemoteName string
+	// Branch to check out, empty for the remote's default branch
+	Branch string
+	// Depth limits the cloned history to that many commits, 0 clones everything
+	Depth int
+}
+
+// InstallOptionsFromConfig returns the install options configured by the user
+func InstallOptionsFromConfig(cfg *config.Config) InstallOptions {
+	return InstallOptions{
+		URL:        cfg.CloneURL(),
+		RemoteName: cfg.RepoRemote,
+		Branch:     cfg.RepoBranch,
+		Depth:      cfg.CloneDepth,
+	}
+}
+
+func Install(path string, opts InstallOptions) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	_, err := git.PlainClone(path, false, &git.CloneOptions{
-		URL:      repoURL,
-		Progress: os.Stdout,
-	})
+	p := progress.FromConfig()
+	cloneOpts := &git.CloneOptions{
+		URL:        opts.URL,
+		RemoteName: opts.RemoteName,
+		Progress:   p.Writer("clone"),
+	}
+	if opts.Branch != "" {
+		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(opts.Branch)
+	}
+	if opts.Depth > 0 {
+		// Like 'git clone --depth', tags and other branches are fetched on demand
+		cloneOpts.Depth = opts.Depth
+		cloneOpts.SingleBranch = true
+		cloneOpts.Tags = git.NoTags
+	}
+
+	p.Start("clone", fmt.Sprintf("Cloning %s", opts.URL))
+	_, err := git.PlainClone(path, false, cloneOpts)
 	if err != nil {
-		return fmt.Errorf("failed to clone repository: %w", err)
+		err = fmt.Errorf("failed to clone repository: %w", err)
+		p.Done("clone", err)
+		return err
 	}
+	p.Done("clone", nil)
 
 	return nil
 }
 
-func Update() error {
+// UpdateOptions controls what Update moves the checkout to
+type UpdateOptions struct {
+	// To pins the checkout to a tag or commit instead of the branch head
+	To string
+	// DryRun only fetches and prints the changelog without touching the worktree
+	DryRun bool
+	// OnDirty is one of the Dirty* actions for local modifications
+	OnDirty string
+}
+
+// Update fetches from the configured remote, prints the upstream changelog
+// and then moves the checkout to the branch head or to opts.To.
+func Update(opts UpdateOptions) (*Changelog, error) {
 	cfg, err := config.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	repo, err := git.PlainOpen(cfg.CNGTPath)
 	if err != nil {
-		return fmt.Errorf("failed to open repository: %w", err)
+		return nil, fmt.Errorf("failed to open repository: %w", err)
 	}
 
-	w, err := repo.Worktree()
+	head, err := repo.Head()
 	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
 	}
 
-	err = w.Pull(&git.PullOptions{
-		RemoteName: "origin",
-		Progress:   os.Stdout,
-	})
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return fmt.Errorf("failed to pull updates: %w", err)
+	mirror, branch, err := upstream(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if mirror != "" {
+		fmt.Printf("🪞 Fetching from mirror %s\n", mirror)
 	}
 
-	return nil
-}
+	var target plumbing.Hash
+	if opts.To != "" {
+		if target, err = fetchRevision(repo, cfg.RepoRemote, mirror, opts.To); err != nil {
+			return nil, err
+		}
+	} else {
+		if branch, err = resolveBranch(repo, cfg.RepoRemote, mirror, branch); err != nil {
+			return nil, err
+		}
+		if target, err = fetchBranch(repo, cfg.RepoRemote, mirror, branch); err != nil {
+			return nil, err
+		}
+	}
 
-func RunScript(scriptName string, args []string) error {
-	cfg, err := config.Load()
+	changelog, err := buildChangelog(repo, head.Hash(), target)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return nil, err
 	}
+	PrintChangelog(os.Stdout, changelog)
 
-	scriptPath := filepath.Join(cfg.CNGTPath, scriptName)
-	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-		return fmt.Errorf("script %s not found", scriptName)
+	if opts.DryRun {
+		return changelog, nil
 	}
 
-	// Check if uv is available and there's a pyproject.toml
-	var cmd *exec.Cmd
-	if isUvAvailable() && hasUvProject(cfg.CNGTPath) {
-		// Use uv run to execute the script with the proper environment
-		cmdArgs := append([]string{"run", "python", scriptPath}, args...)
-		cmd = exec.Command("uv", cmdArgs...)
-	} else {
-		// Find the best Python command
-		pythonCmd := findPythonCommand()
-		if pythonCmd == "" {
-			return fmt.Errorf("Python is not installed or not found in PATH")
+	if err := removeGeneratedFiles(repo); err != nil {
+		return nil, err
+	}
+	if err := ensureClean(cfg, repo, opts.OnDirty); err != nil {
+		return nil, err
+	}
+
+	if opts.To != "" {
+		w, err := repo.Worktree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get worktree: %w", err)
+		}
+		if err := w.Checkout(&git.CheckoutOptions{Hash: target}); err != nil {
+			return nil, fmt.Errorf("failed to check out %s: %w", opts.To, err)
 		}
-		
-		cmdArgs := append([]string{scriptPath}, args...)
-		cmd = exec.Command(pythonCmd, cmdArgs...)
+		_, err = recordChange(cfg, repo, ActionPin, opts.To, head.Hash())
+		return changelog, err
 	}
-	
-	cmd.Dir = cfg.CNGTPath
+
+	if err := fastForward(repo, cfg.RepoRemote, branch, target); err != nil {
+		return nil, err
+	}
+	_, err = recordChange(cfg, repo, ActionUpdate, "", head.Hash())
+	return changelog, err
+}
+
+func RunScript(scriptName string, args []string) error {
+	script, err := PrepareScript(scriptName)
+	if err != nil {
+		return err
+	}
+
+	cmd := script.Command(context.Background(), args)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -106,26 +185,115 @@ func RunScript(scriptName string, args []string) error {
 	return cmd.Run()
 }
 
-func isUvAvailable() bool {
-	cmd := exec.Command("uv", "--version")
-	return cmd.Run() == nil
+// Script is a CNGT script whose checkout has been checked, ready to be run
+// any number of times
+type Script struct {
+	Name string
+	// Commit is the checked out CNGT commit, empty when it is unknown
+	Commit string
+	path   string
+	dir    string
+	// python is the interpreter of the managed environment
+	python string
 }
 
-func hasUvProject(path string) bool {
-	_, err := os.Stat(filepath.Join(path, "pyproject.toml"))
-	return err == nil
-}
+// PrepareScript checks the checkout against cngt.lock and its commit and
+// finds the interpreter for a script
+func PrepareScript(scriptName string) (*Script, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
 
-func findPythonCommand() string {
-	pythonCommands := []string{"python", "python3", "py"}
-	
-	for _, pythonCmd := range pythonCommands {
-		cmd := exec.Command(pythonCmd, "--version")
-		if cmd.Run() == nil {
-			return pythonCmd
+	scriptPath := filepath.Join(cfg.CNGTPath, scriptName)
+	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("script %s not found", scriptName)
+	}
+
+	if err := checkLock(cfg); err != nil {
+		return nil, err
+	}
+	if err := checkCheckout(cfg); err != nil {
+		return nil, err
+	}
+
+	script := &Script{Name: scriptName, path: scriptPath, dir: cfg.CNGTPath}
+	if repo, err := git.PlainOpen(cfg.CNGTPath); err == nil {
+		if head, err := repo.Head(); err == nil {
+			script.Commit = head.Hash().String()
 		}
 	}
-	return ""
+	if !deps.HasVenv(cfg.VenvPath) {
+		return nil, fmt.Errorf("no Python environment at %s, run 'cngt-cli setup' to create it", cfg.VenvPath)
+	}
+	script.python = deps.VenvPython(cfg.VenvPath)
+	return script, nil
+}
+
+// Command returns the command that runs the script with args in the
+// checkout. Its standard streams are left for the caller to connect.
+func (s *Script) Command(ctx context.Context, args []string) *exec.Cmd {
+	cmdArgs := append([]string{s.path}, args...)
+	cmd := exec.CommandContext(ctx, s.python, cmdArgs...)
+	cmd.Dir = s.dir
+	return cmd
+}
+
+// checkLock makes sure the checkout matches the nearest cngt.lock, offering
+// to switch to the locked commit when it does not.
+func checkLock(cfg *config.Config) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+
+	lockPath, lock, err := FindLock(cwd)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return nil
+	}
+
+	repo, err := git.PlainOpen(cfg.CNGTPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if lock.Matches(head.Hash().String()) {
+		return nil
+	}
+
+	fmt.Printf("⚠️  The CNGT checkout (%s) does not match %s (%s).\n", head.Hash().String()[:7], lockPath, shortHash(lock.Commit))
+	if !prompt.Confirm("Switch the checkout to the locked commit? (y/N): ", false) {
+		return fmt.Errorf("CNGT checkout does not match %s, run 'cngt-cli update --to %s' to switch", lockPath, lock.Commit)
+	}
+
+	if err := ensureClean(cfg, repo, DirtyAsk); err != nil {
+		return err
+	}
+	mirror, _, err := upstream(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := checkoutRevision(repo, cfg.RepoRemote, mirror, lock.Commit); err != nil {
+		return err
+	}
+	if _, err := recordChange(cfg, repo, ActionPin, LockFileName, head.Hash()); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Switched CNGT checkout to %s\n", shortHash(lock.Commit))
+	return nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
 }
 
 func GetStatus() Status {
@@ -150,10 +318,19 @@ func GetStatus() Status {
 				status.RepoStatus = "Installed (unknown commit)"
 			} else {
 				commit, err := repo.CommitObject(ref.Hash())
+				if err == nil {
+					status.Commit = commit.Hash.String()
+				}
+				shallow := ""
+				if isShallow(repo) {
+					shallow = ", shallow clone"
+				}
 				if err != nil {
 					status.RepoStatus = "Installed (unknown commit)"
+				} else if ref.Name().IsBranch() {
+					status.RepoStatus = fmt.Sprintf("Installed (branch: %s, commit: %s%s)", ref.Name().Short(), commit.Hash.String()[:7], shallow)
 				} else {
-					status.RepoStatus = fmt.Sprintf("Installed (commit: %s)", commit.Hash.String()[:7])
+					status.RepoStatus = fmt.Sprintf("Installed (commit: %s%s)", commit.Hash.String()[:7], shallow)
 				}
 			}
 		}
@@ -161,22 +338,25 @@ func GetStatus() Status {
 		status.RepoStatus = "Not installed"
 	}
 
-	// Try different Python command variations
-	pythonCommands := []string{"python", "python3", "py"}
-	status.PythonStatus = "Not found"
+	// Report the interpreter of the managed environment, the one scripts run in
+	status.PythonStatus = "Not set up"
 	
-	for _, pythonCmd := range pythonCommands {
-		cmd := exec.Command(pythonCmd, "--version")
-		if out, err := cmd.Output(); err == nil {
-			status.PythonStatus = strings.TrimSpace(string(out))
-			break
-		}
+	cmd := exec.Command(deps.VenvPython(cfg.VenvPath), "--version")
+	if out, err := cmd.Output(); err == nil {
+		status.PythonStatus = fmt.Sprintf("%s (%s)", strings.TrimSpace(string(out)), cfg.VenvPath)
 	}
 
-	if deps.AreInstalled() {
-		status.DepsStatus = "Installed"
-	} else {
+	if !deps.HasVenv(cfg.VenvPath) {
 		status.DepsStatus = "Missing dependencies"
+	} else if reqs, pkgs, err := deps.CheckEnvironment(cfg); err != nil {
+		status.DepsStatus = fmt.Sprintf("Unknown (%v)", err)
+	} else {
+		status.Packages = pkgs
+		status.DepsStatus = "Installed"
+		if !deps.PackagesOK(pkgs) {
+			status.DepsStatus = "Missing or outdated dependencies"
+		}
+		status.DepsStatus += fmt.Sprintf(" (from %s)", reqs.Source)
 	}
 
 	return status
@@ -0,0 +1,232 @@
+package cngt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+func addCommit(t *testing.T, repo *git.Repository, name, content string) plumbing.Hash {
+	t.Helper()
+
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(w.Filesystem.Root(), name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := w.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func headHash(t *testing.T, repo *git.Repository) plumbing.Hash {
+	t.Helper()
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return head.Hash()
+}
+
+// installCheckout installs a checkout of upstream into a fresh data
+// directory and opens it
+func installCheckout(t *testing.T, upstream string) (*config.Config, *git.Repository) {
+	t.Helper()
+
+	t.Setenv(config.DataDirEnv, t.TempDir())
+	t.Setenv("CNGT_REPO_URL", upstream)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(cfg.CNGTPath, InstallOptionsFromConfig(cfg)); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	checkout, err := git.PlainOpen(cfg.CNGTPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg, checkout
+}
+
+func TestInstallFromMirror(t *testing.T) {
+	upstream := t.TempDir()
+	repo := commitFiles(t, upstream, map[string]string{"GlyphModder.py": "print('modder')\n"})
+	want := headHash(t, repo)
+
+	for name, mirror := range map[string]string{
+		"path":     upstream,
+		"file URL": "file://" + filepath.ToSlash(upstream),
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(config.DataDirEnv, t.TempDir())
+			t.Setenv("CNGT_REPO_MIRROR", mirror)
+
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Install(cfg.CNGTPath, InstallOptionsFromConfig(cfg)); err != nil {
+				t.Fatalf("Install failed: %v", err)
+			}
+			if !IsInstalled(cfg.CNGTPath) {
+				t.Fatal("checkout not installed")
+			}
+
+			status := GetStatus()
+			if status.Commit != want.String() {
+				t.Errorf("status commit = %s, want %s", status.Commit, want)
+			}
+			if !strings.Contains(status.RepoStatus, "branch: master") {
+				t.Errorf("unexpected repo status %q", status.RepoStatus)
+			}
+		})
+	}
+}
+
+func TestUpdateFromMirror(t *testing.T) {
+	upstream := t.TempDir()
+	commitFiles(t, upstream, map[string]string{"GlyphModder.py": "print('modder')\n"})
+
+	// The mirror is ahead of the URL the checkout was cloned from
+	mirrorDir := t.TempDir()
+	mirror, err := git.PlainClone(mirrorDir, false, &git.CloneOptions{URL: upstream})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := addCommit(t, mirror, "GlyphTranslator.py", "print('translator')\n")
+
+	t.Setenv(config.DataDirEnv, t.TempDir())
+	t.Setenv("CNGT_REPO_URL", upstream)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(cfg.CNGTPath, InstallOptionsFromConfig(cfg)); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	// Without a mirror there is nothing new upstream
+	changelog, err := Update(UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if !changelog.Empty() {
+		t.Fatalf("expected no changes, got %+v", changelog)
+	}
+
+	t.Setenv("CNGT_REPO_MIRROR", mirrorDir)
+	changelog, err = Update(UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update from mirror failed: %v", err)
+	}
+	if len(changelog.Added) != 1 || changelog.To != want.String() {
+		t.Errorf("unexpected changelog %+v", changelog)
+	}
+
+	checkout, err := git.PlainOpen(cfg.CNGTPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := headHash(t, checkout); got != want {
+		t.Errorf("HEAD = %s, want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.CNGTPath, "GlyphTranslator.py")); err != nil {
+		t.Errorf("worktree not updated: %v", err)
+	}
+
+	// The mirror is used for fetching only, the checkout keeps its remote
+	remotes, err := ListRemotes(cfg.CNGTPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remotes) != 1 || remotes[0].URLs[0] != upstream {
+		t.Errorf("remote changed to %+v", remotes)
+	}
+
+	entries, err := History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].To != want.String() {
+		t.Errorf("unexpected history %+v", entries)
+	}
+}
+
+func TestShallowClone(t *testing.T) {
+	upstream := t.TempDir()
+	repo := commitFiles(t, upstream, map[string]string{"GlyphModder.py": "print('v1')\n"})
+	first := headHash(t, repo)
+	addCommit(t, repo, "GlyphModder.py", "print('v2')\n")
+	addCommit(t, repo, "GlyphModder.py", "print('v3')\n")
+
+	t.Setenv(config.DataDirEnv, t.TempDir())
+	t.Setenv("CNGT_REPO_URL", upstream)
+	t.Setenv("CNGT_CLONE_DEPTH", "1")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(cfg.CNGTPath, InstallOptionsFromConfig(cfg)); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	checkout, err := git.PlainOpen(cfg.CNGTPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isShallow(checkout) {
+		t.Fatal("expected a shallow clone")
+	}
+	if _, err := checkout.CommitObject(first); err == nil {
+		t.Fatal("shallow clone contains the full history")
+	}
+	if status := GetStatus(); !strings.Contains(status.RepoStatus, "shallow") {
+		t.Errorf("unexpected repo status %q", status.RepoStatus)
+	}
+
+	latest := addCommit(t, repo, "GlyphModder.py", "print('v4')\n")
+	changelog, err := Update(UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if len(changelog.Added) != 1 || headHash(t, checkout) != latest {
+		t.Fatalf("unexpected changelog %+v", changelog)
+	}
+
+	// Pinning a commit outside of the fetched history deepens the clone
+	if _, err := Update(UpdateOptions{To: first.String()}); err != nil {
+		t.Fatalf("Update --to failed: %v", err)
+	}
+	if headHash(t, checkout) != first {
+		t.Errorf("HEAD = %s, want %s", headHash(t, checkout), first)
+	}
+	if isShallow(checkout) {
+		t.Error("clone should have been deepened")
+	}
+
+	if _, err := Rollback(1, DirtyAbort); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if headHash(t, checkout) != latest {
+		t.Errorf("HEAD = %s, want %s after rollback", headHash(t, checkout), latest)
+	}
+}
@@ -0,0 +1,580 @@
+package cngt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/snupai/cngt-cli/internal/config"
+	"github.com/snupai/cngt-cli/internal/prompt"
+)
+
+const patchesDirName = "patches"
+
+// What to do with local modifications before the checkout is moved
+const (
+	DirtyAsk     = ""
+	DirtyStash   = "stash"
+	DirtyDiscard = "discard"
+	DirtyAbort   = "abort"
+)
+
+// modifiedFiles returns the tracked files that differ from HEAD. Untracked
+// files are ignored since moving the checkout leaves them alone.
+func modifiedFiles(repo *git.Repository) ([]string, error) {
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read worktree status: %w", err)
+	}
+
+	var files []string
+	for path, s := range status {
+		if s.Worktree == git.Untracked && s.Staging == git.Untracked {
+			continue
+		}
+		if s.Worktree != git.Unmodified || s.Staging != git.Unmodified {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ensureClean handles local modifications before the checkout is moved,
+// asking the user what to do unless action says otherwise.
+func ensureClean(cfg *config.Config, repo *git.Repository, action string) error {
+	files, err := modifiedFiles(repo)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	if action == DirtyAsk {
+		fmt.Println("⚠️  The CNGT checkout has local modifications:")
+		for _, f := range files {
+			fmt.Printf("   %s\n", f)
+		}
+		action = prompt.Choose("[s]tash them as a patch, [d]iscard them or [a]bort? (s/d/A): ", DirtyAbort, DirtyStash, DirtyDiscard, DirtyAbort)
+	}
+
+	switch action {
+	case DirtyStash:
+		path, err := stashChanges(cfg, repo, files)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("💾 Saved local modifications to %s\n", path)
+		fmt.Println("   Re-apply them with 'cngt-cli repo restore-patch'")
+	case DirtyDiscard:
+		fmt.Println("🗑️  Discarding local modifications")
+	default:
+		return fmt.Errorf("the CNGT checkout has local modifications (%s), use --stash or --discard", strings.Join(files, ", "))
+	}
+
+	return resetToHead(repo)
+}
+
+func resetToHead(repo *git.Repository) error {
+	w, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := w.Reset(&git.ResetOptions{Mode: git.HardReset}); err != nil {
+		return fmt.Errorf("failed to reset worktree: %w", err)
+	}
+	return nil
+}
+
+// stashChanges writes the modifications of files as a unified diff into the
+// patches directory and returns its path.
+func stashChanges(cfg *config.Config, repo *git.Repository, files []string) (string, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", fmt.Errorf("failed to read tree: %w", err)
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	p := &patch{message: fmt.Sprintf("Local CNGT modifications on top of %s", head.Hash())}
+	for _, name := range files {
+		fp, err := diffFile(tree, w.Filesystem.Root(), name)
+		if err != nil {
+			return "", err
+		}
+		if fp.IsBinary() {
+			return "", fmt.Errorf("cannot stash binary file %s", name)
+		}
+		p.files = append(p.files, fp)
+	}
+
+	var buf bytes.Buffer
+	if err := fdiff.NewUnifiedEncoder(&buf, fdiff.DefaultContextLines).Encode(p); err != nil {
+		return "", fmt.Errorf("failed to encode patch: %w", err)
+	}
+
+	dir := filepath.Join(cfg.DataDir, patchesDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create patches directory: %w", err)
+	}
+	name := fmt.Sprintf("%s-%s.patch", time.Now().Format("20060102-150405"), head.Hash().String()[:7])
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write patch: %w", err)
+	}
+	return path, nil
+}
+
+func diffFile(tree *object.Tree, root, name string) (*filePatch, error) {
+	fp := &filePatch{}
+
+	var oldContent, newContent string
+	if f, err := tree.File(name); err == nil {
+		if oldContent, err = f.Contents(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if fp.binary, err = f.IsBinary(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		fp.from = &patchFile{path: name, mode: f.Mode, hash: f.Hash}
+	}
+
+	full := filepath.Join(root, filepath.FromSlash(name))
+	data, err := os.ReadFile(full)
+	if err == nil {
+		newContent = string(data)
+		fp.to = &patchFile{
+			path: name,
+			mode: filemode.Regular,
+			hash: plumbing.ComputeHash(plumbing.BlobObject, data),
+		}
+		if info, err := os.Stat(full); err == nil && info.Mode()&0111 != 0 {
+			fp.to.mode = filemode.Executable
+		}
+		if bytes.IndexByte(data, 0) >= 0 {
+			fp.binary = true
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	for _, d := range diff.Do(oldContent, newContent) {
+		c := &patchChunk{content: d.Text}
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			c.op = fdiff.Add
+		case diffmatchpatch.DiffDelete:
+			c.op = fdiff.Delete
+		default:
+			c.op = fdiff.Equal
+		}
+		fp.chunks = append(fp.chunks, c)
+	}
+	return fp, nil
+}
+
+// ListPatches returns the saved patches, newest first
+func ListPatches() ([]string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(cfg.DataDir, patchesDirName, "*.patch"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+	return matches, nil
+}
+
+// RestorePatch applies a saved patch to the checkout. An empty name picks
+// the newest patch; otherwise name may be a file name in the patches
+// directory or a path. It returns the path of the applied patch.
+func RestorePatch(name string) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+
+	path := name
+	if name == "" {
+		patches, err := ListPatches()
+		if err != nil {
+			return "", err
+		}
+		if len(patches) == 0 {
+			return "", fmt.Errorf("no saved patches in %s", filepath.Join(cfg.DataDir, patchesDirName))
+		}
+		path = patches[0]
+	} else if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(cfg.DataDir, patchesDirName, name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read patch: %w", err)
+	}
+	if err := applyPatch(cfg.CNGTPath, data); err != nil {
+		return "", fmt.Errorf("failed to apply %s: %w", filepath.Base(path), err)
+	}
+	return path, nil
+}
+
+type patchHunk struct {
+	oldStart int
+	lines    []string // prefixed with ' ', '-' or '+'
+	// noNewline marks that the old/new side does not end with a newline
+	oldNoNewline bool
+	newNoNewline bool
+}
+
+type parsedFilePatch struct {
+	oldPath string      // empty for new files
+	newPath string      // empty for deleted files
+	mode    os.FileMode // zero unless the patch sets the new mode
+	hunks   []patchHunk
+}
+
+// parsePatch reads a unified diff as written by stashChanges (or git diff)
+func parsePatch(data []byte) ([]parsedFilePatch, error) {
+	var files []parsedFilePatch
+	var cur *parsedFilePatch
+	var hunk *patchHunk
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files = append(files, parsedFilePatch{})
+			cur = &files[len(files)-1]
+			hunk = nil
+		case cur == nil:
+			// Message before the first file
+		case hunk == nil && (strings.HasPrefix(line, "new file mode ") || strings.HasPrefix(line, "new mode ")):
+			m, err := filemode.New(line[strings.LastIndexByte(line, ' ')+1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if cur.mode, err = m.ToOSFileMode(); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		case hunk == nil && strings.HasPrefix(line, "--- "):
+			cur.oldPath = patchPath(line[4:], "a/")
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			cur.newPath = patchPath(line[4:], "b/")
+		case strings.HasPrefix(line, "@@ "):
+			start, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			cur.hunks = append(cur.hunks, patchHunk{oldStart: start})
+			hunk = &cur.hunks[len(cur.hunks)-1]
+		case hunk != nil && strings.HasPrefix(line, `\ No newline at end of file`):
+			if len(hunk.lines) > 0 {
+				switch hunk.lines[len(hunk.lines)-1][0] {
+				case '-':
+					hunk.oldNoNewline = true
+				case '+':
+					hunk.newNoNewline = true
+				default:
+					hunk.oldNoNewline = true
+					hunk.newNoNewline = true
+				}
+			}
+		case hunk != nil && line == "":
+			hunk.lines = append(hunk.lines, " ")
+		case hunk != nil && (line[0] == ' ' || line[0] == '-' || line[0] == '+'):
+			hunk.lines = append(hunk.lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if f.oldPath == "" && f.newPath == "" {
+			return nil, fmt.Errorf("patch contains a file without content changes")
+		}
+	}
+	return files, nil
+}
+
+func patchPath(s, prefix string) string {
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+func parseHunkHeader(line string) (int, error) {
+	// @@ -l[,s] +l[,s] @@
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") {
+		return 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+	start, err := strconv.Atoi(strings.SplitN(fields[1][1:], ",", 2)[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+	return start, nil
+}
+
+// applyPatch applies a unified diff below root. All files are checked
+// before anything is written so a conflicting patch leaves root untouched.
+func applyPatch(root string, data []byte) error {
+	files, err := parsePatch(data)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("patch is empty")
+	}
+
+	type result struct {
+		path    string // resolved below root
+		content []byte
+		mode    os.FileMode
+		remove  bool
+	}
+	var results []result
+
+	for _, f := range files {
+		oldFull, err := patchTarget(root, f.oldPath)
+		if err != nil {
+			return err
+		}
+		newFull, err := patchTarget(root, f.newPath)
+		if err != nil {
+			return err
+		}
+
+		var lines []string
+		noNewline := false
+		mode := os.FileMode(0644)
+		if f.oldPath != "" {
+			data, err := os.ReadFile(oldFull)
+			if err != nil {
+				return fmt.Errorf("%s: %w", f.oldPath, err)
+			}
+			lines, noNewline = splitLines(string(data))
+			if info, err := os.Stat(oldFull); err == nil {
+				mode = info.Mode().Perm()
+			}
+		} else if _, err := os.Stat(newFull); err == nil {
+			return fmt.Errorf("%s: already exists", f.newPath)
+		}
+
+		offset := 0
+		for i, h := range f.hunks {
+			var oldLines, newLines []string
+			for _, l := range h.lines {
+				switch l[0] {
+				case ' ':
+					oldLines = append(oldLines, l[1:])
+					newLines = append(newLines, l[1:])
+				case '-':
+					oldLines = append(oldLines, l[1:])
+				case '+':
+					newLines = append(newLines, l[1:])
+				}
+			}
+
+			// An empty old range starts after the given line, not at it
+			start := h.oldStart - 1
+			if len(oldLines) == 0 {
+				start = h.oldStart
+			}
+			pos := findLines(lines, oldLines, start+offset)
+			if pos < 0 {
+				name := f.oldPath
+				if name == "" {
+					name = f.newPath
+				}
+				return fmt.Errorf("%s: hunk %d does not apply", name, i+1)
+			}
+
+			atEnd := pos+len(oldLines) == len(lines)
+			rest := append(append([]string{}, newLines...), lines[pos+len(oldLines):]...)
+			lines = append(lines[:pos], rest...)
+			offset += len(newLines) - len(oldLines)
+			if atEnd {
+				noNewline = h.newNoNewline
+			}
+		}
+
+		if f.newPath == "" {
+			results = append(results, result{path: oldFull, remove: true})
+			continue
+		}
+
+		content := strings.Join(lines, "\n")
+		if len(lines) > 0 && !noNewline {
+			content += "\n"
+		}
+		if f.mode != 0 {
+			mode = f.mode.Perm()
+		}
+		results = append(results, result{path: newFull, content: []byte(content), mode: mode})
+	}
+
+	for _, r := range results {
+		full := r.path
+		if r.remove {
+			if err := os.Remove(full); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(full, r.content, r.mode); err != nil {
+			return err
+		}
+		// WriteFile only applies the mode to files it creates
+		if err := os.Chmod(full, r.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchTarget resolves a path from a patch header below root and rejects
+// paths that leave it. An empty name stays empty.
+func patchTarget(root, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	target := filepath.Join(root, filepath.FromSlash(name))
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: path leaves the checkout", name)
+	}
+	return target, nil
+}
+
+// splitLines splits content into lines and reports whether the last line
+// lacks a trailing newline
+func splitLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, false
+	}
+	noNewline := !strings.HasSuffix(content, "\n")
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), noNewline
+}
+
+// findLines searches for want in lines, starting at hint and moving outwards
+func findLines(lines, want []string, hint int) int {
+	matches := func(pos int) bool {
+		if pos < 0 || pos+len(want) > len(lines) {
+			return false
+		}
+		for i := range want {
+			if lines[pos+i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for delta := 0; delta <= len(lines); delta++ {
+		if matches(hint - delta) {
+			return hint - delta
+		}
+		if matches(hint + delta) {
+			return hint + delta
+		}
+	}
+	return -1
+}
+
+// patch, filePatch, patchFile and patchChunk implement go-git's diff
+// interfaces so the unified encoder can write worktree changes.
+type patch struct {
+	message string
+	files   []*filePatch
+}
+
+func (p *patch) FilePatches() []fdiff.FilePatch {
+	fps := make([]fdiff.FilePatch, len(p.files))
+	for i, f := range p.files {
+		fps[i] = f
+	}
+	return fps
+}
+
+func (p *patch) Message() string { return p.message }
+
+type filePatch struct {
+	from, to *patchFile
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+func (f *filePatch) IsBinary() bool { return f.binary }
+
+func (f *filePatch) Files() (fdiff.File, fdiff.File) {
+	var from, to fdiff.File
+	if f.from != nil {
+		from = f.from
+	}
+	if f.to != nil {
+		to = f.to
+	}
+	return from, to
+}
+
+func (f *filePatch) Chunks() []fdiff.Chunk { return f.chunks }
+
+type patchFile struct {
+	path string
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+func (f *patchFile) Hash() plumbing.Hash     { return f.hash }
+func (f *patchFile) Mode() filemode.FileMode { return f.mode }
+func (f *patchFile) Path() string            { return f.path }
+
+type patchChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c *patchChunk) Content() string       { return c.content }
+func (c *patchChunk) Type() fdiff.Operation { return c.op }
@@ -0,0 +1,209 @@
+package cngt
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+func commitFiles(t *testing.T, dir string, files map[string]string) *git.Repository {
+	t.Helper()
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = w.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestStashAndRestorePatch(t *testing.T) {
+	dir := t.TempDir()
+	repo := commitFiles(t, dir, map[string]string{
+		"GlyphModder.py":   "import sys\n\ndef main():\n    pass\n\nmain()\n",
+		"requirements.txt": "termcolor\nmido",
+		"README.md":        "readme\n",
+	})
+
+	edits := map[string]string{
+		"GlyphModder.py":   "import sys\n\ndef main():\n    print('patched')\n\nmain()\n",
+		"requirements.txt": "termcolor\nmido\ncolorama\n",
+	}
+	for name, content := range edits {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	// Untracked files are not local modifications
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := modifiedFiles(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 modified files, got %v", files)
+	}
+
+	cfg := &config.Config{DataDir: t.TempDir(), CNGTPath: dir}
+	path, err := stashChanges(cfg, repo, files)
+	if err != nil {
+		t.Fatalf("stashChanges failed: %v", err)
+	}
+	if err := resetToHead(repo); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := modifiedFiles(repo); len(files) != 0 {
+		t.Fatalf("worktree should be clean after reset, got %v", files)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyPatch(dir, data); err != nil {
+		t.Fatalf("applyPatch failed: %v\n%s", err, data)
+	}
+
+	for name, want := range edits {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "README.md")); !os.IsNotExist(err) {
+		t.Error("README.md should be deleted by the patch")
+	}
+}
+
+func TestApplyPatchConflict(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.py"), []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := "diff --git a/a.py b/a.py\n--- a/a.py\n+++ b/a.py\n@@ -1,3 +1,3 @@\n one\n-zwei\n+2\n three\n"
+	if err := applyPatch(dir, []byte(patch)); err == nil {
+		t.Fatal("expected a conflict")
+	}
+
+	got, _ := os.ReadFile(filepath.Join(dir, "a.py"))
+	if string(got) != "one\ntwo\nthree\n" {
+		t.Errorf("file should be untouched after a conflict, got %q", got)
+	}
+}
+
+func TestPatchKeepsFileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no executable bit on Windows")
+	}
+	dir := t.TempDir()
+	repo := commitFiles(t, dir, map[string]string{"run.sh": "#!/bin/sh\necho one\n"})
+	script := filepath.Join(dir, "run.sh")
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("run.sh"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Commit("executable", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{DataDir: t.TempDir(), CNGTPath: dir}
+	path, err := stashChanges(cfg, repo, []string{"run.sh"})
+	if err != nil {
+		t.Fatalf("stashChanges failed: %v", err)
+	}
+	if err := resetToHead(repo); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := string(data) + "diff --git a/new.sh b/new.sh\nnew file mode 100755\n--- /dev/null\n+++ b/new.sh\n@@ -0,0 +1 @@\n+#!/bin/sh\n"
+	if err := applyPatch(dir, []byte(patch)); err != nil {
+		t.Fatalf("applyPatch failed: %v\n%s", err, patch)
+	}
+
+	for _, name := range []string{"run.sh", "new.sh"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0111 == 0 {
+			t.Errorf("%s lost its executable bit: %v", name, info.Mode())
+		}
+	}
+}
+
+func TestApplyPatchRejectsEscapes(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "checkout")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "victim"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, patch := range []string{
+		"diff --git a/../evil b/../evil\n--- /dev/null\n+++ b/../evil\n@@ -0,0 +1 @@\n+evil\n",
+		"diff --git a/x/../../evil b/x/../../evil\n--- /dev/null\n+++ b/x/../../evil\n@@ -0,0 +1 @@\n+evil\n",
+		"diff --git a/../victim b/../victim\n--- a/../victim\n+++ b/../victim\n@@ -1 +1 @@\n-one\n+two\n",
+		"diff --git a/../victim b/../victim\n--- a/../victim\n+++ /dev/null\n@@ -1 +0,0 @@\n-one\n",
+	} {
+		if err := applyPatch(dir, []byte(patch)); err == nil {
+			t.Errorf("patch was applied:\n%s", patch)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "evil")); !os.IsNotExist(err) {
+		t.Error("patch wrote outside the checkout")
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "victim")); string(got) != "one\n" {
+		t.Errorf("file outside the checkout was changed to %q", got)
+	}
+}
@@ -0,0 +1,173 @@
+// Package progress reports the progress of long running steps such as
+// cloning the CNGT repository or installing Python packages. Events are
+// rendered as a terminal progress bar, plain log lines or JSON.
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+// Output modes, see the progress config key
+const (
+	ModeAuto  = "auto"
+	ModeBar   = "bar"
+	ModePlain = "plain"
+	ModeJSON  = "json"
+)
+
+// Event kinds
+const (
+	KindStart    = "start"
+	KindProgress = "progress"
+	KindLog      = "log"
+	KindDone     = "done"
+)
+
+// Event is a single progress report of a task
+type Event struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Task    string    `json:"task"`
+	Stage   string    `json:"stage,omitempty"`
+	Current int64     `json:"current,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Renderer displays progress events
+type Renderer interface {
+	Render(e Event)
+}
+
+// Reporter sends progress events of one or more tasks to a renderer
+type Reporter struct {
+	mu sync.Mutex
+	r  Renderer
+}
+
+// NewReporter returns a reporter that renders events with r
+func NewReporter(r Renderer) *Reporter {
+	return &Reporter{r: r}
+}
+
+// New returns a reporter for the given mode. Bars and plain lines are
+// written to stdout, JSON events to stderr so they can be captured
+// separately from the other output.
+func New(mode string) (*Reporter, error) {
+	switch mode {
+	case ModeAuto, "":
+		if isTerminal(os.Stdout) {
+			return NewReporter(NewBarRenderer(os.Stdout)), nil
+		}
+		return NewReporter(NewPlainRenderer(os.Stdout)), nil
+	case ModeBar:
+		return NewReporter(NewBarRenderer(os.Stdout)), nil
+	case ModePlain:
+		return NewReporter(NewPlainRenderer(os.Stdout)), nil
+	case ModeJSON:
+		return NewReporter(NewJSONRenderer(os.Stderr)), nil
+	}
+	return nil, fmt.Errorf("unknown progress mode %q (expected auto, bar, plain or json)", mode)
+}
+
+// FromConfig returns a reporter for the configured progress mode, falling
+// back to plain lines when the config cannot be loaded
+func FromConfig() *Reporter {
+	cfg, err := config.Load()
+	if err != nil {
+		return NewReporter(NewPlainRenderer(os.Stdout))
+	}
+	p, err := New(cfg.Progress)
+	if err != nil {
+		return NewReporter(NewPlainRenderer(os.Stdout))
+	}
+	return p
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *Reporter) emit(e Event) {
+	e.Time = time.Now().UTC()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.r.Render(e)
+}
+
+// Start announces a task
+func (p *Reporter) Start(task, message string) {
+	p.emit(Event{Kind: KindStart, Task: task, Message: message})
+}
+
+// Update reports that current of total units of a stage are done. total is
+// 0 when it is not known.
+func (p *Reporter) Update(task, stage string, current, total int64) {
+	p.emit(Event{Kind: KindProgress, Task: task, Stage: stage, Current: current, Total: total})
+}
+
+// Log reports a free-form message of a task
+func (p *Reporter) Log(task, message string) {
+	p.emit(Event{Kind: KindLog, Task: task, Message: message})
+}
+
+// Done finishes a task, err is nil on success
+func (p *Reporter) Done(task string, err error) {
+	e := Event{Kind: KindDone, Task: task}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	p.emit(e)
+}
+
+// sidebandLine matches git progress such as "Receiving objects:  45% (450/1000)"
+var sidebandLine = regexp.MustCompile(`^(?:remote: )?([^:]+):\s+\d+% \((\d+)/(\d+)\)`)
+
+// Writer returns a writer for git sideband progress or the output of a
+// child process. Progress lines become updates, everything else log events.
+func (p *Reporter) Writer(task string) io.Writer {
+	return &lineWriter{p: p, task: task}
+}
+
+type lineWriter struct {
+	p    *Reporter
+	task string
+	buf  []byte
+}
+
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.line(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+func (w *lineWriter) line(s string) {
+	if s == "" {
+		return
+	}
+	if m := sidebandLine.FindStringSubmatch(s); m != nil {
+		current, _ := strconv.ParseInt(m[2], 10, 64)
+		total, _ := strconv.ParseInt(m[3], 10, 64)
+		w.p.Update(w.task, m[1], current, total)
+		return
+	}
+	w.p.Log(w.task, s)
+}
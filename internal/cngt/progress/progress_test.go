@@ -0,0 +1,92 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Render(e Event) {
+	r.events = append(r.events, e)
+}
+
+func TestWriterParsesSideband(t *testing.T) {
+	rec := &recorder{}
+	w := NewReporter(rec).Writer("clone")
+
+	// go-git forwards the server output in arbitrary chunks
+	for _, chunk := range []string{
+		"Enumerating objects: 10, done.\n",
+		"Counting objects:  50% (5/10)\rCounting ob",
+		"jects: 100% (10/10), done.\n",
+		"Total 10 (delta 0), reused 0\n",
+	} {
+		w.Write([]byte(chunk))
+	}
+
+	want := []Event{
+		{Kind: KindLog, Task: "clone", Message: "Enumerating objects: 10, done."},
+		{Kind: KindProgress, Task: "clone", Stage: "Counting objects", Current: 5, Total: 10},
+		{Kind: KindProgress, Task: "clone", Stage: "Counting objects", Current: 10, Total: 10},
+		{Kind: KindLog, Task: "clone", Message: "Total 10 (delta 0), reused 0"},
+	}
+	if len(rec.events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(rec.events), len(want), rec.events)
+	}
+	for i, e := range rec.events {
+		e.Time = want[i].Time
+		if e != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestPlainRenderer(t *testing.T) {
+	var out bytes.Buffer
+	p := NewReporter(NewPlainRenderer(&out))
+
+	p.Start("fetch", "Fetching main from origin")
+	for i := int64(1); i <= 100; i++ {
+		p.Update("fetch", "Receiving objects", i, 100)
+	}
+	p.Done("fetch", nil)
+
+	want := "[fetch] Fetching main from origin\n" +
+		"[fetch] Receiving objects: 100% (100/100)\n" +
+		"[fetch] done\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestJSONRenderer(t *testing.T) {
+	var out bytes.Buffer
+	p := NewReporter(NewJSONRenderer(&out))
+
+	p.Update("deps", "Installing mido", 1, 4)
+	p.Done("deps", errors.New("uv failed"))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one event per line, got %q", out.String())
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Kind != KindDone || e.Task != "deps" || e.Error != "uv failed" || e.Time.IsZero() {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
+
+func TestNewRejectsUnknownMode(t *testing.T) {
+	if _, err := New("xml"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
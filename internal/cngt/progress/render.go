@@ -0,0 +1,133 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const barWidth = 30
+
+// BarRenderer redraws a single progress bar line per task on a terminal
+type BarRenderer struct {
+	w io.Writer
+	// drawn is set while the cursor is at the end of a bar line
+	drawn bool
+}
+
+// NewBarRenderer returns a renderer for interactive terminals
+func NewBarRenderer(w io.Writer) *BarRenderer {
+	return &BarRenderer{w: w}
+}
+
+func (r *BarRenderer) Render(e Event) {
+	switch e.Kind {
+	case KindProgress:
+		fmt.Fprintf(r.w, "\r\033[K%s", bar(e))
+		r.drawn = true
+	case KindStart:
+		r.line("⏳ " + e.Message)
+	case KindLog:
+		r.line("   " + e.Message)
+	case KindDone:
+		if e.Error != "" {
+			r.line(fmt.Sprintf("❌ %s failed: %s", e.Task, e.Error))
+		} else if r.drawn {
+			// Keep the completed bar on screen
+			fmt.Fprintln(r.w)
+			r.drawn = false
+		}
+	}
+}
+
+// line prints a message above the current bar
+func (r *BarRenderer) line(s string) {
+	if r.drawn {
+		fmt.Fprint(r.w, "\r\033[K")
+		r.drawn = false
+	}
+	fmt.Fprintln(r.w, s)
+}
+
+func bar(e Event) string {
+	if e.Total <= 0 {
+		return fmt.Sprintf("   %s %d", e.Stage, e.Current)
+	}
+	filled := int(e.Current * barWidth / e.Total)
+	if filled > barWidth {
+		filled = barWidth
+	}
+	return fmt.Sprintf("   %-20s [%s%s] %3d%% (%d/%d)", e.Stage,
+		strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled),
+		e.Current*100/e.Total, e.Current, e.Total)
+}
+
+// plainInterval is the minimum time between two lines for a running stage
+const plainInterval = 5 * time.Second
+
+// PlainRenderer writes one line per event, suitable for CI logs. Progress
+// of a stage is printed when it completes and at most every few seconds
+// while it runs.
+type PlainRenderer struct {
+	w       io.Writer
+	stage   string
+	current int64
+	printed time.Time
+}
+
+// NewPlainRenderer returns a renderer for non-interactive output
+func NewPlainRenderer(w io.Writer) *PlainRenderer {
+	return &PlainRenderer{w: w}
+}
+
+func (r *PlainRenderer) Render(e Event) {
+	switch e.Kind {
+	case KindStart:
+		fmt.Fprintf(r.w, "[%s] %s\n", e.Task, e.Message)
+	case KindLog:
+		fmt.Fprintf(r.w, "[%s] %s\n", e.Task, e.Message)
+	case KindProgress:
+		key := e.Task + "/" + e.Stage
+		if key == r.stage && e.Current == r.current {
+			return
+		}
+		complete := e.Total > 0 && e.Current >= e.Total
+		if key != r.stage {
+			r.stage, r.printed = key, e.Time
+		}
+		r.current = e.Current
+		if !complete && e.Time.Sub(r.printed) < plainInterval {
+			return
+		}
+		r.printed = e.Time
+
+		if e.Total > 0 {
+			fmt.Fprintf(r.w, "[%s] %s: %d%% (%d/%d)\n", e.Task, e.Stage, e.Current*100/e.Total, e.Current, e.Total)
+		} else {
+			fmt.Fprintf(r.w, "[%s] %s: %d\n", e.Task, e.Stage, e.Current)
+		}
+	case KindDone:
+		if e.Error != "" {
+			fmt.Fprintf(r.w, "[%s] failed: %s\n", e.Task, e.Error)
+		} else {
+			fmt.Fprintf(r.w, "[%s] done\n", e.Task)
+		}
+		r.stage = ""
+	}
+}
+
+// JSONRenderer writes every event as a JSON object on its own line
+type JSONRenderer struct {
+	enc *json.Encoder
+}
+
+// NewJSONRenderer returns a renderer for machine readable output
+func NewJSONRenderer(w io.Writer) *JSONRenderer {
+	return &JSONRenderer{enc: json.NewEncoder(w)}
+}
+
+func (r *JSONRenderer) Render(e Event) {
+	r.enc.Encode(e)
+}
@@ -0,0 +1,389 @@
+package cngt
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/snupai/cngt-cli/internal/cngt/progress"
+)
+
+// Remote is a named git remote of the CNGT checkout
+type Remote struct {
+	Name string
+	URLs []string
+}
+
+// SetRemote points the named remote of an existing checkout at url,
+// creating the remote if it does not exist yet. The clone is kept as is.
+func SetRemote(path, name, url string) error {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read repository config: %w", err)
+	}
+
+	if remote, ok := cfg.Remotes[name]; ok {
+		remote.URLs = []string{url}
+		if err := repo.SetConfig(cfg); err != nil {
+			return fmt.Errorf("failed to update remote %s: %w", name, err)
+		}
+		return nil
+	}
+
+	return AddRemote(path, name, url)
+}
+
+// AddRemote adds an extra remote, e.g. a fork, to the checkout
+func AddRemote(path, name, url string) error {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{
+		Name: name,
+		URLs: []string{url},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add remote %s: %w", name, err)
+	}
+	return nil
+}
+
+// RemoveRemote deletes a remote from the checkout
+func RemoveRemote(path, name string) error {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	if err := repo.DeleteRemote(name); err != nil {
+		return fmt.Errorf("failed to remove remote %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListRemotes returns the remotes of the checkout sorted by name
+func ListRemotes(path string) ([]Remote, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository config: %w", err)
+	}
+
+	remotes := make([]Remote, 0, len(cfg.Remotes))
+	for name, remote := range cfg.Remotes {
+		remotes = append(remotes, Remote{Name: name, URLs: remote.URLs})
+	}
+	sort.Slice(remotes, func(i, j int) bool { return remotes[i].Name < remotes[j].Name })
+	return remotes, nil
+}
+
+// openRemote returns the named remote. A non-empty url (see repo_mirror)
+// replaces the remote's configured URL without changing the checkout config;
+// fetched refs are still stored under the remote's name.
+func openRemote(repo *git.Repository, remoteName, url string) (*git.Remote, error) {
+	if url != "" {
+		return git.NewRemote(repo.Storer, &gitconfig.RemoteConfig{Name: remoteName, URLs: []string{url}}), nil
+	}
+
+	remote, err := repo.Remote(remoteName)
+	if err != nil {
+		return nil, fmt.Errorf("remote %s not found: %w", remoteName, err)
+	}
+	return remote, nil
+}
+
+// resolveBranch picks the branch to update: the configured one, otherwise
+// the checked out branch, otherwise the remote's default branch.
+func resolveBranch(repo *git.Repository, remoteName, url, branch string) (string, error) {
+	if branch != "" {
+		return branch, nil
+	}
+
+	if head, err := repo.Head(); err == nil && head.Name().IsBranch() {
+		return head.Name().Short(), nil
+	}
+
+	remote, err := openRemote(repo, remoteName, url)
+	if err != nil {
+		return "", err
+	}
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list remote %s: %w", remoteName, err)
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			return ref.Target().Short(), nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot determine the default branch of %s, set repo_branch", remoteName)
+}
+
+// fetchBranch fetches branch from the remote and returns the commit it points to
+func fetchBranch(repo *git.Repository, remoteName, url, branch string) (plumbing.Hash, error) {
+	remoteRef := plumbing.NewRemoteReferenceName(remoteName, branch)
+	refSpec := gitconfig.RefSpec(fmt.Sprintf("+%s:%s", plumbing.NewBranchReferenceName(branch), remoteRef))
+
+	remote, err := openRemote(repo, remoteName, url)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	p := progress.FromConfig()
+	p.Start("fetch", fmt.Sprintf("Fetching %s from %s", branch, remoteName))
+	err = remote.Fetch(&git.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []gitconfig.RefSpec{refSpec},
+		Progress:   p.Writer("fetch"),
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		err = fmt.Errorf("failed to fetch %s from %s: %w", branch, remoteName, err)
+		p.Done("fetch", err)
+		return plumbing.ZeroHash, err
+	}
+	p.Done("fetch", nil)
+
+	ref, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("branch %s not found on %s: %w", branch, remoteName, err)
+	}
+	return ref.Hash(), nil
+}
+
+// fastForward checks out the local branch tracking remoteName/branch and
+// moves it to target. Diverged local branches are refused.
+func fastForward(repo *git.Repository, remoteName, branch string, target plumbing.Hash) error {
+	w, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	local := plumbing.NewBranchReferenceName(branch)
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	if head.Name() != local {
+		if _, err := repo.Reference(local, false); err != nil {
+			// New branch, start it directly at the target commit
+			if err := w.Checkout(&git.CheckoutOptions{Branch: local, Hash: target, Create: true}); err != nil {
+				return fmt.Errorf("failed to check out branch %s: %w", branch, err)
+			}
+			return trackBranch(repo, remoteName, branch)
+		}
+
+		if err := w.Checkout(&git.CheckoutOptions{Branch: local}); err != nil {
+			return fmt.Errorf("failed to check out branch %s: %w", branch, err)
+		}
+		if head, err = repo.Head(); err != nil {
+			return fmt.Errorf("failed to read HEAD: %w", err)
+		}
+	}
+
+	if err := trackBranch(repo, remoteName, branch); err != nil {
+		return err
+	}
+
+	if head.Hash() == target {
+		return nil
+	}
+
+	current, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return fmt.Errorf("failed to read current commit: %w", err)
+	}
+	next, err := repo.CommitObject(target)
+	if err != nil {
+		return fmt.Errorf("failed to read remote commit: %w", err)
+	}
+	if ok, err := current.IsAncestor(next); err != nil || !ok {
+		return fmt.Errorf("local branch %s has diverged from %s/%s and cannot be fast-forwarded", branch, remoteName, branch)
+	}
+
+	if err := w.Reset(&git.ResetOptions{Commit: target, Mode: git.MergeReset}); err != nil {
+		return fmt.Errorf("failed to update worktree: %w", err)
+	}
+	return nil
+}
+
+// trackBranch records remoteName/branch as the upstream of the local branch
+func trackBranch(repo *git.Repository, remoteName, branch string) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read repository config: %w", err)
+	}
+
+	if b, ok := cfg.Branches[branch]; ok && b.Remote == remoteName {
+		return nil
+	}
+
+	cfg.Branches[branch] = &gitconfig.Branch{
+		Name:   branch,
+		Remote: remoteName,
+		Merge:  plumbing.NewBranchReferenceName(branch),
+	}
+	if err := repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to set upstream of %s: %w", branch, err)
+	}
+	return nil
+}
+
+// fetchAll fetches every branch and tag of the remote
+func fetchAll(repo *git.Repository, remoteName, url string) error {
+	refSpec := gitconfig.RefSpec(fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", remoteName))
+
+	remote, err := openRemote(repo, remoteName, url)
+	if err != nil {
+		return err
+	}
+
+	p := progress.FromConfig()
+	p.Start("fetch", fmt.Sprintf("Fetching branches and tags from %s", remoteName))
+	err = remote.Fetch(&git.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []gitconfig.RefSpec{refSpec},
+		Tags:       git.AllTags,
+		Progress:   p.Writer("fetch"),
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		err = fmt.Errorf("failed to fetch from %s: %w", remoteName, err)
+		p.Done("fetch", err)
+		return err
+	}
+	p.Done("fetch", nil)
+	return nil
+}
+
+// fetchRevision fetches every branch and tag and resolves rev. A shallow
+// clone is deepened when rev is older than the history fetched so far.
+func fetchRevision(repo *git.Repository, remoteName, url, rev string) (plumbing.Hash, error) {
+	if err := fetchAll(repo, remoteName, url); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	hash, err := resolveRevision(repo, remoteName, rev)
+	if err == nil || !isShallow(repo) {
+		return hash, err
+	}
+
+	if err := deepen(repo, remoteName, url); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return resolveRevision(repo, remoteName, rev)
+}
+
+// isShallow reports whether the checkout was cloned with a limited depth
+func isShallow(repo *git.Repository) bool {
+	shallows, err := repo.Storer.Shallow()
+	return err == nil && len(shallows) > 0
+}
+
+// deepen fetches the complete history of a shallow clone
+func deepen(repo *git.Repository, remoteName, url string) error {
+	remote, err := openRemote(repo, remoteName, url)
+	if err != nil {
+		return err
+	}
+
+	p := progress.FromConfig()
+	p.Start("deepen", "Fetching older history of the shallow clone")
+	err = remote.Fetch(&git.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", remoteName))},
+		Tags:       git.AllTags,
+		// Same as 'git fetch --unshallow'
+		Depth:    math.MaxInt32,
+		Progress: p.Writer("deepen"),
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		err = fmt.Errorf("failed to fetch history from %s: %w", remoteName, err)
+		p.Done("deepen", err)
+		return err
+	}
+	p.Done("deepen", nil)
+	return pruneShallow(repo)
+}
+
+// pruneShallow drops commits whose parents are available from the list of
+// shallow commits. go-git only ever adds to that list, which would keep git
+// from walking past them after a deepening fetch.
+func pruneShallow(repo *git.Repository) error {
+	shallows, err := repo.Storer.Shallow()
+	if err != nil {
+		return fmt.Errorf("failed to read shallow commits: %w", err)
+	}
+
+	var keep []plumbing.Hash
+	for _, hash := range shallows {
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			keep = append(keep, hash)
+			continue
+		}
+		for _, parent := range commit.ParentHashes {
+			if _, err := repo.CommitObject(parent); err != nil {
+				keep = append(keep, hash)
+				break
+			}
+		}
+	}
+
+	if fs, ok := repo.Storer.(*filesystem.Storage); ok && len(keep) == 0 {
+		// git treats an empty shallow file as a shallow clone as well
+		if err := fs.Filesystem().Remove("shallow"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove shallow file: %w", err)
+		}
+		return nil
+	}
+	if err := repo.Storer.SetShallow(keep); err != nil {
+		return fmt.Errorf("failed to write shallow commits: %w", err)
+	}
+	return nil
+}
+
+// resolveRevision resolves a tag, remote branch or (abbreviated) commit hash
+func resolveRevision(repo *git.Repository, remoteName, rev string) (plumbing.Hash, error) {
+	for _, candidate := range []string{rev, remoteName + "/" + rev} {
+		if hash, err := repo.ResolveRevision(plumbing.Revision(candidate)); err == nil {
+			return *hash, nil
+		}
+	}
+	return plumbing.ZeroHash, fmt.Errorf("revision %s not found", rev)
+}
+
+// checkoutRevision detaches HEAD at rev, fetching from the remote first
+// when the revision is not known locally.
+func checkoutRevision(repo *git.Repository, remoteName, url, rev string) (plumbing.Hash, error) {
+	hash, err := resolveRevision(repo, remoteName, rev)
+	if err != nil {
+		if hash, err = fetchRevision(repo, remoteName, url, rev); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Hash: hash}); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to check out %s: %w", rev, err)
+	}
+	return hash, nil
+}
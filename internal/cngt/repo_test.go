@@ -0,0 +1,138 @@
+package cngt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// bareRepo pushes every branch of src to a new bare repository
+func bareRepo(t *testing.T, src *git.Repository) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	remote := git.NewRemote(src.Storer, &gitconfig.RemoteConfig{Name: "bare", URLs: []string{dir}})
+	err := remote.Push(&git.PushOptions{RemoteName: "bare", RefSpecs: []gitconfig.RefSpec{"refs/heads/*:refs/heads/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// addBranch creates branch at the HEAD of repo and commits a file to it
+func addBranch(t *testing.T, repo *git.Repository, branch, name, content string) plumbing.Hash {
+	t.Helper()
+
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch), Create: true}); err != nil {
+		t.Fatal(err)
+	}
+	return addCommit(t, repo, name, content)
+}
+
+func TestSwitchRemote(t *testing.T) {
+	src := commitFiles(t, t.TempDir(), map[string]string{"GlyphModder.py": "print('upstream')\n"})
+	upstream := bareRepo(t, src)
+	patched := addBranch(t, src, "my-patches", "GlyphModder.py", "print('fork')\n")
+	fork := bareRepo(t, src)
+
+	cfg, checkout := installCheckout(t, upstream)
+
+	// Point origin at the fork and follow its branch
+	if err := SetRemote(cfg.CNGTPath, "origin", fork); err != nil {
+		t.Fatalf("SetRemote failed: %v", err)
+	}
+	if err := AddRemote(cfg.CNGTPath, "upstream", upstream); err != nil {
+		t.Fatalf("AddRemote failed: %v", err)
+	}
+	if err := AddRemote(cfg.CNGTPath, "upstream", upstream); err == nil {
+		t.Error("expected an error for an existing remote")
+	}
+	remotes, err := ListRemotes(cfg.CNGTPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remotes) != 2 || remotes[0].Name != "origin" || remotes[0].URLs[0] != fork || remotes[1].Name != "upstream" {
+		t.Fatalf("unexpected remotes %+v", remotes)
+	}
+
+	t.Setenv("CNGT_REPO_BRANCH", "my-patches")
+	if _, err := Update(UpdateOptions{}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	head, err := checkout.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Name().Short() != "my-patches" || head.Hash() != patched {
+		t.Errorf("HEAD = %s at %s, want my-patches at %s", head.Name().Short(), head.Hash(), patched)
+	}
+	repoCfg, err := checkout.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := repoCfg.Branches["my-patches"]; b == nil || b.Remote != "origin" {
+		t.Errorf("my-patches does not track origin: %+v", b)
+	}
+
+	if err := RemoveRemote(cfg.CNGTPath, "upstream"); err != nil {
+		t.Fatalf("RemoveRemote failed: %v", err)
+	}
+	if err := RemoveRemote(cfg.CNGTPath, "upstream"); err == nil {
+		t.Error("expected an error for a missing remote")
+	}
+	// SetRemote creates remotes that do not exist
+	if err := SetRemote(cfg.CNGTPath, "backup", upstream); err != nil {
+		t.Fatalf("SetRemote failed: %v", err)
+	}
+	if remotes, _ := ListRemotes(cfg.CNGTPath); len(remotes) != 2 || remotes[0].Name != "backup" {
+		t.Errorf("unexpected remotes %+v", remotes)
+	}
+}
+
+func TestResolveBranch(t *testing.T) {
+	src := commitFiles(t, t.TempDir(), map[string]string{"GlyphModder.py": "print('v1')\n"})
+	addBranch(t, src, "main", "GlyphModder.py", "print('v2')\n")
+	upstream := bareRepo(t, src)
+	bare, err := git.PlainOpen(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bare.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main"))); err != nil {
+		t.Fatal(err)
+	}
+
+	_, checkout := installCheckout(t, upstream)
+	for _, tc := range []struct{ name, configured, want string }{
+		{"configured", "release", "release"},
+		{"checked out", "", "main"},
+	} {
+		if got, err := resolveBranch(checkout, "origin", "", tc.configured); err != nil || got != tc.want {
+			t.Errorf("%s: got %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+
+	// A detached HEAD falls back to the default branch of the remote
+	w, err := checkout.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Hash: headHash(t, checkout)}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := resolveBranch(checkout, "origin", "", ""); err != nil || got != "main" {
+		t.Errorf("detached: got %q, %v, want main", got, err)
+	}
+	if _, err := resolveBranch(checkout, "missing", "", ""); err == nil || !strings.Contains(err.Error(), "remote missing not found") {
+		t.Errorf("got %v for a missing remote", err)
+	}
+}
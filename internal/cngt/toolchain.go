@@ -0,0 +1,187 @@
+package cngt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/snupai/cngt-cli/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+const toolchainFileName = "toolchain.yaml"
+
+// Toolchain is a named CNGT installation with its own checkout
+type Toolchain struct {
+	Name    string    `yaml:"-"`
+	Path    string    `yaml:"-"`
+	Active  bool      `yaml:"-"`
+	Commit  string    `yaml:"-"`
+	Broken  string    `yaml:"-"` // why toolchain.yaml could not be read
+	URL     string    `yaml:"url"`
+	Branch  string    `yaml:"branch,omitempty"`
+	Ref     string    `yaml:"ref,omitempty"`
+	Created time.Time `yaml:"created"`
+}
+
+// AddToolchain clones a new named installation and checks out ref, if given
+func AddToolchain(name string, opts InstallOptions, ref string) (*Toolchain, error) {
+	if err := config.ValidateToolchainName(name); err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	dir := cfg.ToolchainDir(name)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("toolchain %s already exists", name)
+	}
+
+	path := filepath.Join(dir, "cngt")
+	if err := Install(path, opts); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	if ref != "" {
+		repo, err := git.PlainOpen(path)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to open repository: %w", err)
+		}
+		if _, err := checkoutRevision(repo, opts.RemoteName, "", ref); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	tc := &Toolchain{Name: name, Path: path, URL: opts.URL, Branch: opts.Branch, Ref: ref, Created: time.Now().UTC()}
+	data, err := yaml.Marshal(tc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode toolchain: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, toolchainFileName), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write toolchain: %w", err)
+	}
+	return tc, nil
+}
+
+// ListToolchains returns the default installation followed by all named ones
+func ListToolchains() ([]Toolchain, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	toolchains := []Toolchain{{
+		Name:   config.DefaultToolchain,
+		Path:   cfg.DefaultCNGTPath,
+		Active: cfg.Toolchain == "",
+		URL:    cfg.CloneURL(),
+	}}
+
+	entries, err := os.ReadDir(filepath.Join(cfg.DataDir, config.ToolchainsDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list toolchains: %w", err)
+	}
+	var named []Toolchain
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tc, err := readToolchain(cfg, entry.Name())
+		if err != nil {
+			// Keep listing the others, e.g. after an interrupted 'toolchain add'
+			tc = &Toolchain{Name: entry.Name(), Path: filepath.Join(cfg.ToolchainDir(entry.Name()), "cngt"), Broken: err.Error()}
+		}
+		tc.Active = cfg.Toolchain == tc.Name
+		named = append(named, *tc)
+	}
+	sort.Slice(named, func(i, j int) bool { return named[i].Name < named[j].Name })
+	toolchains = append(toolchains, named...)
+
+	for i := range toolchains {
+		if repo, err := git.PlainOpen(toolchains[i].Path); err == nil {
+			if head, err := repo.Head(); err == nil {
+				toolchains[i].Commit = head.Hash().String()
+			}
+		}
+	}
+	return toolchains, nil
+}
+
+// ToolchainExists reports whether name is the default or an installed toolchain
+func ToolchainExists(name string) (bool, error) {
+	if name == "" || name == config.DefaultToolchain {
+		return true, nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return false, fmt.Errorf("failed to load config: %w", err)
+	}
+	_, err = os.Stat(filepath.Join(cfg.ToolchainDir(name), toolchainFileName))
+	return err == nil, nil
+}
+
+// RemoveToolchain deletes a named installation including its checkout
+func RemoveToolchain(name string) error {
+	if err := config.ValidateToolchainName(name); err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Toolchain == name {
+		return fmt.Errorf("toolchain %s is in use, switch with 'cngt-cli toolchain use default' first", name)
+	}
+	// Broken toolchains without a toolchain.yaml can be removed as well
+	if _, err := os.Stat(cfg.ToolchainDir(name)); err != nil {
+		return fmt.Errorf("toolchain %s does not exist", name)
+	}
+	if err := os.RemoveAll(cfg.ToolchainDir(name)); err != nil {
+		return fmt.Errorf("failed to remove toolchain %s: %w", name, err)
+	}
+	return nil
+}
+
+// upstream returns the mirror URL and branch the active installation is
+// updated from. A named toolchain keeps the remote and branch it was added
+// with; repo_mirror and repo_branch only apply to the default installation
+// and to toolchains whose toolchain.yaml does not record a URL.
+func upstream(cfg *config.Config) (string, string, error) {
+	if cfg.Toolchain == "" {
+		return cfg.RepoMirror, cfg.RepoBranch, nil
+	}
+	tc, err := readToolchain(cfg, cfg.Toolchain)
+	if err != nil {
+		return "", "", err
+	}
+	if tc.URL == "" {
+		return cfg.RepoMirror, cfg.RepoBranch, nil
+	}
+	// The checkout's remote was created with tc.URL
+	return "", tc.Branch, nil
+}
+
+func readToolchain(cfg *config.Config, name string) (*Toolchain, error) {
+	dir := cfg.ToolchainDir(name)
+	data, err := os.ReadFile(filepath.Join(dir, toolchainFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read toolchain %s: %w", name, err)
+	}
+
+	var tc Toolchain
+	if err := yaml.Unmarshal(data, &tc); err != nil {
+		return nil, fmt.Errorf("failed to parse toolchain %s: %w", name, err)
+	}
+	tc.Name = name
+	tc.Path = filepath.Join(dir, "cngt")
+	return &tc, nil
+}
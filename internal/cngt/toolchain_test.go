@@ -0,0 +1,137 @@
+package cngt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+func TestListToolchainsReportsBroken(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(config.DataDirEnv, dataDir)
+	if err := os.MkdirAll(filepath.Join(dataDir, config.ToolchainsDirName, "leftover", "cngt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	toolchains, err := ListToolchains()
+	if err != nil {
+		t.Fatalf("ListToolchains failed: %v", err)
+	}
+	if len(toolchains) != 2 || toolchains[0].Name != config.DefaultToolchain || toolchains[1].Name != "leftover" || toolchains[1].Broken == "" {
+		t.Fatalf("unexpected toolchains %+v", toolchains)
+	}
+
+	if err := RemoveToolchain("leftover"); err != nil {
+		t.Fatalf("RemoveToolchain failed: %v", err)
+	}
+	if toolchains, _ := ListToolchains(); len(toolchains) != 1 {
+		t.Errorf("broken toolchain was not removed: %+v", toolchains)
+	}
+}
+
+func TestToolchainKeepsItsUpstream(t *testing.T) {
+	src := commitFiles(t, t.TempDir(), map[string]string{"GlyphModder.py": "print('upstream')\n"})
+	addBranch(t, src, "my-patches", "GlyphModder.py", "print('fork')\n")
+	fork := bareRepo(t, src)
+
+	t.Setenv(config.DataDirEnv, t.TempDir())
+	tc, err := AddToolchain("patched", InstallOptions{URL: fork, RemoteName: "origin", Branch: "my-patches"}, "")
+	if err != nil {
+		t.Fatalf("AddToolchain failed: %v", err)
+	}
+	want := addCommit(t, src, "GlyphModder.py", "print('fork 2')\n")
+	// A copy of the fork with the new commit, as if it had moved
+	fork = bareRepo(t, src)
+	if err := SetRemote(tc.Path, "origin", fork); err != nil {
+		t.Fatal(err)
+	}
+
+	// The global settings belong to the default installation
+	t.Setenv("CNGT_REPO_MIRROR", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("CNGT_REPO_BRANCH", "master")
+	t.Setenv("CNGT_TOOLCHAIN", "patched")
+	if _, err := Update(UpdateOptions{}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	checkout, err := git.PlainOpen(tc.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := checkout.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Name().Short() != "my-patches" || head.Hash() != want {
+		t.Errorf("HEAD = %s at %s, want my-patches at %s", head.Name().Short(), head.Hash(), want)
+	}
+}
+
+func TestToolchains(t *testing.T) {
+	src := commitFiles(t, t.TempDir(), map[string]string{"GlyphModder.py": "print('v1')\n"})
+	v1 := headHash(t, src)
+	v2 := addCommit(t, src, "GlyphModder.py", "print('v2')\n")
+	upstream := bareRepo(t, src)
+
+	t.Setenv(config.DataDirEnv, t.TempDir())
+	opts := InstallOptions{URL: upstream, RemoteName: "origin"}
+	stable, err := AddToolchain("stable", opts, v1.String())
+	if err != nil {
+		t.Fatalf("AddToolchain failed: %v", err)
+	}
+	if _, err := AddToolchain("latest", opts, ""); err != nil {
+		t.Fatalf("AddToolchain failed: %v", err)
+	}
+	if _, err := AddToolchain("stable", opts, ""); err == nil {
+		t.Error("expected an error for an existing toolchain")
+	}
+	if _, err := AddToolchain(config.DefaultToolchain, opts, ""); err == nil {
+		t.Error("expected an error for the default toolchain")
+	}
+	if _, err := AddToolchain("broken", InstallOptions{URL: filepath.Join(t.TempDir(), "missing"), RemoteName: "origin"}, ""); err == nil {
+		t.Error("expected an error for a missing repository")
+	}
+
+	toolchains, err := ListToolchains()
+	if err != nil {
+		t.Fatalf("ListToolchains failed: %v", err)
+	}
+	var got []string
+	for _, tc := range toolchains {
+		got = append(got, tc.Name+"@"+tc.Commit)
+	}
+	want := []string{config.DefaultToolchain + "@", "latest@" + v2.String(), "stable@" + v1.String()}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got toolchains %v, want %v", got, want)
+	}
+	if !toolchains[0].Active || toolchains[2].Ref != v1.String() || toolchains[2].URL != upstream || toolchains[2].Path != stable.Path {
+		t.Errorf("unexpected toolchain %+v", toolchains[2])
+	}
+
+	for name, want := range map[string]bool{"": true, config.DefaultToolchain: true, "stable": true, "broken": false, "nightly": false} {
+		if ok, err := ToolchainExists(name); err != nil || ok != want {
+			t.Errorf("ToolchainExists(%q) = %v, %v, want %v", name, ok, err, want)
+		}
+	}
+
+	t.Setenv("CNGT_TOOLCHAIN", "stable")
+	if err := RemoveToolchain("stable"); err == nil {
+		t.Error("expected an error for the active toolchain")
+	}
+	t.Setenv("CNGT_TOOLCHAIN", "")
+	if err := RemoveToolchain("stable"); err != nil {
+		t.Fatalf("RemoveToolchain failed: %v", err)
+	}
+	if err := RemoveToolchain("stable"); err == nil {
+		t.Error("expected an error for a removed toolchain")
+	}
+	if _, err := os.Stat(stable.Path); !os.IsNotExist(err) {
+		t.Errorf("checkout %s was not removed", stable.Path)
+	}
+	if toolchains, _ := ListToolchains(); len(toolchains) != 2 {
+		t.Errorf("unexpected toolchains after removal %+v", toolchains)
+	}
+}
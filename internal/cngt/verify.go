@@ -0,0 +1,353 @@
+package cngt
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/snupai/cngt-cli/internal/config"
+	"github.com/snupai/cngt-cli/internal/prompt"
+)
+
+// VerifyResult lists where the checkout differs from its HEAD commit
+type VerifyResult struct {
+	Commit   string
+	Modified []string
+	Missing  []string
+	// Untracked files are only searched for by a full check
+	Untracked []string
+}
+
+// OK reports whether every tracked file matches HEAD. Untracked files do
+// not count as a failure.
+func (r *VerifyResult) OK() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0
+}
+
+// Verify compares the files of the checkout against the blob hashes of the
+// HEAD tree. A fast check trusts files whose size and modification time
+// still match the index and skips the search for untracked files.
+func Verify(full bool) (*VerifyResult, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	repo, err := git.PlainOpen(cfg.CNGTPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+	return verifyCheckout(repo, full)
+}
+
+// Repair restores modified and missing files from HEAD. The modifications
+// are saved as a patch first, its path is returned. Untracked files are
+// deleted only when removeUntracked is set.
+func Repair(result *VerifyResult, removeUntracked bool) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+
+	repo, err := git.PlainOpen(cfg.CNGTPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	var patchPath string
+	if !result.OK() {
+		files := append(append([]string{}, result.Modified...), result.Missing...)
+		if patchPath, err = stashChanges(cfg, repo, files); err != nil {
+			return "", err
+		}
+		if err := restoreFiles(repo, files); err != nil {
+			return patchPath, err
+		}
+	}
+
+	if removeUntracked {
+		for _, name := range result.Untracked {
+			if err := os.Remove(filepath.Join(cfg.CNGTPath, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+				return patchPath, fmt.Errorf("failed to remove %s: %w", name, err)
+			}
+		}
+	}
+	return patchPath, nil
+}
+
+// restoreFiles writes the HEAD version of the given tracked files and
+// refreshes their index entries. Unlike a hard reset it leaves untracked
+// and ignored files such as virtual environments alone.
+func restoreFiles(repo *git.Repository, files []string) error {
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to read tree: %w", err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	for _, name := range files {
+		file, err := tree.File(name)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from HEAD: %w", name, err)
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from HEAD: %w", name, err)
+		}
+
+		path := filepath.Join(w.Filesystem.Root(), filepath.FromSlash(name))
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		if file.Mode == filemode.Symlink {
+			err = os.Symlink(filepath.FromSlash(content), path)
+		} else {
+			perm := os.FileMode(0644)
+			if file.Mode == filemode.Executable {
+				perm = 0755
+			}
+			err = os.WriteFile(path, []byte(content), perm)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+
+		if _, err := w.Add(name); err != nil {
+			return fmt.Errorf("failed to update index for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func verifyCheckout(repo *git.Repository, full bool) (*VerifyResult, error) {
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	root := w.Filesystem.Root()
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree: %w", err)
+	}
+
+	var idx *index.Index
+	if !full {
+		if idx, err = repo.Storer.Index(); err != nil {
+			return nil, fmt.Errorf("failed to read index: %w", err)
+		}
+	}
+
+	result := &VerifyResult{Commit: head.Hash().String()}
+	tracked := map[string]bool{}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk tree: %w", err)
+		}
+		if entry.Mode == filemode.Dir || entry.Mode == filemode.Submodule {
+			continue
+		}
+		tracked[name] = true
+
+		path := filepath.Join(root, filepath.FromSlash(name))
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			result.Missing = append(result.Missing, name)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", name, err)
+		}
+
+		if idx != nil && statMatchesIndex(idx, name, entry.Hash, info) {
+			continue
+		}
+
+		hash, err := hashWorktreeFile(path, info)
+		if err != nil {
+			return nil, err
+		}
+		if hash != entry.Hash {
+			result.Modified = append(result.Modified, name)
+		}
+	}
+
+	if full {
+		if result.Untracked, err = untrackedFiles(w, tracked); err != nil {
+			return nil, err
+		}
+		// Left behind by older releases, removed by setup and update
+		generated, err := generatedFiles(repo)
+		if err != nil {
+			return nil, err
+		}
+		result.Untracked = slices.DeleteFunc(result.Untracked, func(name string) bool {
+			return slices.Contains(generated, name)
+		})
+	}
+
+	sort.Strings(result.Modified)
+	sort.Strings(result.Missing)
+	return result, nil
+}
+
+// statMatchesIndex reports whether the index records the expected blob for
+// name and the file's size and modification time are unchanged since.
+func statMatchesIndex(idx *index.Index, name string, hash plumbing.Hash, info fs.FileInfo) bool {
+	e, err := idx.Entry(name)
+	if err != nil {
+		return false
+	}
+	return e.Hash == hash && int64(e.Size) == info.Size() && e.ModifiedAt.Equal(info.ModTime())
+}
+
+func hashWorktreeFile(path string, info fs.FileInfo) (plumbing.Hash, error) {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to read link %s: %w", path, err)
+		}
+		return plumbing.ComputeHash(plumbing.BlobObject, []byte(filepath.ToSlash(target))), nil
+	}
+	if !info.Mode().IsRegular() {
+		// A directory or device where a file is tracked never matches
+		return plumbing.ZeroHash, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := plumbing.NewHasher(plumbing.BlobObject, info.Size())
+	if _, err := io.Copy(h, f); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return h.Sum(), nil
+}
+
+// untrackedFiles lists files that are neither tracked nor ignored
+func untrackedFiles(w *git.Worktree, tracked map[string]bool) ([]string, error) {
+	root := w.Filesystem.Root()
+
+	patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+	matcher := gitignore.NewMatcher(append(patterns, w.Excludes...))
+
+	var files []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() && rel == ".git" {
+			return filepath.SkipDir
+		}
+		if matcher.Match(strings.Split(rel, "/"), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !tracked[rel] {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk checkout: %w", err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// checkCheckout runs the fast integrity check before a script is executed
+// and asks whether to continue when the checkout differs from HEAD.
+func checkCheckout(cfg *config.Config) error {
+	if !cfg.VerifyCheckout {
+		return nil
+	}
+
+	repo, err := git.PlainOpen(cfg.CNGTPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	result, err := verifyCheckout(repo, false)
+	if err != nil {
+		return err
+	}
+	if result.OK() {
+		return nil
+	}
+
+	fmt.Println("⚠️  The CNGT checkout does not match its commit:")
+	PrintVerifyResult(os.Stdout, result)
+	fmt.Println("💡 Run 'cngt-cli repo verify --repair' to restore the files, or pass --no-verify to skip this check")
+	if !prompt.Confirm("Run the script anyway? (y/N): ", false) {
+		return fmt.Errorf("CNGT checkout failed verification")
+	}
+	return nil
+}
+
+// PrintVerifyResult lists the files that differ from HEAD
+func PrintVerifyResult(w io.Writer, result *VerifyResult) {
+	for _, name := range result.Modified {
+		fmt.Fprintf(w, "   modified   %s\n", name)
+	}
+	for _, name := range result.Missing {
+		fmt.Fprintf(w, "   missing    %s\n", name)
+	}
+	for _, name := range result.Untracked {
+		fmt.Fprintf(w, "   untracked  %s\n", name)
+	}
+}
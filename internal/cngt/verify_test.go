@@ -0,0 +1,91 @@
+package cngt
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+func TestVerifyAndRepair(t *testing.T) {
+	dir := t.TempDir()
+	repo := commitFiles(t, dir, map[string]string{
+		"GlyphModder.py":   "print('modder')\n",
+		"GlyphMigrate.py":  "print('migrate')\n",
+		"requirements.txt": "termcolor\n",
+		".gitignore":       "__pycache__/\n",
+	})
+
+	for _, full := range []bool{false, true} {
+		result, err := verifyCheckout(repo, full)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.OK() || len(result.Untracked) > 0 {
+			t.Fatalf("fresh checkout failed verification (full=%v): %+v", full, result)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "GlyphModder.py"), []byte("print('hacked')\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "requirements.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "__pycache__"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "__pycache__", "cache.pyc"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fast, err := verifyCheckout(repo, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fast.OK() || len(fast.Untracked) > 0 {
+		t.Errorf("unexpected fast result %+v", fast)
+	}
+
+	result, err := verifyCheckout(repo, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &VerifyResult{
+		Commit:    result.Commit,
+		Modified:  []string{"GlyphModder.py"},
+		Missing:   []string{"requirements.txt"},
+		Untracked: []string{"notes.txt"},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("got %+v, want %+v", result, want)
+	}
+
+	t.Setenv(config.DataDirEnv, t.TempDir())
+	t.Setenv("CNGT_PATH", dir)
+	t.Setenv("CNGT_TOOLCHAIN", "")
+
+	patch, err := Repair(result, true)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if _, err := os.Stat(patch); err != nil {
+		t.Errorf("modifications were not saved: %v", err)
+	}
+
+	result, err = verifyCheckout(repo, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK() || len(result.Untracked) > 0 {
+		t.Errorf("checkout not repaired: %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "__pycache__", "cache.pyc")); err != nil {
+		t.Error("ignored files must be kept")
+	}
+}
@@ -4,29 +4,640 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FileName is the name of the user config file inside the data directory
+	FileName = "config.yaml"
+
+	// DataDirEnv overrides the platform-specific data directory
+	DataDirEnv = "CNGT_DATA_DIR"
+
+	// ToolchainsDirName holds one directory per named CNGT installation
+	ToolchainsDirName = "toolchains"
+
+	// DefaultToolchain names the installation at cngt_path
+	DefaultToolchain = "default"
+
+	// DefaultPythonMinVersion is the oldest Python with importlib.metadata,
+	// which the dependency checks rely on
+	DefaultPythonMinVersion = "3.8"
+
+	defaultRepoURL = "https://github.com/SebiAi/custom-nothing-glyph-tools.git"
+)
+
+// Value sources reported by Settings
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
 )
 
+// minVersionPattern matches python_min_version values such as 3 or 3.10
+var minVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,2}$`)
+
+// overrides are set from command line flags and win over every other source
+var overrides = map[string]string{}
+
 type Config struct {
 	CNGTPath string
 	DataDir  string
+
+	// Toolchain is the active named installation, empty for the default one
+	Toolchain string
+	// DefaultCNGTPath is the checkout at cngt_path, which CNGTPath points
+	// to unless a named toolchain is active
+	DefaultCNGTPath string
+	// StateDir holds per-installation state such as the update history
+	StateDir string
+	// VenvPath is the Python environment the scripts of the installation
+	// run in, created and managed by the CLI
+	VenvPath string
+
+	RepoURL string
+	// RepoMirror replaces RepoURL for cloning and fetching when set
+	RepoMirror          string
+	RepoRemote          string
+	RepoBranch          string
+	CloneDepth          int
+	Python              string
+	PythonMinVersion    string
+	PythonMirror        string
+	VerifyCheckout      bool
+	Progress            string
+	ModderEngine        string
+	LintLabels          bool
+	CheckForUpdates     bool
+	UpdateCheckInterval time.Duration
+
+	sources map[string]string
+}
+
+// Setting describes a single resolved config value
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+	Env    string
+	Usage  string
+}
+
+type setting struct {
+	key   string
+	env   string
+	usage string
+	get   func(*Config) string
+	set   func(*Config, string) error
+	// normalize, if set, rewrites values before they are stored by Set
+	normalize func(string) (string, error)
 }
 
+var settings = []setting{
+	{
+		key:   "cngt_path",
+		env:   "CNGT_PATH",
+		usage: "Directory of the CNGT repository checkout (relative paths are resolved against the data directory)",
+		get:   func(c *Config) string { return c.CNGTPath },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				return fmt.Errorf("path must not be empty")
+			}
+			if !filepath.IsAbs(v) {
+				v = filepath.Join(c.DataDir, v)
+			}
+			c.CNGTPath = filepath.Clean(v)
+			return nil
+		},
+	},
+	{
+		key:   "toolchain",
+		env:   "CNGT_TOOLCHAIN",
+		usage: "Named CNGT installation to use (empty or default for cngt_path, see 'cngt-cli toolchain list')",
+		get:   func(c *Config) string { return c.Toolchain },
+		set: func(c *Config, v string) error {
+			if v == DefaultToolchain {
+				v = ""
+			}
+			if v != "" {
+				if err := ValidateToolchainName(v); err != nil {
+					return err
+				}
+			}
+			c.Toolchain = v
+			return nil
+		},
+	},
+	{
+		key:   "repo_url",
+		env:   "CNGT_REPO_URL",
+		usage: "Git URL the CNGT repository is cloned from",
+		get:   func(c *Config) string { return c.RepoURL },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				return fmt.Errorf("URL must not be empty")
+			}
+			c.RepoURL = v
+			return nil
+		},
+	},
+	{
+		key:   "repo_mirror",
+		env:   "CNGT_REPO_MIRROR",
+		usage: "Git URL or local path used instead of repo_url for setup and update, e.g. an internal mirror",
+		get:   func(c *Config) string { return c.RepoMirror },
+		set: func(c *Config, v string) error {
+			v, err := normalizeRepoURL(v)
+			if err != nil {
+				return err
+			}
+			c.RepoMirror = v
+			return nil
+		},
+		normalize: normalizeRepoURL,
+	},
+	{
+		key:   "repo_remote",
+		env:   "CNGT_REPO_REMOTE",
+		usage: "Remote that setup clones as and update pulls from",
+		get:   func(c *Config) string { return c.RepoRemote },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				return fmt.Errorf("remote name must not be empty")
+			}
+			c.RepoRemote = v
+			return nil
+		},
+	},
+	{
+		key:   "repo_branch",
+		env:   "CNGT_REPO_BRANCH",
+		usage: "Branch to install and update (empty for the remote's default branch)",
+		get:   func(c *Config) string { return c.RepoBranch },
+		set: func(c *Config, v string) error {
+			c.RepoBranch = v
+			return nil
+		},
+	},
+	{
+		key:   "clone_depth",
+		env:   "CNGT_CLONE_DEPTH",
+		usage: "Number of commits fetched by setup, 0 for the full history (older commits are fetched when needed)",
+		get:   func(c *Config) string { return strconv.Itoa(c.CloneDepth) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("expected a number of commits, 0 for the full history")
+			}
+			c.CloneDepth = n
+			return nil
+		},
+	},
+	{
+		key:   "python",
+		env:   "CNGT_PYTHON",
+		usage: "Python interpreter the environment is created from (empty to pick the newest suitable one, see 'cngt-cli python list')",
+		get:   func(c *Config) string { return c.Python },
+		set: func(c *Config, v string) error {
+			c.Python = v
+			return nil
+		},
+	},
+	{
+		key:   "python_min_version",
+		env:   "CNGT_PYTHON_MIN_VERSION",
+		usage: "Oldest Python version the environment may be created from, e.g. 3.10",
+		get:   func(c *Config) string { return c.PythonMinVersion },
+		set: func(c *Config, v string) error {
+			if !minVersionPattern.MatchString(v) {
+				return fmt.Errorf("expected a version such as 3.10, got %q", v)
+			}
+			c.PythonMinVersion = v
+			return nil
+		},
+	},
+	{
+		key:   "python_mirror",
+		env:   "CNGT_PYTHON_MIRROR",
+		usage: "URL or local directory with python-build-standalone archives and their SHA256SUMS, used instead of GitHub to install Python",
+		get:   func(c *Config) string { return c.PythonMirror },
+		set: func(c *Config, v string) error {
+			c.PythonMirror = v
+			return nil
+		},
+	},
+	{
+		key:   "verify_checkout",
+		env:   "CNGT_VERIFY_CHECKOUT",
+		usage: "Check the CNGT checkout for modified or missing files before running a script",
+		get:   func(c *Config) string { return strconv.FormatBool(c.VerifyCheckout) },
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false")
+			}
+			c.VerifyCheckout = b
+			return nil
+		},
+	},
+	{
+		key:   "progress",
+		env:   "CNGT_PROGRESS",
+		usage: "How clone, fetch and install progress is shown: auto, bar, plain or json (JSON events go to stderr)",
+		get:   func(c *Config) string { return c.Progress },
+		set: func(c *Config, v string) error {
+			switch v {
+			case "auto", "bar", "plain", "json":
+				c.Progress = v
+				return nil
+			}
+			return fmt.Errorf("expected auto, bar, plain or json")
+		},
+	},
+	{
+		key:   "modder_engine",
+		env:   "CNGT_MODDER_ENGINE",
+		usage: "What runs 'modder': python for GlyphModder.py, native for the built-in writer (title and glyph data only)",
+		get:   func(c *Config) string { return c.ModderEngine },
+		set: func(c *Config, v string) error {
+			switch v {
+			case "python", "native":
+				c.ModderEngine = v
+				return nil
+			}
+			return fmt.Errorf("expected python or native")
+		},
+	},
+	{
+		key:   "lint_labels",
+		env:   "CNGT_LINT_LABELS",
+		usage: "Check label files with 'lint' before 'translator' runs GlyphTranslator.py",
+		get:   func(c *Config) string { return strconv.FormatBool(c.LintLabels) },
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false")
+			}
+			c.LintLabels = b
+			return nil
+		},
+	},
+	{
+		key:   "check_updates",
+		env:   "CNGT_CHECK_UPDATES",
+		usage: "Periodically check for new cngt-cli releases",
+		get:   func(c *Config) string { return strconv.FormatBool(c.CheckForUpdates) },
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false")
+			}
+			c.CheckForUpdates = b
+			return nil
+		},
+	},
+	{
+		key:   "update_check_interval",
+		env:   "CNGT_UPDATE_CHECK_INTERVAL",
+		usage: "Minimum time between release checks (e.g. 24h, 168h)",
+		get:   func(c *Config) string { return c.UpdateCheckInterval.String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return fmt.Errorf("expected a positive duration such as 168h")
+			}
+			c.UpdateCheckInterval = d
+			return nil
+		},
+	},
+}
+
+// Load resolves the configuration. Values are applied in the following
+// order, later sources overriding earlier ones:
+//
+//  1. built-in defaults
+//  2. the config file (config.yaml in the data directory)
+//  3. CNGT_* environment variables
+//
+// Values passed to Override, e.g. from command line flags, take precedence
+// over all of them. The data directory itself can only be changed through
+// CNGT_DATA_DIR.
 func Load() (*Config, error) {
 	dataDir, err := getDataDir()
 	if err != nil {
 		return nil, err
 	}
 
+	cfg := defaults(dataDir)
+
+	values, err := readFile(cfg.FilePath())
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		v, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		if err := s.set(cfg, v); err != nil {
+			return nil, fmt.Errorf("invalid value for %s in %s: %w", s.key, cfg.FilePath(), err)
+		}
+		cfg.sources[s.key] = SourceFile
+	}
+
+	for _, s := range settings {
+		v := os.Getenv(s.env)
+		if v == "" {
+			continue
+		}
+		if err := s.set(cfg, v); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", s.env, err)
+		}
+		cfg.sources[s.key] = SourceEnv
+	}
+
+	for _, s := range settings {
+		v, ok := overrides[s.key]
+		if !ok {
+			continue
+		}
+		if err := s.set(cfg, v); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", s.key, err)
+		}
+		cfg.sources[s.key] = SourceFlag
+	}
+
+	cfg.DefaultCNGTPath = cfg.CNGTPath
+	if cfg.Toolchain != "" {
+		cfg.StateDir = cfg.ToolchainDir(cfg.Toolchain)
+		cfg.CNGTPath = filepath.Join(cfg.StateDir, "cngt")
+	}
+	cfg.VenvPath = filepath.Join(cfg.StateDir, "venv")
+
+	return cfg, nil
+}
+
+// Override sets a value for the rest of the process, taking precedence over
+// the config file and the environment. An empty value removes the override.
+func Override(key, value string) error {
+	if _, err := lookup(key); err != nil {
+		return err
+	}
+	if value == "" {
+		delete(overrides, key)
+		return nil
+	}
+	overrides[key] = value
+	return nil
+}
+
+// ToolchainDir returns the directory of a named installation
+func (c *Config) ToolchainDir(name string) string {
+	return filepath.Join(c.DataDir, ToolchainsDirName, name)
+}
+
+// ValidateToolchainName checks that name can be used as a directory name
+func ValidateToolchainName(name string) error {
+	if name == "" || name == DefaultToolchain {
+		return fmt.Errorf("toolchain name %q is reserved", name)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("invalid toolchain name %q (use letters, digits, '-', '_' and '.')", name)
+		}
+	}
+	if name == "." || name == ".." {
+		return fmt.Errorf("invalid toolchain name %q", name)
+	}
+	return nil
+}
+
+func defaults(dataDir string) *Config {
 	return &Config{
-		CNGTPath: filepath.Join(dataDir, "cngt"),
-		DataDir:  dataDir,
-	}, nil
+		CNGTPath:            filepath.Join(dataDir, "cngt"),
+		DataDir:             dataDir,
+		StateDir:            dataDir,
+		RepoURL:             defaultRepoURL,
+		RepoRemote:          "origin",
+		VerifyCheckout:      true,
+		Progress:            "auto",
+		PythonMinVersion:    DefaultPythonMinVersion,
+		ModderEngine:        "python",
+		LintLabels:          true,
+		CheckForUpdates:     true,
+		UpdateCheckInterval: 7 * 24 * time.Hour,
+		sources:             map[string]string{},
+	}
+}
+
+// FilePath returns the location of the user config file
+func (c *Config) FilePath() string {
+	return filepath.Join(c.DataDir, FileName)
+}
+
+// DefaultFilePath returns the config file location without parsing it
+func DefaultFilePath() (string, error) {
+	dataDir, err := getDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, FileName), nil
+}
+
+// CloneURL returns the URL to clone and fetch CNGT from, preferring the mirror
+func (c *Config) CloneURL() string {
+	if c.RepoMirror != "" {
+		return c.RepoMirror
+	}
+	return c.RepoURL
+}
+
+// normalizeRepoURL turns local repository paths into absolute paths so that
+// they keep working from any directory. URLs are returned unchanged.
+func normalizeRepoURL(v string) (string, error) {
+	if v == "" || strings.Contains(v, "://") || isScpLike(v) {
+		return v, nil
+	}
+
+	if v == "~" || strings.HasPrefix(v, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		v = filepath.Join(home, v[1:])
+	}
+
+	abs, err := filepath.Abs(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %q: %w", v, err)
+	}
+	return abs, nil
+}
+
+// isScpLike reports whether v is an scp-style address such as git@host:repo.git
+func isScpLike(v string) bool {
+	i := strings.Index(v, ":")
+	if i <= 0 || strings.ContainsAny(v[:i], "/\\") {
+		return false
+	}
+	// C:\mirror is a Windows drive, not a host
+	return !(i == 1 && runtime.GOOS == "windows")
+}
+
+// Keys returns all known config keys
+func Keys() []string {
+	keys := make([]string, 0, len(settings))
+	for _, s := range settings {
+		keys = append(keys, s.key)
+	}
+	return keys
+}
+
+// Get returns the resolved value of a single key
+func (c *Config) Get(key string) (string, error) {
+	s, err := lookup(key)
+	if err != nil {
+		return "", err
+	}
+	return s.get(c), nil
+}
+
+// Settings returns every key with its resolved value and where it came from
+func (c *Config) Settings() []Setting {
+	result := make([]Setting, 0, len(settings))
+	for _, s := range settings {
+		source := c.sources[s.key]
+		if source == "" {
+			source = SourceDefault
+		}
+		result = append(result, Setting{
+			Key:    s.key,
+			Value:  s.get(c),
+			Source: source,
+			Env:    s.env,
+			Usage:  s.usage,
+		})
+	}
+	return result
+}
+
+// Set validates value and stores it in the config file. An empty value
+// removes the key from the file so the default applies again.
+func Set(key, value string) error {
+	s, err := lookup(key)
+	if err != nil {
+		return err
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	cfg := defaults(dataDir)
+
+	values, err := readFile(cfg.FilePath())
+	if err != nil {
+		return err
+	}
+
+	if value == "" {
+		delete(values, key)
+	} else {
+		if err := s.set(cfg, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		if s.normalize != nil {
+			if value, err = s.normalize(value); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+		}
+		values[key] = value
+	}
+
+	return writeFile(cfg.FilePath(), values)
+}
+
+func lookup(key string) (setting, error) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, nil
+		}
+	}
+	return setting{}, fmt.Errorf("unknown config key %q (known keys: %s)", key, strings.Join(Keys(), ", "))
+}
+
+func readFile(path string) (map[string]string, error) {
+	values := map[string]string{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if values == nil {
+		values = map[string]string{}
+	}
+
+	for key := range values {
+		if _, err := lookup(key); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return values, nil
+}
+
+func writeFile(path string, values map[string]string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# cngt-cli configuration, see 'cngt-cli config list'\n")
+	for _, key := range keys {
+		line, err := yaml.Marshal(map[string]string{key: values[key]})
+		if err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		b.Write(line)
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
 }
 
 func getDataDir() (string, error) {
 	var dataDir string
-	
+
+	if override := os.Getenv(DataDirEnv); override != "" {
+		abs, err := filepath.Abs(override)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s: %w", DataDirEnv, err)
+		}
+		if err := os.MkdirAll(abs, 0755); err != nil {
+			return "", fmt.Errorf("failed to create data directory: %w", err)
+		}
+		return abs, nil
+	}
+
 	switch runtime.GOOS {
 	case "windows":
 		dataDir = os.Getenv("APPDATA")
@@ -53,10 +664,10 @@ func getDataDir() (string, error) {
 	}
 
 	dataDir = filepath.Join(dataDir, "cngt-cli")
-	
+
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create data directory: %w", err)
 	}
 
 	return dataDir, nil
-}
\ No newline at end of file
+}
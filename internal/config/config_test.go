@@ -33,4 +33,143 @@ func TestLoad(t *testing.T) {
 	if _, err := os.Stat(cfg.DataDir); os.IsNotExist(err) {
 		t.Error("DataDir should exist after Load()")
 	}
-}
\ No newline at end of file
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(DataDirEnv, dataDir)
+	t.Setenv("CNGT_PYTHON", "")
+	t.Setenv("CNGT_REPO_URL", "https://example.com/env.git")
+
+	if err := Set("repo_url", "https://example.com/file.git"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := Set("python", "python3.12"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.DataDir != dataDir {
+		t.Errorf("DataDir = %s, want %s", cfg.DataDir, dataDir)
+	}
+	if cfg.RepoURL != "https://example.com/env.git" {
+		t.Errorf("environment should override the config file, got %s", cfg.RepoURL)
+	}
+	if cfg.Python != "python3.12" {
+		t.Errorf("config file should override the default, got %q", cfg.Python)
+	}
+	if !cfg.CheckForUpdates {
+		t.Error("check_updates should default to true")
+	}
+
+	sources := map[string]string{}
+	for _, s := range cfg.Settings() {
+		sources[s.Key] = s.Source
+	}
+	if sources["repo_url"] != SourceEnv || sources["python"] != SourceFile || sources["cngt_path"] != SourceDefault {
+		t.Errorf("unexpected sources: %v", sources)
+	}
+}
+
+func TestSetValidation(t *testing.T) {
+	t.Setenv(DataDirEnv, t.TempDir())
+
+	if err := Set("does_not_exist", "1"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+	if err := Set("check_updates", "maybe"); err == nil {
+		t.Error("expected an error for an invalid boolean")
+	}
+
+	if err := Set("cngt_path", "checkout"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.CNGTPath != filepath.Join(cfg.DataDir, "checkout") {
+		t.Errorf("relative cngt_path should resolve against DataDir, got %s", cfg.CNGTPath)
+	}
+
+	// An empty value removes the key again
+	if err := Set("cngt_path", ""); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.CNGTPath != filepath.Join(cfg.DataDir, "cngt") {
+		t.Errorf("cngt_path should be back to default, got %s", cfg.CNGTPath)
+	}
+}
+
+func TestToolchainOverride(t *testing.T) {
+	t.Setenv(DataDirEnv, t.TempDir())
+	t.Setenv("CNGT_TOOLCHAIN", "")
+
+	if err := Override("toolchain", "stable"); err != nil {
+		t.Fatalf("Override failed: %v", err)
+	}
+	defer Override("toolchain", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	want := filepath.Join(cfg.DataDir, ToolchainsDirName, "stable")
+	if cfg.StateDir != want {
+		t.Errorf("StateDir = %s, want %s", cfg.StateDir, want)
+	}
+	if cfg.CNGTPath != filepath.Join(want, "cngt") {
+		t.Errorf("CNGTPath should point into the toolchain, got %s", cfg.CNGTPath)
+	}
+	if cfg.DefaultCNGTPath != filepath.Join(cfg.DataDir, "cngt") {
+		t.Errorf("DefaultCNGTPath = %s", cfg.DefaultCNGTPath)
+	}
+
+	if err := Override("toolchain", "../escape"); err != nil {
+		t.Fatalf("Override failed: %v", err)
+	}
+	if _, err := Load(); err == nil {
+		t.Error("expected an error for an invalid toolchain name")
+	}
+}
+
+func TestRepoMirror(t *testing.T) {
+	t.Setenv(DataDirEnv, t.TempDir())
+	t.Setenv("CNGT_REPO_MIRROR", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.CloneURL() != cfg.RepoURL {
+		t.Errorf("CloneURL = %s, want repo_url without a mirror", cfg.CloneURL())
+	}
+
+	// Relative paths are stored as absolute ones
+	if err := Set("repo_mirror", "mirrors/cngt.git"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cwd, _ := os.Getwd()
+	if want := filepath.Join(cwd, "mirrors", "cngt.git"); cfg.CloneURL() != want {
+		t.Errorf("CloneURL = %s, want %s", cfg.CloneURL(), want)
+	}
+
+	for _, url := range []string{"file:///srv/git/cngt.git", "https://git.example.com/cngt.git", "git@example.com:cngt.git"} {
+		if got, err := normalizeRepoURL(url); err != nil || got != url {
+			t.Errorf("normalizeRepoURL(%q) = %q, %v", url, got, err)
+		}
+	}
+}
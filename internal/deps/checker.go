@@ -1,17 +1,20 @@
 package deps
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
 	"strings"
 
+	"github.com/snupai/cngt-cli/internal/cngt/progress"
 	"github.com/snupai/cngt-cli/internal/config"
+	"github.com/snupai/cngt-cli/internal/prompt"
+	"github.com/snupai/cngt-cli/internal/python"
 )
 
+// requiredPackages is the fallback for checkouts without requirements.txt
+// or pyproject.toml, see LoadRequirements
 var requiredPackages = []string{
 	"termcolor",
 	"mido",
@@ -19,43 +22,20 @@ var requiredPackages = []string{
 	"cryptography>=42.0.5",
 }
 
+// AreInstalled reports whether the managed environment exists and has all
+// required packages in a version that satisfies their requirement.
+// Packages of the system Python do not count.
 func AreInstalled() bool {
 	cfg, err := config.Load()
 	if err != nil {
 		return false
 	}
-	
-	// Check if using uv environment
-	if isUvAvailable() && hasUvProject(cfg.CNGTPath) {
-		return arePackagesInstalledInUv(cfg.CNGTPath)
-	}
-	
-	// Fallback to system Python check
-	for _, pkg := range requiredPackages {
-		if !isPackageInstalled(pkg) {
-			return false
-		}
-	}
-	return true
-}
 
-func arePackagesInstalledInUv(projectPath string) bool {
-	for _, pkg := range requiredPackages {
-		pkgName := strings.Split(pkg, ">=")[0]
-		pkgName = strings.Split(pkgName, "==")[0]
-		
-		cmd := exec.Command("uv", "run", "python", "-c", fmt.Sprintf("import %s", pkgName))
-		cmd.Dir = projectPath
-		if cmd.Run() != nil {
-			return false
-		}
+	if !HasVenv(cfg.VenvPath) {
+		return false
 	}
-	return true
-}
-
-func hasUvProject(path string) bool {
-	_, err := os.Stat(filepath.Join(path, "pyproject.toml"))
-	return err == nil
+	_, pkgs, err := CheckEnvironment(cfg)
+	return err == nil && PackagesOK(pkgs)
 }
 
 func Install() error {
@@ -64,36 +44,87 @@ func Install() error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	if !isPythonInstalled() {
-		return fmt.Errorf("Python is not installed. Please install Python first")
+	if _, err := python.Find(cfg); err != nil {
+		return err
 	}
 
-	fmt.Println("Installing Python dependencies...")
+	p := progress.FromConfig()
+	p.Start("deps", "Installing Python dependencies")
 	
 	// Try to install uv if not available
 	if !isUvAvailable() {
-		fmt.Println("   Installing uv (modern Python package manager)...")
-		if err := installUv(); err != nil {
-			fmt.Printf("   Failed to install uv, falling back to pip: %v\n", err)
-			return installWithPip(cfg)
+		p.Log("deps", "Installing uv (modern Python package manager)")
+		// The installer script is piped into sh, which hides download
+		// errors, so check that uv is actually there afterwards
+		if err := installUv(p); err != nil || !isUvAvailable() {
+			if err == nil {
+				err = fmt.Errorf("uv is not on PATH after installing it")
+			}
+			p.Log("deps", fmt.Sprintf("Failed to install uv, falling back to pip: %v", err))
+			err = installPackages(cfg, false, nil, p)
+			p.Done("deps", err)
+			return err
 		}
-		fmt.Println("   ✓ uv installed successfully")
+		p.Log("deps", "✓ uv installed successfully")
 	}
 	
-	return installWithUv(cfg)
+	err = installPackages(cfg, true, nil, p)
+	p.Done("deps", err)
+	return err
 }
 
-func isPythonInstalled() bool {
-	// Try different Python command variations
-	pythonCommands := []string{"python", "python3", "py"}
-	
-	for _, pythonCmd := range pythonCommands {
-		cmd := exec.Command(pythonCmd, "--version")
-		if cmd.Run() == nil {
-			return true
-		}
+// InstallOffline installs the dependencies from a local wheel directory
+// without any network access
+func InstallOffline(wheelDir string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if _, err := python.Find(cfg); err != nil {
+		return err
 	}
-	return false
+
+	p := progress.FromConfig()
+	p.Start("deps", "Installing Python dependencies from bundled wheels")
+	indexArgs := []string{"--no-index", "--find-links", wheelDir}
+	err = installPackages(cfg, isUvAvailable(), indexArgs, p)
+	p.Done("deps", err)
+	return err
+}
+
+// DownloadWheels downloads the dependencies and their requirements as
+// wheels into dir, for installation on machines without network access
+func DownloadWheels(dir string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	base, err := python.Find(cfg)
+	if err != nil {
+		return err
+	}
+
+	reqs, err := LoadRequirements(cfg.CNGTPath)
+	if err != nil {
+		return err
+	}
+	args := []string{"-m", "pip", "download", "--prefer-binary", "--dest", dir}
+	args = append(args, reqs.installArgs()...)
+
+	p := progress.FromConfig()
+	p.Start("wheels", "Downloading wheels")
+	cmd := exec.Command(base.Command(), args...)
+	cmd.Stdout = p.Writer("wheels")
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Run(); err != nil {
+		err = fmt.Errorf("failed to download wheels: %w", err)
+		p.Done("wheels", err)
+		return err
+	}
+	p.Done("wheels", nil)
+	return nil
 }
 
 func isUvAvailable() bool {
@@ -101,7 +132,7 @@ func isUvAvailable() bool {
 	return cmd.Run() == nil
 }
 
-func installUv() error {
+func installUv(p *progress.Reporter) error {
 	// Install uv using the official installer
 	var cmd *exec.Cmd
 	
@@ -115,159 +146,163 @@ func installUv() error {
 		cmd = exec.Command("sh", "-c", "curl -LsSf https://astral.sh/uv/install.sh | sh")
 	}
 	
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = p.Writer("deps")
+	cmd.Stderr = cmd.Stdout
 	return cmd.Run()
 }
 
-func installWithUv(cfg *config.Config) error {
-	// Initialize uv project in the CNGT directory
-	fmt.Println("   Setting up Python environment...")
-	
-	// Store current directory to restore later
-	originalDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+// installPackages installs the required packages into the managed
+// environment, creating it first if needed. It uses uv when useUv is set
+// and pip otherwise. indexArgs are passed to every install, e.g. to install
+// from local wheels.
+func installPackages(cfg *config.Config, useUv bool, indexArgs []string, p *progress.Reporter) error {
+	if err := ensureVenv(cfg, useUv, p); err != nil {
+		return err
 	}
-	defer os.Chdir(originalDir)
-	
-	// Change to CNGT directory
-	if err := os.Chdir(cfg.CNGTPath); err != nil {
-		return fmt.Errorf("failed to change to CNGT directory: %w", err)
+
+	run, err := installer(cfg, useUv, indexArgs, p)
+	if err != nil {
+		return err
 	}
-	
-	// Initialize uv project if not already initialized
-	pyprojectFile := filepath.Join(cfg.CNGTPath, "pyproject.toml")
-	if _, err := os.Stat(pyprojectFile); os.IsNotExist(err) {
-		cmd := exec.Command("uv", "init", "--no-readme")
-		cmd.Dir = cfg.CNGTPath
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to initialize uv project: %w", err)
-		}
+
+	reqs, err := LoadRequirements(cfg.CNGTPath)
+	if err != nil {
+		return err
 	}
-	
-	// Install packages using uv
-	reqFile := filepath.Join(cfg.CNGTPath, "requirements.txt")
-	if _, err := os.Stat(reqFile); os.IsNotExist(err) {
-		// Install packages individually if no requirements.txt
-		for _, pkg := range requiredPackages {
-			fmt.Printf("   Installing %s...\n", pkg)
-			cmd := exec.Command("uv", "add", pkg)
-			cmd.Dir = cfg.CNGTPath
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("failed to install %s: %w", pkg, err)
-			}
-		}
-	} else {
-		// Install from requirements.txt
-		fmt.Println("   Installing from requirements.txt...")
-		
-		// First, add the requirements to the project
-		cmd := exec.Command("uv", "add", "-r", reqFile)
-		cmd.Dir = cfg.CNGTPath
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to add requirements from requirements.txt: %w", err)
-		}
+	p.Log("deps", fmt.Sprintf("Installing packages (%s)", reqs.Source))
+	if err := run(reqs.installArgs()...); err != nil {
+		return fmt.Errorf("failed to install requirements (%s): %w", reqs.Source, err)
 	}
-	
 	return nil
 }
 
-func installWithPip(cfg *config.Config) error {
-	reqFile := filepath.Join(cfg.CNGTPath, "requirements.txt")
-	if _, err := os.Stat(reqFile); os.IsNotExist(err) {
-		return installPackagesDirectly("pip", "install")
+// installer returns a function that runs uv pip install or pip install
+// with its arguments in the managed environment, adding indexArgs
+func installer(cfg *config.Config, useUv bool, indexArgs []string, p *progress.Reporter) (func(args ...string) error, error) {
+	python := VenvPython(cfg.VenvPath)
+	install := []string{"uv", "pip", "install", "--python", python}
+	if !useUv {
+		if err := ensurePip(python, p); err != nil {
+			return nil, err
+		}
+		install = []string{python, "-m", "pip", "install"}
 	}
-
-	cmd := exec.Command("pip", "install", "-r", reqFile)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return func(args ...string) error {
+		args = append(append(install[1:len(install):len(install)], args...), indexArgs...)
+		cmd := exec.Command(install[0], args...)
+		cmd.Stdout = p.Writer("deps")
+		cmd.Stderr = cmd.Stdout
+		return cmd.Run()
+	}, nil
 }
 
-func installPackagesDirectly(tool, action string) error {
-	for _, pkg := range requiredPackages {
-		fmt.Printf("Installing %s...\n", pkg)
-		cmd := exec.Command(tool, action, pkg)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to install %s: %w", pkg, err)
-		}
+// upgradePackages installs or upgrades only the given requirements
+func upgradePackages(cfg *config.Config, useUv bool, specs []string, p *progress.Reporter) error {
+	if err := ensureVenv(cfg, useUv, p); err != nil {
+		return err
+	}
+	run, err := installer(cfg, useUv, nil, p)
+	if err != nil {
+		return err
+	}
+	p.Log("deps", "Upgrading "+strings.Join(specs, ", "))
+	if err := run(append([]string{"--upgrade"}, specs...)...); err != nil {
+		return fmt.Errorf("failed to upgrade %s: %w", strings.Join(specs, ", "), err)
 	}
 	return nil
 }
 
-func isPackageInstalled(pkg string) bool {
-	pkgName := strings.Split(pkg, ">=")[0]
-	pkgName = strings.Split(pkgName, "==")[0]
-	
-	// Try different Python command variations
-	pythonCommands := []string{"python", "python3", "py"}
-	
-	for _, pythonCmd := range pythonCommands {
-		cmd := exec.Command(pythonCmd, "-c", fmt.Sprintf("import %s", pkgName))
-		if cmd.Run() == nil {
-			return true
-		}
+func CheckInteractive() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
 	}
-	return false
-}
 
-func CheckInteractive() error {
-	if isPythonInstalled() {
-		fmt.Println("   ✓ Python is installed")
+	if base, err := python.Find(cfg); err == nil {
+		fmt.Printf("   ✓ Python %s (%s)\n", base.Version, base.Command())
 	} else {
-		fmt.Println("   ✗ Python is not installed")
+		fmt.Printf("   ✗ %v\n", err)
 		fmt.Println()
 		fmt.Println("   Python is required to run CNGT tools.")
-		fmt.Println("   Please install Python from https://python.org")
-		fmt.Println("   On Windows, you can also use: winget install Python.Python.3")
-		fmt.Println()
-		return fmt.Errorf("Python is required but not installed")
-	}
-
-	missing := []string{}
-	for _, pkg := range requiredPackages {
-		if isPackageInstalled(pkg) {
-			fmt.Printf("   ✓ %s is installed\n", pkg)
-		} else {
-			fmt.Printf("   ✗ %s is missing\n", pkg)
-			missing = append(missing, pkg)
+		if cfg.Python != "" {
+			fmt.Println("   Pick another interpreter with 'cngt-cli python use <path|auto>'")
+			fmt.Println()
+			return fmt.Errorf("the configured Python is not usable")
 		}
-	}
-
-	if len(missing) > 0 {
-		fmt.Printf("\n   Missing %d Python packages. Install them automatically? (Y/n): ", len(missing))
-		reader := bufio.NewReader(os.Stdin)
-		response, err := reader.ReadString('\n')
+		version := python.InstallVersion(cfg)
+		if !prompt.Confirm(fmt.Sprintf("   Download a standalone Python %s into %s? (Y/n): ", version, python.ManagedDir(cfg)), true) {
+			fmt.Println("   Please install Python from https://python.org")
+			fmt.Println("   On Windows, you can also use: winget install Python.Python.3")
+			fmt.Println("   If it is installed already, pick it with 'cngt-cli python use <path>'")
+			fmt.Println()
+			return fmt.Errorf("Python is required but not installed")
+		}
+		base, err := python.Install(cfg, version, progress.FromConfig())
 		if err != nil {
-			return fmt.Errorf("failed to read input: %w", err)
+			return fmt.Errorf("failed to install Python: %w", err)
 		}
+		fmt.Printf("   ✓ Python %s (%s)\n", base.Version, base.Command())
+	}
 
-		response = strings.ToLower(strings.TrimSpace(response))
-		if response == "n" || response == "no" {
-			fmt.Println("   Setup cancelled. You can install packages manually with:")
-			for _, pkg := range missing {
-				fmt.Printf("   pip install %s\n", pkg)
-			}
+	reqs, err := LoadRequirements(cfg.CNGTPath)
+	if err != nil {
+		return err
+	}
+	python := VenvPython(cfg.VenvPath)
+	if !HasVenv(cfg.VenvPath) {
+		fmt.Printf("   ✗ No Python environment at %s yet\n", cfg.VenvPath)
+		if !prompt.Confirm("\n   Create it and install the required Python packages? (Y/n): ", true) {
+			fmt.Println("   Setup cancelled. You can set up the environment manually with:")
+			fmt.Printf("   python3 -m venv %s\n", cfg.VenvPath)
+			fmt.Printf("   %s -m pip install %s\n", python, quoteArgs(reqs.installArgs()))
 			return fmt.Errorf("Python dependencies are required but installation was cancelled")
 		}
-		
 		fmt.Println("   Installing Python packages...")
 		if err := Install(); err != nil {
 			return fmt.Errorf("failed to install Python packages: %w", err)
 		}
 		fmt.Println("   ✓ Python packages installed successfully")
-	} else {
+		return nil
+	}
+
+	pkgs, err := CheckPackages(python, reqs)
+	if err != nil {
+		return err
+	}
+	PrintPackages(os.Stdout, "   ", pkgs)
+	if PackagesOK(pkgs) {
 		fmt.Println("   ✓ All Python packages are already installed")
+		return nil
+	}
+
+	var specs []string
+	for _, pkg := range pkgs {
+		if pkg.State != StateOK {
+			specs = append(specs, pkg.Requirement.spec())
+		}
+	}
+	if !prompt.Confirm(fmt.Sprintf("\n   %d Python packages need to be installed or upgraded. Do it now? (Y/n): ", len(specs)), true) {
+		fmt.Println("   Setup cancelled. You can upgrade the packages manually with:")
+		fmt.Printf("   %s -m pip install --upgrade %s\n", python, quoteArgs(specs))
+		return fmt.Errorf("Python dependencies are required but installation was cancelled")
+	}
+	if err := Upgrade(pkgs); err != nil {
+		return fmt.Errorf("failed to upgrade Python packages: %w", err)
 	}
+	fmt.Println("   ✓ Python packages upgraded successfully")
 
 	return nil
-}
\ No newline at end of file
+}
+
+// quoteArgs joins command line arguments for display, quoting those that
+// a shell would split or interpret
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = arg
+		if strings.ContainsAny(arg, " <>;!\"'[]*") {
+			quoted[i] = "\"" + strings.ReplaceAll(arg, "\"", "\\\"") + "\""
+		}
+	}
+	return strings.Join(quoted, " ")
+}
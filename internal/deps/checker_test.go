@@ -1,7 +1,15 @@
 package deps
 
 import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+
+	"github.com/snupai/cngt-cli/internal/cngt/progress"
+	"github.com/snupai/cngt-cli/internal/config"
+	"github.com/snupai/cngt-cli/internal/python"
 )
 
 func TestRequiredPackages(t *testing.T) {
@@ -24,14 +32,66 @@ func TestRequiredPackages(t *testing.T) {
 	}
 }
 
-func TestIsPythonInstalled(t *testing.T) {
-	// This test depends on the environment, so we'll just check that it doesn't panic
-	result := isPythonInstalled()
-	t.Logf("Python installed: %v", result)
-}
-
 func TestIsUvAvailable(t *testing.T) {
 	// This test depends on the environment, so we'll just check that it doesn't panic
 	result := isUvAvailable()
 	t.Logf("uv available: %v", result)
-}
\ No newline at end of file
+}
+
+func TestEnsureVenv(t *testing.T) {
+	cfg := &config.Config{PythonMinVersion: config.DefaultPythonMinVersion, VenvPath: filepath.Join(t.TempDir(), "venv")}
+	base, err := python.Find(cfg)
+	if err != nil || exec.Command(base.Command(), "-m", "venv", "--help").Run() != nil {
+		t.Skip("python -m venv is not available")
+	}
+
+	p := progress.NewReporter(progress.NewPlainRenderer(io.Discard))
+	if HasVenv(cfg.VenvPath) {
+		t.Fatal("HasVenv reported an environment before it was created")
+	}
+	if err := ensureVenv(cfg, false, p); err != nil {
+		t.Fatalf("ensureVenv failed: %v", err)
+	}
+	if !HasVenv(cfg.VenvPath) {
+		t.Fatalf("no interpreter at %s", VenvPython(cfg.VenvPath))
+	}
+
+	// A broken environment is replaced
+	if err := os.Remove(VenvPython(cfg.VenvPath)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureVenv(cfg, false, p); err != nil {
+		t.Fatalf("ensureVenv failed to recreate the environment: %v", err)
+	}
+	if !HasVenv(cfg.VenvPath) {
+		t.Fatal("broken environment was not recreated")
+	}
+}
+
+func TestPackageState(t *testing.T) {
+	tests := []struct {
+		req       string
+		installed string
+		want      string
+	}{
+		{"termcolor", "2.4.0", StateOK},
+		{"termcolor", "", StateMissing},
+		{"cryptography>=42.0.5", "41.0.7", StateTooOld},
+		{"cryptography>=42.0.5", "42.0.5", StateOK},
+		{"colorama<0.5", "0.5.1", StateTooNew},
+		{"colorama~=0.4.6", "0.4.5", StateTooOld},
+		{"colorama~=0.4.6", "0.5.0", StateTooNew},
+		{"mido!=1.3.0", "1.3.0", StateExcluded},
+		{"mido>=1.0", "not-a-version", StateTooOld},
+		{"mido", "not-a-version", StateOK},
+	}
+	for _, tt := range tests {
+		req, err := ParseRequirement(tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := packageState(req, tt.installed); got != tt.want {
+			t.Errorf("%s with %q installed: got %s, want %s", tt.req, tt.installed, got, tt.want)
+		}
+	}
+}
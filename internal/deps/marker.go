@@ -0,0 +1,210 @@
+package deps
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// markerVariables are the environment marker variables of PEP 508
+var markerVariables = map[string]bool{
+	"os_name": true, "sys_platform": true, "platform_machine": true,
+	"platform_python_implementation": true, "platform_release": true,
+	"platform_system": true, "platform_version": true, "python_version": true,
+	"python_full_version": true, "implementation_name": true,
+	"implementation_version": true, "extra": true,
+}
+
+// EvaluateMarker reports whether an environment marker such as
+// python_version < "3.12" and sys_platform != "win32" holds in env
+func EvaluateMarker(marker string, env map[string]string) (bool, error) {
+	tokens, err := tokenizeMarker(marker)
+	if err != nil {
+		return false, fmt.Errorf("invalid marker %q: %w", marker, err)
+	}
+	p := &markerParser{tokens: tokens, env: env}
+	result, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return false, fmt.Errorf("invalid marker %q: %w", marker, err)
+	}
+	return result, nil
+}
+
+type markerToken struct {
+	text string
+	// quoted is set for string literals, whose text is unquoted
+	quoted bool
+}
+
+func tokenizeMarker(s string) ([]markerToken, error) {
+	var tokens []markerToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, markerToken{text: string(c)})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, markerToken{text: s[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		case strings.ContainsRune("<>=!~", rune(c)):
+			j := i
+			for j < len(s) && strings.ContainsRune("<>=!~", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, markerToken{text: s[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, markerToken{text: s[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+type markerParser struct {
+	tokens []markerToken
+	pos    int
+	env    map[string]string
+}
+
+func (p *markerParser) peek(text string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && p.tokens[p.pos].text == text
+}
+
+func (p *markerParser) or() (bool, error) {
+	result, err := p.and()
+	for err == nil && p.peek("or") {
+		p.pos++
+		var right bool
+		right, err = p.and()
+		result = result || right
+	}
+	return result, err
+}
+
+func (p *markerParser) and() (bool, error) {
+	result, err := p.expr()
+	for err == nil && p.peek("and") {
+		p.pos++
+		var right bool
+		right, err = p.expr()
+		result = result && right
+	}
+	return result, err
+}
+
+func (p *markerParser) expr() (bool, error) {
+	if p.peek("(") {
+		p.pos++
+		result, err := p.or()
+		if err != nil {
+			return false, err
+		}
+		if !p.peek(")") {
+			return false, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return result, nil
+	}
+
+	left, err := p.value()
+	if err != nil {
+		return false, err
+	}
+	op, err := p.operator()
+	if err != nil {
+		return false, err
+	}
+	right, err := p.value()
+	if err != nil {
+		return false, err
+	}
+	return compareMarker(left, op, right), nil
+}
+
+func (p *markerParser) value() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("unexpected end")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	if t.quoted {
+		return t.text, nil
+	}
+	if !markerVariables[t.text] {
+		return "", fmt.Errorf("unknown variable %q", t.text)
+	}
+	return p.env[t.text], nil
+}
+
+func (p *markerParser) operator() (string, error) {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return "", fmt.Errorf("expected a comparison")
+	}
+	op := p.tokens[p.pos].text
+	p.pos++
+	switch op {
+	case "in", "<", "<=", "==", "!=", ">=", ">", "~=", "===":
+		return op, nil
+	case "not":
+		if p.peek("in") {
+			p.pos++
+			return "not in", nil
+		}
+	}
+	return "", fmt.Errorf("unknown operator %q", op)
+}
+
+// compareMarker compares two marker values, as versions when both parse
+// as such and as strings otherwise
+func compareMarker(left, op, right string) bool {
+	switch op {
+	case "in":
+		return strings.Contains(right, left)
+	case "not in":
+		return !strings.Contains(right, left)
+	}
+
+	spec := Specifier{Op: op, Version: right}
+	if v, err := ParseVersion(left); err == nil {
+		if op == "===" {
+			return spec.Allows(v)
+		}
+		if _, err := spec.parse(); err == nil {
+			return spec.Allows(v)
+		}
+	}
+
+	c := strings.Compare(left, right)
+	switch op {
+	case "==", "===":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
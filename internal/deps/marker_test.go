@@ -0,0 +1,43 @@
+package deps
+
+import "testing"
+
+func TestEvaluateMarker(t *testing.T) {
+	env := map[string]string{
+		"python_version":      "3.11",
+		"python_full_version": "3.11.7",
+		"sys_platform":        "linux",
+		"platform_machine":    "x86_64",
+		"os_name":             "posix",
+	}
+	tests := []struct {
+		marker string
+		want   bool
+	}{
+		{`python_version < "3.12"`, true},
+		{`python_version >= "3.9" and sys_platform == "win32"`, false},
+		{`sys_platform == "win32" or sys_platform == 'linux'`, true},
+		{`(os_name == "nt" or platform_machine == "x86_64") and python_full_version != "3.11.7"`, false},
+		{`python_version > "3.9"`, true},
+		{`"arm" in platform_machine`, false},
+		{`platform_machine not in "arm64 aarch64"`, true},
+		{`extra == "dev"`, false},
+		{`python_version ~= "3.10"`, true},
+	}
+	for _, tt := range tests {
+		got, err := EvaluateMarker(tt.marker, env)
+		if err != nil {
+			t.Errorf("%s: %v", tt.marker, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.marker, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`python_version <`, `unknown_var == "x"`, `(python_version == "3.11"`, `python_version = "3.11"`, `"a" "b"`} {
+		if _, err := EvaluateMarker(bad, env); err == nil {
+			t.Errorf("EvaluateMarker(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package deps
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/snupai/cngt-cli/internal/cngt/progress"
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+// States of a required package in the managed environment
+const (
+	StateOK     = "ok"
+	StateTooOld = "too old"
+	StateTooNew = "too new"
+	// StateExcluded is a version ruled out by a != clause
+	StateExcluded = "excluded"
+	StateMissing  = "missing"
+)
+
+// Package is the state of a required package in the managed environment
+type Package struct {
+	Requirement Requirement
+	// Installed is the installed version, empty when the package is missing
+	Installed string
+	State     string
+}
+
+// versionScript prints the installed versions of the distributions named
+// in its arguments, null for those that are not installed, and the values
+// of the environment marker variables as JSON
+const versionScript = `import json, os, platform, sys
+from importlib import metadata
+
+def version(info):
+    v = "%d.%d.%d" % (info.major, info.minor, info.micro)
+    if info.releaselevel != "final":
+        v += info.releaselevel[0] + str(info.serial)
+    return v
+
+versions = {}
+for name in sys.argv[1:]:
+    try:
+        versions[name] = metadata.version(name)
+    except metadata.PackageNotFoundError:
+        versions[name] = None
+env = {
+    "implementation_name": sys.implementation.name,
+    "implementation_version": version(sys.implementation.version),
+    "os_name": os.name,
+    "platform_machine": platform.machine(),
+    "platform_python_implementation": platform.python_implementation(),
+    "platform_release": platform.release(),
+    "platform_system": platform.system(),
+    "platform_version": platform.version(),
+    "python_full_version": platform.python_version(),
+    "python_version": ".".join(platform.python_version_tuple()[:2]),
+    "sys_platform": sys.platform,
+}
+print(json.dumps({"versions": versions, "env": env}))
+`
+
+// CheckEnvironment checks the requirements of the checkout against the
+// managed environment
+func CheckEnvironment(cfg *config.Config) (*Requirements, []Package, error) {
+	reqs, err := LoadRequirements(cfg.CNGTPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkgs, err := CheckPackages(VenvPython(cfg.VenvPath), reqs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reqs, pkgs, nil
+}
+
+// CheckPackages looks up the installed version of every requirement in the
+// environment of python. Requirements whose marker does not hold there are
+// left out.
+func CheckPackages(python string, reqs *Requirements) ([]Package, error) {
+	args := []string{"-c", versionScript}
+	for _, req := range reqs.List {
+		args = append(args, req.Name)
+	}
+	out, err := exec.Command(python, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query installed packages: %w", err)
+	}
+	var result struct {
+		Versions map[string]*string `json:"versions"`
+		Env      map[string]string  `json:"env"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("failed to query installed packages: %w", err)
+	}
+
+	var pkgs []Package
+	for _, req := range reqs.List {
+		if req.Marker != "" {
+			ok, err := EvaluateMarker(req.Marker, result.Env)
+			if err != nil {
+				return nil, fmt.Errorf("requirement %s: %w", req.Name, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		pkg := Package{Requirement: req}
+		if v := result.Versions[req.Name]; v != nil {
+			pkg.Installed = *v
+		}
+		pkg.State = packageState(req, pkg.Installed)
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// parseRequirements parses dependency specifications such as
+// colorama>=0.4.6
+func parseRequirements(specs []string) ([]Requirement, error) {
+	reqs := make([]Requirement, 0, len(specs))
+	for _, spec := range specs {
+		req, err := ParseRequirement(spec)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// packageState compares an installed version against a requirement.
+// Versions that cannot be parsed only satisfy requirements without
+// clauses.
+func packageState(req Requirement, installed string) string {
+	if installed == "" {
+		return StateMissing
+	}
+	v, err := ParseVersion(installed)
+	if err != nil {
+		if len(req.Specifiers) == 0 {
+			return StateOK
+		}
+		return StateTooOld
+	}
+	for _, s := range req.Specifiers {
+		if s.Allows(v) {
+			continue
+		}
+		if s.Op == "!=" {
+			return StateExcluded
+		}
+		// Any other clause rejects v because it lies below or above the
+		// clause's version, for wildcards and ~= below or above its prefix
+		if spec, err := s.parse(); err == nil && v.Compare(spec) < 0 {
+			return StateTooOld
+		}
+		return StateTooNew
+	}
+	return StateOK
+}
+
+// PackagesOK reports whether every package is in StateOK
+func PackagesOK(pkgs []Package) bool {
+	for _, p := range pkgs {
+		if p.State != StateOK {
+			return false
+		}
+	}
+	return true
+}
+
+// PrintPackages writes one line per package with its installed and
+// required version
+func PrintPackages(w io.Writer, indent string, pkgs []Package) {
+	for _, p := range pkgs {
+		mark := "✓"
+		if p.State != StateOK {
+			mark = "✗"
+		}
+		installed := p.Installed
+		if installed == "" {
+			installed = "-"
+		}
+		fmt.Fprintf(w, "%s%s %-14s %-10s installed %-10s required %s\n", indent, mark, p.Requirement.Name, p.State, installed, p.Requirement.SpecifierString())
+	}
+}
+
+// Upgrade installs the packages that are not in StateOK into the managed
+// environment, leaving the others untouched
+func Upgrade(pkgs []Package) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var specs []string
+	for _, p := range pkgs {
+		if p.State != StateOK {
+			specs = append(specs, p.Requirement.spec())
+		}
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+
+	p := progress.FromConfig()
+	p.Start("deps", "Upgrading Python packages")
+	err = upgradePackages(cfg, isUvAvailable(), specs, p)
+	p.Done("deps", err)
+	return err
+}
+
+// Sync installs or upgrades the packages that do not satisfy the
+// requirements of the checkout, creating the environment if needed
+func Sync() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !HasVenv(cfg.VenvPath) {
+		return Install()
+	}
+	_, pkgs, err := CheckEnvironment(cfg)
+	if err != nil {
+		return err
+	}
+	return Upgrade(pkgs)
+}
@@ -0,0 +1,444 @@
+package deps
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionPattern is the version scheme of PEP 440, including the
+// alternative spellings it allows for pre, post and dev releases
+var versionPattern = regexp.MustCompile(`^v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?:[-_.]?(?P<pre_l>alpha|a|beta|b|preview|pre|c|rc)[-_.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?:-(?P<post_n1>[0-9]+)|[-_.]?(?P<post_l>post|rev|r)[-_.]?(?P<post_n2>[0-9]+)?)?` +
+	`(?:[-_.]?(?P<dev_l>dev)[-_.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_.][a-z0-9]+)*))?$`)
+
+// Phases of a release, ordered the way they sort
+const (
+	phaseDevOnly = iota - 1 // a dev release of a final release, e.g. 1.0.dev1
+	phaseAlpha
+	phaseBeta
+	phaseRC
+	phaseFinal
+)
+
+// Version is a parsed PEP 440 version
+type Version struct {
+	Epoch   int
+	Release []int
+	// phase and pre are the pre-release phase and number
+	phase int
+	pre   int
+	// post is -1 for versions that are not post releases
+	post int
+	// dev is -1 for versions that are not dev releases
+	dev   int
+	local []string
+	raw   string
+}
+
+// ParseVersion parses a version such as 1.2, 2!1.0rc1 or 42.0.5.post1+local
+func ParseVersion(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	group := func(name string) string {
+		return m[versionPattern.SubexpIndex(name)]
+	}
+	number := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	v := Version{Epoch: number(group("epoch")), phase: phaseFinal, post: -1, dev: -1, raw: strings.TrimSpace(s)}
+	for _, part := range strings.Split(group("release"), ".") {
+		v.Release = append(v.Release, number(part))
+	}
+	switch group("pre_l") {
+	case "":
+	case "a", "alpha":
+		v.phase, v.pre = phaseAlpha, number(group("pre_n"))
+	case "b", "beta":
+		v.phase, v.pre = phaseBeta, number(group("pre_n"))
+	default:
+		v.phase, v.pre = phaseRC, number(group("pre_n"))
+	}
+	if n := group("post_n1"); n != "" {
+		v.post = number(n)
+	} else if group("post_l") != "" {
+		v.post = number(group("post_n2"))
+	}
+	if group("dev_l") != "" {
+		v.dev = number(group("dev_n"))
+		if v.phase == phaseFinal && v.post < 0 {
+			v.phase = phaseDevOnly
+		}
+	}
+	if local := group("local"); local != "" {
+		v.local = strings.FieldsFunc(local, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	}
+	return v, nil
+}
+
+// String returns the version as it was written
+func (v Version) String() string {
+	return v.raw
+}
+
+// IsPrerelease reports whether v is an alpha, beta, rc or dev release
+func (v Version) IsPrerelease() bool {
+	return v.phase != phaseFinal || v.dev >= 0
+}
+
+// Compare returns -1, 0 or 1 when v sorts before, equal to or after o
+func (v Version) Compare(o Version) int {
+	if c := compareInt(v.Epoch, o.Epoch); c != 0 {
+		return c
+	}
+	if c := compareRelease(v.Release, o.Release); c != 0 {
+		return c
+	}
+	return v.compareSuffix(o)
+}
+
+// compareSuffix compares the pre, post, dev and local parts
+func (v Version) compareSuffix(o Version) int {
+	if c := compareInt(v.phase, o.phase); c != 0 {
+		return c
+	}
+	if c := compareInt(v.pre, o.pre); c != 0 {
+		return c
+	}
+	if c := compareInt(v.post, o.post); c != 0 {
+		return c
+	}
+	// Not being a dev release sorts after every dev release
+	if c := compareInt(devKey(v.dev), devKey(o.dev)); c != 0 {
+		return c
+	}
+	return compareLocal(v.local, o.local)
+}
+
+func devKey(dev int) int {
+	if dev < 0 {
+		return int(^uint(0) >> 1)
+	}
+	return dev
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareRelease compares release segments, padding the shorter one with
+// zeros so that 1.0 equals 1.0.0
+func compareRelease(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := compareInt(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareLocal compares local version labels. Numeric segments sort after
+// alphanumeric ones and a longer label sorts after its prefix.
+func compareLocal(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		switch {
+		case errX == nil && errY == nil:
+			if c := compareInt(x, y); c != 0 {
+				return c
+			}
+		case errX == nil:
+			return 1
+		case errY == nil:
+			return -1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(a), len(b))
+}
+
+// Specifier is a single version clause such as >=42.0.5 or ==1.*
+type Specifier struct {
+	Op      string
+	Version string
+}
+
+// specifierOps are the PEP 440 comparison operators, two character ones
+// first so that they match before their prefixes
+var specifierOps = []string{"===", "~=", "==", "!=", "<=", ">=", "<", ">"}
+
+// ParseSpecifiers parses a comma separated list of version clauses. An
+// empty string allows any version.
+func ParseSpecifiers(s string) ([]Specifier, error) {
+	var specs []Specifier
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	for _, clause := range strings.Split(s, ",") {
+		clause = strings.TrimSpace(clause)
+		spec := Specifier{}
+		for _, op := range specifierOps {
+			if strings.HasPrefix(clause, op) {
+				spec = Specifier{Op: op, Version: strings.TrimSpace(clause[len(op):])}
+				break
+			}
+		}
+		if spec.Op == "" || spec.Version == "" {
+			return nil, fmt.Errorf("invalid version specifier %q", clause)
+		}
+		if spec.Op != "===" {
+			if _, err := spec.parse(); err != nil {
+				return nil, fmt.Errorf("invalid version specifier %q: %w", clause, err)
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// String returns the clause as written in requirements, e.g. >=42.0.5
+func (s Specifier) String() string {
+	return s.Op + s.Version
+}
+
+// wildcard reports whether s is a prefix match such as ==1.*
+func (s Specifier) wildcard() bool {
+	return (s.Op == "==" || s.Op == "!=") && strings.HasSuffix(s.Version, ".*")
+}
+
+// parse returns the version of the clause, without a trailing .*
+func (s Specifier) parse() (Version, error) {
+	v, err := ParseVersion(strings.TrimSuffix(s.Version, ".*"))
+	if err != nil {
+		return v, err
+	}
+	if s.Op == "~=" && len(v.Release) < 2 {
+		return v, fmt.Errorf("~= needs at least two release segments")
+	}
+	return v, nil
+}
+
+// Allows reports whether v satisfies the clause. Pre-releases are allowed
+// since an installed version has been chosen already.
+func (s Specifier) Allows(v Version) bool {
+	if s.Op == "===" {
+		return strings.EqualFold(s.Version, v.raw)
+	}
+	spec, err := s.parse()
+	if err != nil {
+		return false
+	}
+	public := v
+	public.local = nil
+
+	switch s.Op {
+	case "==", "!=":
+		var equal bool
+		if s.wildcard() {
+			equal = v.Epoch == spec.Epoch && prefixMatch(v.Release, spec.Release)
+		} else if len(spec.local) == 0 {
+			equal = public.Compare(spec) == 0
+		} else {
+			equal = v.Compare(spec) == 0
+		}
+		return equal == (s.Op == "==")
+	case "~=":
+		prefix := Specifier{Op: "==", Version: joinRelease(spec.Release[:len(spec.Release)-1]) + ".*"}
+		if spec.Epoch != 0 {
+			prefix.Version = strconv.Itoa(spec.Epoch) + "!" + prefix.Version
+		}
+		return public.Compare(spec) >= 0 && prefix.Allows(v)
+	case "<=":
+		return public.Compare(spec) <= 0
+	case ">=":
+		return public.Compare(spec) >= 0
+	case "<":
+		// <2.0 excludes 2.0rc1 unless the clause itself is a pre-release
+		if !spec.IsPrerelease() && v.IsPrerelease() && sameRelease(v, spec) {
+			return false
+		}
+		return public.Compare(spec) < 0
+	case ">":
+		// >1.0 excludes 1.0.post1 unless the clause itself is a post-release
+		if spec.post < 0 && v.post >= 0 && sameRelease(v, spec) {
+			return false
+		}
+		return public.Compare(spec) > 0
+	}
+	return false
+}
+
+// prefixMatch reports whether release starts with prefix, padding release
+// with zeros so that 1 matches 1.0.*
+func prefixMatch(release, prefix []int) bool {
+	for i, p := range prefix {
+		n := 0
+		if i < len(release) {
+			n = release[i]
+		}
+		if n != p {
+			return false
+		}
+	}
+	return true
+}
+
+func sameRelease(a, b Version) bool {
+	return a.Epoch == b.Epoch && compareRelease(a.Release, b.Release) == 0
+}
+
+func joinRelease(release []int) string {
+	parts := make([]string, len(release))
+	for i, n := range release {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// Requirement is a parsed dependency such as colorama>=0.4.6
+type Requirement struct {
+	// Name is the distribution name as written
+	Name       string
+	Extras     []string
+	Specifiers []Specifier
+	// URL is set for direct references such as name @ https://...
+	URL string
+	// Marker is the environment marker after the semicolon, unevaluated
+	Marker string
+}
+
+var requirementName = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?`)
+
+// ParseRequirement parses a PEP 508 dependency specification
+func ParseRequirement(s string) (Requirement, error) {
+	var req Requirement
+	rest := strings.TrimSpace(s)
+	if i := strings.Index(rest, ";"); i >= 0 {
+		req.Marker = strings.TrimSpace(rest[i+1:])
+		rest = strings.TrimSpace(rest[:i])
+	}
+
+	req.Name = requirementName.FindString(rest)
+	if req.Name == "" {
+		return req, fmt.Errorf("invalid requirement %q: missing package name", s)
+	}
+	rest = strings.TrimSpace(rest[len(req.Name):])
+
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return req, fmt.Errorf("invalid requirement %q: unterminated extras", s)
+		}
+		for _, extra := range strings.Split(rest[1:end], ",") {
+			if extra = strings.TrimSpace(extra); extra != "" {
+				req.Extras = append(req.Extras, extra)
+			}
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+
+	if strings.HasPrefix(rest, "@") {
+		req.URL = strings.TrimSpace(rest[1:])
+		if req.URL == "" {
+			return req, fmt.Errorf("invalid requirement %q: missing URL", s)
+		}
+		return req, nil
+	}
+
+	if strings.HasPrefix(rest, "(") && strings.HasSuffix(rest, ")") {
+		rest = rest[1 : len(rest)-1]
+	}
+	specs, err := ParseSpecifiers(rest)
+	if err != nil {
+		return req, fmt.Errorf("invalid requirement %q: %w", s, err)
+	}
+	req.Specifiers = specs
+	return req, nil
+}
+
+// Key returns the normalized distribution name, so that Foo_Bar and
+// foo-bar compare equal
+func (r Requirement) Key() string {
+	return NormalizeName(r.Name)
+}
+
+// NormalizeName normalizes a distribution name as described in PEP 503
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	}), "-"))
+}
+
+// SpecifierString returns the version clauses, e.g. >=0.4.6, or "any"
+// when there are none
+func (r Requirement) SpecifierString() string {
+	if len(r.Specifiers) == 0 {
+		return "any"
+	}
+	parts := make([]string, len(r.Specifiers))
+	for i, s := range r.Specifiers {
+		parts[i] = s.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Allows reports whether v satisfies every clause of the requirement
+func (r Requirement) Allows(v Version) bool {
+	for _, s := range r.Specifiers {
+		if !s.Allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the requirement in PEP 508 form, including its marker
+func (r Requirement) String() string {
+	if r.Marker != "" {
+		return r.spec() + "; " + r.Marker
+	}
+	return r.spec()
+}
+
+// spec returns the requirement without its marker, in the form pip and uv
+// accept on the command line
+func (r Requirement) spec() string {
+	if r.URL != "" {
+		return r.Name + " @ " + r.URL
+	}
+	spec := r.Name
+	if len(r.Extras) > 0 {
+		spec += "[" + strings.Join(r.Extras, ",") + "]"
+	}
+	if len(r.Specifiers) > 0 {
+		spec += r.SpecifierString()
+	}
+	return spec
+}
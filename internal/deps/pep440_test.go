@@ -0,0 +1,119 @@
+package deps
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	// Each version sorts strictly after the one before it
+	ordered := []string{
+		"1.0.dev1", "1.0a1", "1.0a2.dev1", "1.0a2", "1.0b1", "1.0rc1", "1.0",
+		"1.0+local", "1.0.post1.dev1", "1.0.post1", "1.0.1", "1.1", "42.0.5", "1!0.1",
+	}
+	for i := 1; i < len(ordered); i++ {
+		a, err := ParseVersion(ordered[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseVersion(ordered[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Compare(b) >= 0 || b.Compare(a) <= 0 {
+			t.Errorf("expected %s < %s", a, b)
+		}
+	}
+
+	equal := [][2]string{{"1.0", "1.0.0"}, {"1.0RC1", "1.0rc1"}, {"1.0-1", "1.0.post1"}, {"v2.0alpha", "2.0a0"}}
+	for _, pair := range equal {
+		a, _ := ParseVersion(pair[0])
+		b, _ := ParseVersion(pair[1])
+		if a.Compare(b) != 0 {
+			t.Errorf("expected %s == %s", pair[0], pair[1])
+		}
+	}
+
+	for _, bad := range []string{"", "latest", "1.0-beta-1-", "1..0"} {
+		if _, err := ParseVersion(bad); err == nil {
+			t.Errorf("ParseVersion(%q) should fail", bad)
+		}
+	}
+}
+
+func TestSpecifierAllows(t *testing.T) {
+	tests := []struct {
+		spec    string
+		version string
+		want    bool
+	}{
+		{">=42.0.5", "42.0.5", true},
+		{">=42.0.5", "43.0.0", true},
+		{">=42.0.5", "41.0.7", false},
+		{">=0.4.6", "0.4.6+local", true},
+		{"<2", "1.9", true},
+		{"<2", "2.0", false},
+		{"<2", "2.0rc1", false},
+		{"<2.0rc2", "2.0rc1", true},
+		{">1.0", "1.0.post1", false},
+		{">1.0", "1.0.1", true},
+		{"==1.*", "1.5", true},
+		{"==1.*", "2.0", false},
+		{"!=1.5.*", "1.5.3", false},
+		{"==1.0", "1.0.0", true},
+		{"==1.0", "1.0+local", true},
+		{"==1.0+local", "1.0", false},
+		{"~=1.4.2", "1.4.9", true},
+		{"~=1.4.2", "1.5.0", false},
+		{"~=1.4", "1.9", true},
+		{"~=1.4", "2.0", false},
+		{">=1.0,<2.0,!=1.3", "1.3", false},
+		{">=1.0,<2.0,!=1.3", "1.4", true},
+		{"===1.0-custom", "1.0-custom", true},
+	}
+	for _, tt := range tests {
+		req, err := ParseRequirement("pkg" + tt.spec)
+		if err != nil {
+			t.Fatalf("ParseRequirement(%q) failed: %v", tt.spec, err)
+		}
+		v, err := ParseVersion(tt.version)
+		if strings.HasPrefix(tt.spec, "===") {
+			v = Version{raw: tt.version}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Allows(v); got != tt.want {
+			t.Errorf("%s allows %s = %v, want %v", tt.spec, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseRequirement(t *testing.T) {
+	req, err := ParseRequirement(`Foo_Bar[socks, security] (>=1.0, <2) ; python_version < "3.12"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Name != "Foo_Bar" || req.Key() != "foo-bar" {
+		t.Errorf("unexpected name %q (key %q)", req.Name, req.Key())
+	}
+	if len(req.Extras) != 2 || req.Extras[0] != "socks" || req.Extras[1] != "security" {
+		t.Errorf("unexpected extras %v", req.Extras)
+	}
+	if req.SpecifierString() != ">=1.0,<2" {
+		t.Errorf("unexpected specifiers %q", req.SpecifierString())
+	}
+	if req.Marker != `python_version < "3.12"` {
+		t.Errorf("unexpected marker %q", req.Marker)
+	}
+
+	req, err = ParseRequirement("glyphs @ https://example.com/glyphs-1.0.tar.gz")
+	if err != nil || req.URL != "https://example.com/glyphs-1.0.tar.gz" {
+		t.Errorf("unexpected direct reference %+v (%v)", req, err)
+	}
+
+	for _, bad := range []string{"", ">=1.0", "pkg[extra", "pkg>=", "pkg~=1", "pkg=>1.0"} {
+		if _, err := ParseRequirement(bad); err == nil {
+			t.Errorf("ParseRequirement(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package deps
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Files in the CNGT checkout that declare its Python dependencies
+const (
+	RequirementsFile = "requirements.txt"
+	PyprojectFile    = "pyproject.toml"
+)
+
+// maxIncludeDepth limits nested -r includes in requirements files
+const maxIncludeDepth = 10
+
+// Requirements are the Python dependencies of a CNGT checkout
+type Requirements struct {
+	// Source names the files the list was read from, or "built-in list"
+	// when the checkout declares no dependencies
+	Source string
+	List   []Requirement
+	// file is the requirements.txt that was read, passed to pip with -r so
+	// that its options, such as index URLs, apply as well
+	file string
+	// project are the pyproject.toml requirements missing from file
+	project []Requirement
+}
+
+// LoadRequirements reads the requirements.txt and the [project]
+// dependencies of the pyproject.toml in dir. Requirements that appear in
+// both files must satisfy both. When neither file exists the built-in
+// list is used.
+func LoadRequirements(dir string) (*Requirements, error) {
+	reqs := &Requirements{}
+	var sources []string
+
+	path := filepath.Join(dir, RequirementsFile)
+	if _, err := os.Stat(path); err == nil {
+		list, err := ReadRequirementsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		reqs.file = path
+		reqs.List = mergeRequirements(nil, list)
+		sources = append(sources, RequirementsFile)
+	}
+
+	path = filepath.Join(dir, PyprojectFile)
+	if data, err := os.ReadFile(path); err == nil && !GeneratedPyproject(data) {
+		specs, found, err := pyprojectDependencies(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if found {
+			list, err := parseRequirements(specs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			known := map[string]bool{}
+			for _, req := range reqs.List {
+				known[req.Key()] = true
+			}
+			for _, req := range list {
+				if !known[req.Key()] {
+					reqs.project = append(reqs.project, req)
+				}
+			}
+			reqs.List = mergeRequirements(reqs.List, list)
+			sources = append(sources, PyprojectFile)
+		}
+	}
+
+	if len(sources) == 0 {
+		list, err := parseRequirements(requiredPackages)
+		if err != nil {
+			return nil, err
+		}
+		reqs.List = list
+		reqs.project = list
+		reqs.Source = "built-in list"
+		return reqs, nil
+	}
+	reqs.Source = strings.Join(sources, " and ")
+	return reqs, nil
+}
+
+// GeneratedPyproject reports whether a pyproject.toml is the template of
+// 'uv init', which older releases ran inside the checkout. Its dependencies
+// were added by the CLI and are not the project's own.
+func GeneratedPyproject(data []byte) bool {
+	return strings.Contains(string(data), `description = "Add your description here"`)
+}
+
+// mergeRequirements appends reqs to list, combining the clauses of
+// requirements for the same package and marker
+func mergeRequirements(list, reqs []Requirement) []Requirement {
+	for _, req := range reqs {
+		merged := false
+		for i := range list {
+			if list[i].Key() == req.Key() && list[i].Marker == req.Marker && list[i].URL == "" && req.URL == "" {
+				list[i].Specifiers = append(list[i].Specifiers, req.Specifiers...)
+				list[i].Extras = append(list[i].Extras, req.Extras...)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			list = append(list, req)
+		}
+	}
+	return list
+}
+
+// installArgs are the arguments to pip install or pip download that
+// install the requirements
+func (r *Requirements) installArgs() []string {
+	var args []string
+	if r.file != "" {
+		args = append(args, "-r", r.file)
+	}
+	for _, req := range r.project {
+		args = append(args, req.String())
+	}
+	return args
+}
+
+// Strings returns every requirement as it would be written in a
+// requirements file
+func (r *Requirements) Strings() []string {
+	specs := make([]string, len(r.List))
+	for i, req := range r.List {
+		specs[i] = req.String()
+	}
+	return specs
+}
+
+// DiffRequirements lists the requirements only in old, prefixed with "-",
+// and those only in new, prefixed with "+"
+func DiffRequirements(old, new *Requirements) []string {
+	count := map[string]int{}
+	for _, s := range old.Strings() {
+		count[s]--
+	}
+	for _, s := range new.Strings() {
+		count[s]++
+	}
+
+	var diff []string
+	for s, n := range count {
+		if n < 0 {
+			diff = append(diff, "- "+s)
+		} else if n > 0 {
+			diff = append(diff, "+ "+s)
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		if diff[i][2:] != diff[j][2:] {
+			return diff[i][2:] < diff[j][2:]
+		}
+		return diff[i] < diff[j]
+	})
+	return diff
+}
+
+// requirementComment matches a comment, which pip only recognizes at the
+// start of a line or after whitespace
+var requirementComment = regexp.MustCompile(`(^|\s+)#.*$`)
+
+// ReadRequirementsFile parses a pip requirements file. Nested -r files are
+// followed, pip options, editable installs and requirements given only as
+// a path or URL are skipped since they cannot be checked.
+func ReadRequirementsFile(path string) ([]Requirement, error) {
+	return readRequirementsFile(path, 0)
+}
+
+func readRequirementsFile(path string, depth int) ([]Requirement, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("failed to read %s: too many nested -r includes", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var reqs []Requirement
+	var line string
+	start, n := 0, 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+		if line == "" {
+			start = n
+		}
+		text := requirementComment.ReplaceAllString(scanner.Text(), "")
+		// A trailing backslash continues the line
+		if strings.HasSuffix(text, "\\") {
+			line += strings.TrimSuffix(text, "\\")
+			continue
+		}
+		line = strings.TrimSpace(line + text)
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "-r ") || strings.HasPrefix(line, "--requirement"):
+			include := strings.TrimSpace(strings.TrimLeft(strings.TrimPrefix(strings.TrimPrefix(line, "--requirement"), "-r"), "= "))
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(path), include)
+			}
+			nested, err := readRequirementsFile(include, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			reqs = append(reqs, nested...)
+		case strings.HasPrefix(line, "-"), strings.HasPrefix(line, "."), strings.HasPrefix(line, "/"),
+			strings.Contains(line, "://") && !strings.Contains(line, "@"):
+		default:
+			req, err := ParseRequirement(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, start, err)
+			}
+			reqs = append(reqs, req)
+		}
+		line = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return reqs, nil
+}
+
+// pyprojectDependencies extracts the dependencies array of the [project]
+// table. found is false when the table has no such array, e.g. because the
+// dependencies are dynamic.
+func pyprojectDependencies(data string) (specs []string, found bool, err error) {
+	table := ""
+	for i := 0; i < len(data); {
+		end := strings.IndexByte(data[i:], '\n')
+		if end < 0 {
+			end = len(data) - i
+		}
+		line := strings.TrimSpace(data[i : i+end])
+		next := i + end + 1
+
+		switch {
+		case strings.HasPrefix(line, "["):
+			table = strings.TrimSpace(strings.Trim(stripTOMLComment(line), "[]"))
+		case table == "project":
+			key, _, ok := strings.Cut(line, "=")
+			if ok && strings.Trim(strings.TrimSpace(key), `"'`) == "dependencies" {
+				offset := i + strings.Index(data[i:], "=") + 1
+				specs, err := tomlStringArray(data[offset:])
+				if err != nil {
+					return nil, false, fmt.Errorf("invalid [project] dependencies: %w", err)
+				}
+				return specs, true, nil
+			}
+		}
+		i = next
+	}
+	return nil, false, nil
+}
+
+// stripTOMLComment removes a trailing comment from a line without strings
+func stripTOMLComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// tomlStringArray parses an array of strings at the start of s, which may
+// span several lines and contain comments
+func tomlStringArray(s string) ([]string, error) {
+	s = strings.TrimLeft(s, " \t")
+	if !strings.HasPrefix(s, "[") {
+		return nil, fmt.Errorf("expected an array")
+	}
+	var values []string
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t', '\r', '\n', ',':
+		case '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case ']':
+			return values, nil
+		case '"', '\'':
+			// Multi-line strings are not used for dependencies
+			var value strings.Builder
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\n' {
+					return nil, fmt.Errorf("unterminated string")
+				}
+				if c == '"' && s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			values = append(values, value.String())
+		default:
+			return nil, fmt.Errorf("unexpected %q in array", c)
+		}
+	}
+	return nil, fmt.Errorf("unterminated array")
+}
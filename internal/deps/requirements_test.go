@@ -0,0 +1,144 @@
+package deps
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadRequirementsFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "base.txt"), "mido>=1.3 # MIDI input\n")
+	writeFile(t, filepath.Join(dir, RequirementsFile), `# CNGT dependencies
+--index-url https://pypi.org/simple
+-r base.txt
+termcolor
+colorama>=0.4.6 ; sys_platform == "win32"
+cryptography>=42.0.5,\
+    <46
+requests[socks]==2.31.*
+-e ./vendor/tool
+./local-package
+https://example.com/pkg-1.0.tar.gz
+glyphs @ https://example.com/glyphs-1.0.tar.gz#sha256=abc
+`)
+
+	reqs, err := ReadRequirementsFile(filepath.Join(dir, RequirementsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, req := range reqs {
+		got = append(got, req.String())
+	}
+	want := []string{
+		"mido>=1.3",
+		"termcolor",
+		`colorama>=0.4.6; sys_platform == "win32"`,
+		"cryptography>=42.0.5,<46",
+		"requests[socks]==2.31.*",
+		"glyphs @ https://example.com/glyphs-1.0.tar.gz#sha256=abc",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	writeFile(t, filepath.Join(dir, "bad.txt"), "termcolor\n\nmido>=\n")
+	if _, err := ReadRequirementsFile(filepath.Join(dir, "bad.txt")); err == nil || !strings.Contains(err.Error(), "bad.txt:3") {
+		t.Errorf("expected an error pointing at bad.txt:3, got %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "loop.txt"), "-r loop.txt\n")
+	if _, err := ReadRequirementsFile(filepath.Join(dir, "loop.txt")); err == nil {
+		t.Error("expected an error for recursive includes")
+	}
+}
+
+func TestPyprojectDependencies(t *testing.T) {
+	specs, found, err := pyprojectDependencies(`[build-system]
+requires = ["setuptools"]
+
+[project]
+name = "cngt"
+dependencies = [
+    "termcolor",  # colored output
+    'mido>=1.3',
+    "colorama>=0.4.6; sys_platform == \"win32\"",
+]
+
+[project.optional-dependencies]
+dev = ["pytest"]
+`)
+	if err != nil || !found {
+		t.Fatalf("found=%v err=%v", found, err)
+	}
+	want := []string{"termcolor", "mido>=1.3", `colorama>=0.4.6; sys_platform == "win32"`}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("got %q, want %q", specs, want)
+	}
+
+	if _, found, _ := pyprojectDependencies("[project]\nname = \"x\"\ndynamic = [\"dependencies\"]\n[tool.x]\ndependencies = [\"y\"]\n"); found {
+		t.Error("dependencies outside [project] should be ignored")
+	}
+	if _, _, err := pyprojectDependencies("[project]\ndependencies = [\"termcolor\"\n"); err == nil {
+		t.Error("expected an error for an unterminated array")
+	}
+}
+
+func TestLoadRequirements(t *testing.T) {
+	dir := t.TempDir()
+	reqs, err := LoadRequirements(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqs.Source != "built-in list" || len(reqs.List) != len(requiredPackages) {
+		t.Errorf("expected the built-in list, got %s with %v", reqs.Source, reqs.Strings())
+	}
+
+	writeFile(t, filepath.Join(dir, RequirementsFile), "termcolor\ncryptography>=42.0.5\n")
+	writeFile(t, filepath.Join(dir, PyprojectFile), "[project]\ndependencies = [\"cryptography<46\", \"mido\"]\n")
+	reqs, err = LoadRequirements(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqs.Source != "requirements.txt and pyproject.toml" {
+		t.Errorf("unexpected source %q", reqs.Source)
+	}
+	want := []string{"termcolor", "cryptography>=42.0.5,<46", "mido"}
+	if !reflect.DeepEqual(reqs.Strings(), want) {
+		t.Errorf("got %q, want %q", reqs.Strings(), want)
+	}
+	wantArgs := []string{"-r", filepath.Join(dir, RequirementsFile), "mido"}
+	if !reflect.DeepEqual(reqs.installArgs(), wantArgs) {
+		t.Errorf("install args %q, want %q", reqs.installArgs(), wantArgs)
+	}
+}
+
+func TestDiffRequirements(t *testing.T) {
+	parse := func(specs ...string) *Requirements {
+		list, err := parseRequirements(specs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Requirements{List: list}
+	}
+	old := parse("termcolor", "mido", "cryptography>=42.0.5")
+	new := parse("mido", "termcolor", "cryptography>=43", "colorama")
+
+	want := []string{"+ colorama", "- cryptography>=42.0.5", "+ cryptography>=43"}
+	if got := DiffRequirements(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := DiffRequirements(old, old); len(got) != 0 {
+		t.Errorf("expected no changes, got %q", got)
+	}
+}
@@ -0,0 +1,93 @@
+package deps
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/snupai/cngt-cli/internal/cngt/progress"
+	"github.com/snupai/cngt-cli/internal/config"
+	"github.com/snupai/cngt-cli/internal/python"
+)
+
+// VenvPython returns the interpreter of the environment at dir
+func VenvPython(dir string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(dir, "Scripts", "python.exe")
+	}
+	return filepath.Join(dir, "bin", "python")
+}
+
+// HasVenv reports whether the environment at dir has a working interpreter
+func HasVenv(dir string) bool {
+	return exec.Command(VenvPython(dir), "--version").Run() == nil
+}
+
+// ensureVenv creates the managed environment unless it works already and
+// meets the minimum Python version, with uv venv when useUv is set and
+// python -m venv otherwise
+func ensureVenv(cfg *config.Config, useUv bool, p *progress.Reporter) error {
+	if HasVenv(cfg.VenvPath) {
+		venv := python.Probe(VenvPython(cfg.VenvPath), "")
+		if venv.Err == nil && !venv.Version.Less(python.MinVersion(cfg)) {
+			return nil
+		}
+	}
+
+	base, err := python.Find(cfg)
+	if err != nil {
+		return err
+	}
+	// An environment whose base interpreter was removed or upgraded, or
+	// that is too old, is recreated from scratch
+	if err := os.RemoveAll(cfg.VenvPath); err != nil {
+		return fmt.Errorf("failed to remove broken Python environment: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.VenvPath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(cfg.VenvPath), err)
+	}
+
+	p.Log("deps", fmt.Sprintf("Creating Python environment in %s with Python %s", cfg.VenvPath, base.Version))
+	var cmd *exec.Cmd
+	if useUv {
+		cmd = exec.Command("uv", "venv", "--python", base.Command(), cfg.VenvPath)
+	} else {
+		cmd = exec.Command(base.Command(), "-m", "venv", cfg.VenvPath)
+	}
+	cmd.Stdout = p.Writer("deps")
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Run(); err != nil {
+		if !useUv {
+			return fmt.Errorf("failed to create Python environment (on Debian and Ubuntu, install python3-venv): %w", err)
+		}
+		return fmt.Errorf("failed to create Python environment: %w", err)
+	}
+	return nil
+}
+
+// ensurePip bootstraps pip in environments created without it, such as
+// those made by uv venv
+func ensurePip(python string, p *progress.Reporter) error {
+	if exec.Command(python, "-m", "pip", "--version").Run() == nil {
+		return nil
+	}
+	p.Log("deps", "Installing pip into the Python environment")
+	cmd := exec.Command(python, "-m", "ensurepip", "--upgrade")
+	cmd.Stdout = p.Writer("deps")
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to install pip into the Python environment: %w", err)
+	}
+	return nil
+}
+
+// RemoveVenv deletes the managed environment, e.g. so that it is recreated
+// from a different interpreter
+func RemoveVenv(cfg *config.Config) error {
+	if err := os.RemoveAll(cfg.VenvPath); err != nil {
+		return fmt.Errorf("failed to remove Python environment: %w", err)
+	}
+	return nil
+}
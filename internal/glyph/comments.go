@@ -0,0 +1,186 @@
+package glyph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Codecs of the audio stream
+const (
+	CodecOpus   = "opus"
+	CodecVorbis = "vorbis"
+)
+
+var (
+	opusHeadMagic   = []byte("OpusHead")
+	opusTagsMagic   = []byte("OpusTags")
+	vorbisHeadMagic = []byte("\x01vorbis")
+	vorbisTagsMagic = []byte("\x03vorbis")
+)
+
+// Field is a single KEY=value entry of a comment header
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Comments is the Vorbis comment block of an Opus or Vorbis stream. Fields
+// keep their order and the case of their keys.
+type Comments struct {
+	Vendor string
+	Fields []Field
+}
+
+// Get returns the first value of key, compared case-insensitively
+func (c *Comments) Get(key string) (string, bool) {
+	for _, f := range c.Fields {
+		if strings.EqualFold(f.Key, key) {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// streamInfo is what the identification header tells about the audio
+type streamInfo struct {
+	Codec      string
+	SampleRate uint32
+	// PreSkip is the number of Opus samples to drop at the start
+	PreSkip uint16
+}
+
+func parseIdentHeader(data []byte) (*streamInfo, error) {
+	switch {
+	case bytes.HasPrefix(data, opusHeadMagic):
+		if len(data) < 19 {
+			return nil, fmt.Errorf("truncated OpusHead header")
+		}
+		// Opus granule positions always count 48 kHz samples
+		return &streamInfo{Codec: CodecOpus, SampleRate: 48000, PreSkip: binary.LittleEndian.Uint16(data[10:12])}, nil
+	case bytes.HasPrefix(data, vorbisHeadMagic):
+		if len(data) < 30 {
+			return nil, fmt.Errorf("truncated Vorbis identification header")
+		}
+		return &streamInfo{Codec: CodecVorbis, SampleRate: binary.LittleEndian.Uint32(data[12:16])}, nil
+	}
+	return nil, fmt.Errorf("unsupported audio codec, expected Opus or Vorbis")
+}
+
+func parseComments(codec string, data []byte) (*Comments, error) {
+	magic := opusTagsMagic
+	if codec == CodecVorbis {
+		magic = vorbisTagsMagic
+	}
+	if !bytes.HasPrefix(data, magic) {
+		return nil, fmt.Errorf("missing %s comment header", codec)
+	}
+	r := &byteReader{data: data[len(magic):]}
+
+	vendor, err := r.string()
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment header: %w", err)
+	}
+	count, err := r.uint32()
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment header: %w", err)
+	}
+
+	c := &Comments{Vendor: vendor}
+	for i := uint32(0); i < count; i++ {
+		entry, err := r.string()
+		if err != nil {
+			return nil, fmt.Errorf("invalid comment %d: %w", i, err)
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid comment %d: missing '='", i)
+		}
+		c.Fields = append(c.Fields, Field{Key: key, Value: value})
+	}
+	return c, nil
+}
+
+type byteReader struct {
+	data []byte
+}
+
+func (r *byteReader) uint32() (uint32, error) {
+	if len(r.data) < 4 {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+	v := binary.LittleEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v, nil
+}
+
+func (r *byteReader) string() (string, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return "", err
+	}
+	if uint64(n) > uint64(len(r.data)) {
+		return "", fmt.Errorf("unexpected end of data")
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s, nil
+}
+
+// Set replaces the first value of key and removes any other, or appends
+// the field when the key is missing
+func (c *Comments) Set(key, value string) {
+	fields := c.Fields[:0]
+	found := false
+	for _, f := range c.Fields {
+		if strings.EqualFold(f.Key, key) {
+			if found {
+				continue
+			}
+			f.Value = value
+			found = true
+		}
+		fields = append(fields, f)
+	}
+	if !found {
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+	c.Fields = fields
+}
+
+// Delete removes every value of key
+func (c *Comments) Delete(key string) {
+	fields := c.Fields[:0]
+	for _, f := range c.Fields {
+		if !strings.EqualFold(f.Key, key) {
+			fields = append(fields, f)
+		}
+	}
+	c.Fields = fields
+}
+
+// encodeComments builds the comment header packet for codec
+func encodeComments(codec string, c *Comments) []byte {
+	magic := opusTagsMagic
+	if codec == CodecVorbis {
+		magic = vorbisTagsMagic
+	}
+
+	buf := append([]byte{}, magic...)
+	buf = appendString(buf, c.Vendor)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(c.Fields)))
+	for _, f := range c.Fields {
+		buf = appendString(buf, f.Key+"="+f.Value)
+	}
+	if codec == CodecVorbis {
+		// Vorbis comment headers end with a framing bit
+		buf = append(buf, 1)
+	}
+	return buf
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
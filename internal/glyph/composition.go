@@ -0,0 +1,291 @@
+// Package glyph reads glyph compositions, Opus or Vorbis .ogg ringtones
+// whose comment header carries the light data for Nothing phones.
+package glyph
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// FrameInterval is the time between two rows of light data
+	FrameInterval = 16666 * time.Microsecond
+	// MaxBrightness is the brightness of a fully lit zone
+	MaxBrightness = 4095
+)
+
+// Tags used by the Glyph Composer
+const (
+	TagTitle    = "TITLE"
+	TagAlbum    = "ALBUM"
+	TagAuthor   = "AUTHOR"
+	TagComposer = "COMPOSER"
+	TagCustom1  = "CUSTOM1"
+	TagCustom2  = "CUSTOM2"
+	// TagArtist names the person who made the composition, since AUTHOR
+	// holds the light data
+	TagArtist = "ARTIST"
+)
+
+// Composition is a decoded glyph ringtone
+type Composition struct {
+	Codec    string
+	Duration time.Duration
+	Comments *Comments
+
+	Title    string
+	Composer string
+	// Model is nil when neither COMPOSER nor the zone count identify it
+	Model *Model
+	// Columns is the number of zones per frame
+	Columns int
+	// Frames holds one brightness value (0-4095) per zone every FrameInterval
+	Frames [][]int
+	// Custom1 is the decoded CUSTOM1 payload used by the Glyph Composer app
+	Custom1 string
+}
+
+// ReadFile reads a composition from an .ogg file
+func ReadFile(path string) (*Composition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	c, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Read reads a composition from an Ogg stream
+func Read(r io.Reader) (*Composition, error) {
+	pages, err := readPages(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("empty Ogg file")
+	}
+
+	serial := pages[0].Serial
+	headers, err := readPackets(pages, serial, 2)
+	if err != nil {
+		return nil, err
+	}
+	info, err := parseIdentHeader(headers[0].Data)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := parseComments(info.Codec, headers[1].Data)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Composition{Codec: info.Codec, Comments: comments}
+	c.Duration = duration(pages, serial, info)
+	c.Title, _ = comments.Get(TagTitle)
+	c.Composer, _ = comments.Get(TagComposer)
+
+	author, ok := comments.Get(TagAuthor)
+	if !ok {
+		return nil, fmt.Errorf("not a glyph composition (no %s tag)", TagAuthor)
+	}
+	data, err := DecodePayload(author)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s tag: %w", TagAuthor, err)
+	}
+	if c.Frames, err = ParseFrames(data); err != nil {
+		return nil, fmt.Errorf("invalid %s tag: %w", TagAuthor, err)
+	}
+
+	if custom1, ok := comments.Get(TagCustom1); ok && custom1 != "" {
+		data, err := DecodePayload(custom1)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s tag: %w", TagCustom1, err)
+		}
+		c.Custom1 = string(data)
+	}
+
+	if len(c.Frames) > 0 {
+		c.Columns = len(c.Frames[0])
+	}
+	if custom2, ok := comments.Get(TagCustom2); ok {
+		n, err := parseColumns(custom2)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s tag: %w", TagCustom2, err)
+		}
+		if len(c.Frames) > 0 && n != c.Columns {
+			return nil, fmt.Errorf("%s declares %d zones but the light data has %d", TagCustom2, n, c.Columns)
+		}
+		c.Columns = n
+	}
+
+	c.Model = modelFromComposer(c.Composer)
+	if c.Model == nil {
+		c.Model = modelFromColumns(c.Columns)
+	}
+	return c, nil
+}
+
+// duration computes the audio length from the last granule position
+func duration(pages []page, serial uint32, info *streamInfo) time.Duration {
+	for i := len(pages) - 1; i >= 0; i-- {
+		p := pages[i]
+		if p.Serial != serial || p.Granule < 0 {
+			continue
+		}
+		samples := p.Granule - int64(info.PreSkip)
+		if samples <= 0 || info.SampleRate == 0 {
+			return 0
+		}
+		return time.Duration(samples) * time.Second / time.Duration(info.SampleRate)
+	}
+	return 0
+}
+
+// DecodePayload decodes a base64 encoded, zlib compressed tag value.
+// Missing base64 padding and line breaks are tolerated.
+func DecodePayload(value string) ([]byte, error) {
+	value = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' {
+			return -1
+		}
+		return r
+	}, value)
+	value = strings.TrimRight(value, "=")
+
+	compressed, err := base64.RawStdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zlib data: %w", err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zlib data: %w", err)
+	}
+	return data, nil
+}
+
+// ParseFrames parses the decoded AUTHOR payload, one line of comma
+// separated brightness values per frame
+func ParseFrames(data []byte) ([][]int, error) {
+	var frames [][]int
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimSuffix(line, ","), ",")
+		frame := make([]int, len(fields))
+		for j, field := range fields {
+			v, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || v < 0 || v > MaxBrightness {
+				return nil, fmt.Errorf("line %d, zone %d: invalid brightness %q", i+1, j+1, field)
+			}
+			frame[j] = v
+		}
+		if len(frames) > 0 && len(frame) != len(frames[0]) {
+			return nil, fmt.Errorf("line %d has %d zones, expected %d", i+1, len(frame), len(frames[0]))
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// parseColumns parses a CUSTOM2 value such as "33cols"
+func parseColumns(value string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "cols"))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("expected a zone count such as 33cols, got %q", value)
+	}
+	return n, nil
+}
+
+// LightDuration is the time covered by the light data
+func (c *Composition) LightDuration() time.Duration {
+	return time.Duration(len(c.Frames)) * FrameInterval
+}
+
+// ZoneUsage summarizes how a single zone is lit
+type ZoneUsage struct {
+	// Zone is the 1-based zone number
+	Zone int
+	// LitFrames counts the frames with a brightness above zero
+	LitFrames int
+	// Peak is the highest brightness of the zone
+	Peak int
+	// Average is the mean brightness over all frames
+	Average float64
+}
+
+// Usage returns the usage of every zone
+func (c *Composition) Usage() []ZoneUsage {
+	usage := make([]ZoneUsage, c.Columns)
+	sums := make([]int64, c.Columns)
+	for i := range usage {
+		usage[i].Zone = i + 1
+	}
+	for _, frame := range c.Frames {
+		for i, v := range frame {
+			if v > 0 {
+				usage[i].LitFrames++
+			}
+			if v > usage[i].Peak {
+				usage[i].Peak = v
+			}
+			sums[i] += int64(v)
+		}
+	}
+	if len(c.Frames) > 0 {
+		for i := range usage {
+			usage[i].Average = float64(sums[i]) / float64(len(c.Frames))
+		}
+	}
+	return usage
+}
+
+// PrintSummary writes the model, timing and per-zone usage of c
+func PrintSummary(w io.Writer, c *Composition) {
+	model := "unknown"
+	if c.Model != nil {
+		model = fmt.Sprintf("%s (%s)", c.Model.DisplayName, c.Model.Name)
+	}
+	if c.Title != "" {
+		fmt.Fprintf(w, "Title:       %s\n", c.Title)
+	}
+	fmt.Fprintf(w, "Phone model: %s\n", model)
+	if c.Composer != "" {
+		fmt.Fprintf(w, "Composer:    %s\n", c.Composer)
+	}
+	fmt.Fprintf(w, "Audio:       %s, %s\n", c.Codec, formatDuration(c.Duration))
+	fmt.Fprintf(w, "Frames:      %d (%s of light data, %.3f ms per frame)\n", len(c.Frames), formatDuration(c.LightDuration()), float64(FrameInterval)/float64(time.Millisecond))
+	fmt.Fprintf(w, "Zones:       %d\n", c.Columns)
+
+	if len(c.Frames) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%-6s %10s %7s %6s %8s\n", "Zone", "Lit frames", "Usage", "Peak", "Average")
+	for _, u := range c.Usage() {
+		fmt.Fprintf(w, "%-6d %10d %6.1f%% %6d %8.0f\n", u.Zone, u.LitFrames, float64(u.LitFrames)*100/float64(len(c.Frames)), u.Peak, u.Average)
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
@@ -0,0 +1,140 @@
+package glyph
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encodePayload(t *testing.T, data string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	zw.Write([]byte(data))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// testOgg builds a minimal Opus stream with the given comments
+func testOgg(t *testing.T, fields []Field, samples int64) []byte {
+	t.Helper()
+
+	head := append([]byte("OpusHead"), 1, 2, 0x38, 0x01, 0x80, 0xbb, 0, 0, 0, 0, 0)
+	tags := encodeComments(CodecOpus, &Comments{Vendor: "test vendor", Fields: fields})
+	audio := []byte{0xfc, 0xff, 0xfe}
+
+	var out bytes.Buffer
+	seq := uint32(0)
+	for i, data := range [][]byte{head, tags, audio} {
+		granule := int64(0)
+		if i == 2 {
+			granule = samples
+		}
+		for _, p := range paginate(data, 42, seq, granule) {
+			if i == 0 {
+				p.Flags |= flagFirst
+			}
+			if i == 2 {
+				p.Flags |= flagLast
+			}
+			out.Write(p.encode())
+			seq++
+		}
+	}
+	return out.Bytes()
+}
+
+func TestReadComposition(t *testing.T) {
+	var rows strings.Builder
+	for i := 0; i < 3000; i++ {
+		rows.WriteString(strings.Repeat("0,", 32))
+		if i%2 == 0 {
+			rows.WriteString("4095,\r\n")
+		} else {
+			rows.WriteString("0,\r\n")
+		}
+	}
+
+	data := testOgg(t, []Field{
+		{Key: "TITLE", Value: "Test tone"},
+		{Key: "ALBUM", Value: "custom"},
+		{Key: "AUTHOR", Value: strings.TrimRight(encodePayload(t, rows.String()), "=")},
+		{Key: "COMPOSER", Value: "v1-Pong Glyph Composer"},
+		{Key: "CUSTOM1", Value: encodePayload(t, "0-0,100-1,")},
+		{Key: "CUSTOM2", Value: "33cols"},
+	}, 312+48000*3/2)
+
+	c, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if c.Title != "Test tone" || c.Codec != CodecOpus {
+		t.Errorf("unexpected metadata %+v", c)
+	}
+	if c.Model == nil || c.Model.Name != "phone2" {
+		t.Errorf("expected phone2, got %+v", c.Model)
+	}
+	if c.Duration != 1500*time.Millisecond {
+		t.Errorf("Duration = %s, want 1.5s", c.Duration)
+	}
+	if len(c.Frames) != 3000 || c.Columns != 33 {
+		t.Fatalf("got %d frames with %d zones", len(c.Frames), c.Columns)
+	}
+	if c.Custom1 != "0-0,100-1," {
+		t.Errorf("Custom1 = %q", c.Custom1)
+	}
+
+	usage := c.Usage()
+	if usage[0].LitFrames != 0 || usage[32].LitFrames != 1500 || usage[32].Peak != MaxBrightness {
+		t.Errorf("unexpected usage %+v / %+v", usage[0], usage[32])
+	}
+}
+
+func TestReadRejectsInvalidFiles(t *testing.T) {
+	valid := testOgg(t, []Field{{Key: "TITLE", Value: "no glyphs"}}, 48000)
+	if _, err := Read(bytes.NewReader(valid)); err == nil || !strings.Contains(err.Error(), "AUTHOR") {
+		t.Errorf("expected a missing AUTHOR error, got %v", err)
+	}
+
+	corrupt := append([]byte{}, valid...)
+	corrupt[40] ^= 0xff
+	if _, err := Read(bytes.NewReader(corrupt)); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("expected a checksum error, got %v", err)
+	}
+
+	if _, err := Read(strings.NewReader("RIFF....WAVE")); err == nil {
+		t.Error("expected an error for a non-Ogg file")
+	}
+
+	mismatch := testOgg(t, []Field{
+		{Key: "AUTHOR", Value: encodePayload(t, "0,0,0,0,0,\r\n")},
+		{Key: "CUSTOM2", Value: "33cols"},
+	}, 48000)
+	if _, err := Read(bytes.NewReader(mismatch)); err == nil {
+		t.Error("expected an error for a zone count mismatch")
+	}
+}
+
+func TestParseFrames(t *testing.T) {
+	frames, err := ParseFrames([]byte("0,4095,0,\r\n100,0,0,\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || frames[0][1] != 4095 || frames[1][0] != 100 {
+		t.Errorf("unexpected frames %v", frames)
+	}
+
+	if _, err := ParseFrames([]byte("0,4096,\r\n")); err == nil {
+		t.Error("expected an error for a brightness above 4095")
+	}
+	if _, err := ParseFrames([]byte("0,0,\r\n0,\r\n")); err == nil {
+		t.Error("expected an error for rows of different length")
+	}
+}
@@ -0,0 +1,857 @@
+package glyph
+
+import (
+	"encoding/binary"
+	"hash/adler32"
+)
+
+// zlibCompress compresses data exactly like zlib's compress2 at level 9.
+// compress/flate produces a different, equally valid stream, which would
+// make the tags written here differ from those of GlyphModder.py. This is a
+// port of zlib's deflate_slow and trees.c restricted to the settings Python
+// uses: a 32K window, memLevel 8 and the default strategy.
+func zlibCompress(data []byte) []byte {
+	d := newDeflater(data)
+	d.out = append(d.out, 0x78, 0xda)
+	d.deflateSlow()
+
+	sum := adler32.Checksum(data)
+	return binary.BigEndian.AppendUint32(d.out, sum)
+}
+
+const (
+	wSize        = 1 << 15
+	wMask        = wSize - 1
+	hashBits     = 15
+	hashSize     = 1 << hashBits
+	hashMask     = hashSize - 1
+	hashShift    = (hashBits + minMatch - 1) / minMatch
+	minMatch     = 3
+	maxMatch     = 258
+	minLookahead = maxMatch + minMatch + 1
+	maxDist      = wSize - minLookahead
+	winInit      = maxMatch
+	tooFar       = 4096
+	litBufSize   = 1 << (8 + 6)
+
+	// Level 9 of zlib's configuration table
+	goodMatch    = 32
+	maxLazyMatch = 258
+	niceMatch    = 258
+	maxChain     = 4096
+)
+
+// Tree constants from deflate.h
+const (
+	lengthCodes = 29
+	literals    = 256
+	lCodes      = literals + 1 + lengthCodes
+	dCodes      = 30
+	blCodes     = 19
+	heapSize    = 2*lCodes + 1
+	maxBits     = 15
+	maxBLBits   = 7
+	endBlock    = 256
+	rep3To6     = 16
+	repz3To10   = 17
+	repz11To138 = 18
+)
+
+var (
+	extraLBits  = [lengthCodes]int{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+	extraDBits  = [dCodes]int{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13}
+	extraBLBits = [blCodes]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 3, 7}
+	blOrder     = [blCodes]int{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+)
+
+// ctData mirrors zlib's ct_data union: fc holds the frequency while a tree
+// is built and the code afterwards, dl the parent node and then the length
+type ctData struct {
+	fc uint16
+	dl uint16
+}
+
+type staticTreeDesc struct {
+	tree      []ctData
+	extraBits []int
+	extraBase int
+	elems     int
+	maxLength int
+}
+
+var (
+	staticLTree [lCodes + 2]ctData
+	staticDTree [dCodes]ctData
+	distCode    [512]uint8
+	lengthCode  [maxMatch - minMatch + 1]uint8
+	baseLength  [lengthCodes]int
+	baseDist    [dCodes]int
+
+	staticLDesc  = staticTreeDesc{staticLTree[:], extraLBits[:], literals + 1, lCodes, maxBits}
+	staticDDesc  = staticTreeDesc{staticDTree[:], extraDBits[:], 0, dCodes, maxBits}
+	staticBLDesc = staticTreeDesc{nil, extraBLBits[:], 0, blCodes, maxBLBits}
+)
+
+func init() {
+	length := 0
+	code := 0
+	for code = 0; code < lengthCodes-1; code++ {
+		baseLength[code] = length
+		for n := 0; n < 1<<extraLBits[code]; n++ {
+			lengthCode[length] = uint8(code)
+			length++
+		}
+	}
+	lengthCode[length-1] = uint8(code)
+
+	dist := 0
+	for code = 0; code < 16; code++ {
+		baseDist[code] = dist
+		for n := 0; n < 1<<extraDBits[code]; n++ {
+			distCode[dist] = uint8(code)
+			dist++
+		}
+	}
+	dist >>= 7
+	for ; code < dCodes; code++ {
+		baseDist[code] = dist << 7
+		for n := 0; n < 1<<(extraDBits[code]-7); n++ {
+			distCode[256+dist] = uint8(code)
+			dist++
+		}
+	}
+
+	var blCount [maxBits + 1]uint16
+	n := 0
+	for ; n <= 143; n++ {
+		staticLTree[n].dl = 8
+		blCount[8]++
+	}
+	for ; n <= 255; n++ {
+		staticLTree[n].dl = 9
+		blCount[9]++
+	}
+	for ; n <= 279; n++ {
+		staticLTree[n].dl = 7
+		blCount[7]++
+	}
+	for ; n <= 287; n++ {
+		staticLTree[n].dl = 8
+		blCount[8]++
+	}
+	genCodes(staticLTree[:], lCodes+1, blCount[:])
+
+	for n := 0; n < dCodes; n++ {
+		staticDTree[n].dl = 5
+		staticDTree[n].fc = uint16(biReverse(n, 5))
+	}
+}
+
+func dCode(dist int) uint8 {
+	if dist < 256 {
+		return distCode[dist]
+	}
+	return distCode[256+dist>>7]
+}
+
+type treeDesc struct {
+	dynTree []ctData
+	maxCode int
+	stat    *staticTreeDesc
+}
+
+type deflater struct {
+	input []byte
+	out   []byte
+
+	window     [2 * wSize]byte
+	windowSize int
+	highWater  int
+	prev       [wSize]uint16
+	head       [hashSize]uint16
+	insH       int
+
+	blockStart     int
+	strStart       int
+	matchStart     int
+	lookahead      int
+	insert         int
+	matchLength    int
+	prevLength     int
+	prevMatch      int
+	matchAvailable bool
+
+	dynLTree [heapSize]ctData
+	dynDTree [2*dCodes + 1]ctData
+	blTree   [2*blCodes + 1]ctData
+	lDesc    treeDesc
+	dDesc    treeDesc
+	blDesc   treeDesc
+
+	blCount [maxBits + 1]uint16
+	heap    [2*lCodes + 1]int
+	heapLen int
+	heapMax int
+	depth   [2*lCodes + 1]uint8
+
+	symBuf  []byte
+	optLen  int
+	statLen int
+
+	biBuf   uint32
+	biValid int
+}
+
+func newDeflater(data []byte) *deflater {
+	d := &deflater{
+		input:       data,
+		windowSize:  2 * wSize,
+		matchLength: minMatch - 1,
+		prevLength:  minMatch - 1,
+		symBuf:      make([]byte, 0, (litBufSize-1)*3),
+	}
+	d.lDesc = treeDesc{dynTree: d.dynLTree[:], stat: &staticLDesc}
+	d.dDesc = treeDesc{dynTree: d.dynDTree[:], stat: &staticDDesc}
+	d.blDesc = treeDesc{dynTree: d.blTree[:], stat: &staticBLDesc}
+	d.initBlock()
+	return d
+}
+
+func (d *deflater) initBlock() {
+	for n := 0; n < lCodes; n++ {
+		d.dynLTree[n].fc = 0
+	}
+	for n := 0; n < dCodes; n++ {
+		d.dynDTree[n].fc = 0
+	}
+	for n := 0; n < blCodes; n++ {
+		d.blTree[n].fc = 0
+	}
+	d.dynLTree[endBlock].fc = 1
+	d.optLen, d.statLen = 0, 0
+	d.symBuf = d.symBuf[:0]
+}
+
+func (d *deflater) updateHash(c byte) {
+	d.insH = (d.insH<<hashShift ^ int(c)) & hashMask
+}
+
+// insertString adds the string at pos to the hash chains and returns the
+// previous head of its chain
+func (d *deflater) insertString(pos int) int {
+	d.updateHash(d.window[pos+minMatch-1])
+	h := d.head[d.insH]
+	d.prev[pos&wMask] = h
+	d.head[d.insH] = uint16(pos)
+	return int(h)
+}
+
+func (d *deflater) slideHash() {
+	for i, m := range d.head {
+		if int(m) >= wSize {
+			d.head[i] = m - wSize
+		} else {
+			d.head[i] = 0
+		}
+	}
+	for i, m := range d.prev {
+		if int(m) >= wSize {
+			d.prev[i] = m - wSize
+		} else {
+			d.prev[i] = 0
+		}
+	}
+}
+
+func (d *deflater) fillWindow() {
+	for {
+		more := d.windowSize - d.lookahead - d.strStart
+		if d.strStart >= wSize+maxDist {
+			copy(d.window[:wSize-more], d.window[wSize:2*wSize-more])
+			d.matchStart -= wSize
+			d.strStart -= wSize
+			d.blockStart -= wSize
+			if d.insert > d.strStart {
+				d.insert = d.strStart
+			}
+			d.slideHash()
+			more += wSize
+		}
+		if len(d.input) == 0 {
+			break
+		}
+
+		n := copy(d.window[d.strStart+d.lookahead:d.strStart+d.lookahead+more], d.input)
+		d.input = d.input[n:]
+		d.lookahead += n
+
+		if d.lookahead+d.insert >= minMatch {
+			str := d.strStart - d.insert
+			d.insH = int(d.window[str])
+			d.updateHash(d.window[str+1])
+			for d.insert > 0 {
+				d.updateHash(d.window[str+minMatch-1])
+				d.prev[str&wMask] = d.head[d.insH]
+				d.head[d.insH] = uint16(str)
+				str++
+				d.insert--
+				if d.lookahead+d.insert < minMatch {
+					break
+				}
+			}
+		}
+		if d.lookahead >= minLookahead || len(d.input) == 0 {
+			break
+		}
+	}
+
+	// zlib zeroes the bytes past the data that longest match may read
+	if d.highWater < d.windowSize {
+		curr := d.strStart + d.lookahead
+		if d.highWater < curr {
+			init := min(d.windowSize-curr, winInit)
+			clear(d.window[curr : curr+init])
+			d.highWater = curr + init
+		} else if d.highWater < curr+winInit {
+			init := min(curr+winInit-d.highWater, d.windowSize-d.highWater)
+			clear(d.window[d.highWater : d.highWater+init])
+			d.highWater += init
+		}
+	}
+}
+
+func (d *deflater) longestMatch(curMatch int) int {
+	chainLength := maxChain
+	scan := d.strStart
+	bestLen := d.prevLength
+	nice := niceMatch
+	limit := 0
+	if d.strStart > maxDist {
+		limit = d.strStart - maxDist
+	}
+	w := d.window[:]
+	scanEnd1 := w[scan+bestLen-1]
+	scanEnd := w[scan+bestLen]
+
+	if d.prevLength >= goodMatch {
+		chainLength >>= 2
+	}
+	if nice > d.lookahead {
+		nice = d.lookahead
+	}
+
+	for {
+		match := curMatch
+		if w[match+bestLen] == scanEnd && w[match+bestLen-1] == scanEnd1 &&
+			w[match] == w[scan] && w[match+1] == w[scan+1] {
+			// The third byte always matches when the first two and the
+			// hash do, zlib skips it as well
+			n := 3
+			for n < maxMatch && w[scan+n] == w[match+n] {
+				n++
+			}
+			if n > bestLen {
+				d.matchStart = curMatch
+				bestLen = n
+				if n >= nice {
+					break
+				}
+				scanEnd1 = w[scan+bestLen-1]
+				scanEnd = w[scan+bestLen]
+			}
+		}
+
+		curMatch = int(d.prev[curMatch&wMask])
+		if curMatch <= limit {
+			break
+		}
+		chainLength--
+		if chainLength == 0 {
+			break
+		}
+	}
+
+	if bestLen <= d.lookahead {
+		return bestLen
+	}
+	return d.lookahead
+}
+
+func (d *deflater) tallyLit(c byte) bool {
+	d.symBuf = append(d.symBuf, 0, 0, c)
+	d.dynLTree[c].fc++
+	return len(d.symBuf) == cap(d.symBuf)
+}
+
+func (d *deflater) tallyDist(dist, length int) bool {
+	d.symBuf = append(d.symBuf, byte(dist), byte(dist>>8), byte(length))
+	dist--
+	d.dynLTree[int(lengthCode[length])+literals+1].fc++
+	d.dynDTree[dCode(dist)].fc++
+	return len(d.symBuf) == cap(d.symBuf)
+}
+
+func (d *deflater) flushBlock(last bool) {
+	var buf []byte
+	if d.blockStart >= 0 {
+		buf = d.window[d.blockStart:d.strStart]
+	}
+	d.trFlushBlock(buf, d.strStart-d.blockStart, last)
+	d.blockStart = d.strStart
+}
+
+// deflateSlow is zlib's lazy matching compressor, called once with all
+// input and Z_FINISH
+func (d *deflater) deflateSlow() {
+	for {
+		if d.lookahead < minLookahead {
+			d.fillWindow()
+			if d.lookahead == 0 {
+				break
+			}
+		}
+
+		hashHead := 0
+		if d.lookahead >= minMatch {
+			hashHead = d.insertString(d.strStart)
+		}
+
+		d.prevLength, d.prevMatch = d.matchLength, d.matchStart
+		d.matchLength = minMatch - 1
+
+		if hashHead != 0 && d.prevLength < maxLazyMatch && d.strStart-hashHead <= maxDist {
+			d.matchLength = d.longestMatch(hashHead)
+			if d.matchLength <= 5 && d.matchLength == minMatch && d.strStart-d.matchStart > tooFar {
+				d.matchLength = minMatch - 1
+			}
+		}
+
+		if d.prevLength >= minMatch && d.matchLength <= d.prevLength {
+			maxInsert := d.strStart + d.lookahead - minMatch
+			flush := d.tallyDist(d.strStart-1-d.prevMatch, d.prevLength-minMatch)
+			d.lookahead -= d.prevLength - 1
+			d.prevLength -= 2
+			for {
+				d.strStart++
+				if d.strStart <= maxInsert {
+					d.insertString(d.strStart)
+				}
+				d.prevLength--
+				if d.prevLength == 0 {
+					break
+				}
+			}
+			d.matchAvailable = false
+			d.matchLength = minMatch - 1
+			d.strStart++
+			if flush {
+				d.flushBlock(false)
+			}
+		} else if d.matchAvailable {
+			if d.tallyLit(d.window[d.strStart-1]) {
+				d.flushBlock(false)
+			}
+			d.strStart++
+			d.lookahead--
+		} else {
+			d.matchAvailable = true
+			d.strStart++
+			d.lookahead--
+		}
+	}
+
+	if d.matchAvailable {
+		d.tallyLit(d.window[d.strStart-1])
+		d.matchAvailable = false
+	}
+	d.flushBlock(true)
+}
+
+func (d *deflater) sendBits(value, length int) {
+	d.biBuf |= uint32(value) << d.biValid
+	d.biValid += length
+	for d.biValid >= 8 {
+		d.out = append(d.out, byte(d.biBuf))
+		d.biBuf >>= 8
+		d.biValid -= 8
+	}
+}
+
+func (d *deflater) sendCode(c int, tree []ctData) {
+	d.sendBits(int(tree[c].fc), int(tree[c].dl))
+}
+
+func (d *deflater) biWindup() {
+	if d.biValid > 0 {
+		d.out = append(d.out, byte(d.biBuf))
+	}
+	d.biBuf, d.biValid = 0, 0
+}
+
+func (d *deflater) trFlushBlock(buf []byte, storedLen int, last bool) {
+	d.buildTree(&d.lDesc)
+	d.buildTree(&d.dDesc)
+	maxBLIndex := d.buildBLTree()
+
+	optLenB := (d.optLen + 3 + 7) >> 3
+	staticLenB := (d.statLen + 3 + 7) >> 3
+	if staticLenB <= optLenB {
+		optLenB = staticLenB
+	}
+
+	lastBit := 0
+	if last {
+		lastBit = 1
+	}
+	switch {
+	case storedLen+4 <= optLenB && buf != nil:
+		d.sendBits(lastBit, 3)
+		d.biWindup()
+		d.out = binary.LittleEndian.AppendUint16(d.out, uint16(storedLen))
+		d.out = binary.LittleEndian.AppendUint16(d.out, ^uint16(storedLen))
+		d.out = append(d.out, buf...)
+	case staticLenB == optLenB:
+		d.sendBits(1<<1+lastBit, 3)
+		d.compressBlock(staticLTree[:], staticDTree[:])
+	default:
+		d.sendBits(2<<1+lastBit, 3)
+		d.sendAllTrees(d.lDesc.maxCode+1, d.dDesc.maxCode+1, maxBLIndex+1)
+		d.compressBlock(d.dynLTree[:], d.dynDTree[:])
+	}
+
+	d.initBlock()
+	if last {
+		d.biWindup()
+	}
+}
+
+func (d *deflater) compressBlock(ltree, dtree []ctData) {
+	for i := 0; i < len(d.symBuf); i += 3 {
+		dist := int(d.symBuf[i]) | int(d.symBuf[i+1])<<8
+		lc := int(d.symBuf[i+2])
+		if dist == 0 {
+			d.sendCode(lc, ltree)
+			continue
+		}
+
+		code := int(lengthCode[lc])
+		d.sendCode(code+literals+1, ltree)
+		if extra := extraLBits[code]; extra != 0 {
+			d.sendBits(lc-baseLength[code], extra)
+		}
+		dist--
+		code = int(dCode(dist))
+		d.sendCode(code, dtree)
+		if extra := extraDBits[code]; extra != 0 {
+			d.sendBits(dist-baseDist[code], extra)
+		}
+	}
+	d.sendCode(endBlock, ltree)
+}
+
+// smaller compares two nodes by frequency, then by depth
+func (d *deflater) smaller(tree []ctData, n, m int) bool {
+	return tree[n].fc < tree[m].fc || (tree[n].fc == tree[m].fc && d.depth[n] <= d.depth[m])
+}
+
+func (d *deflater) pqDownHeap(tree []ctData, k int) {
+	v := d.heap[k]
+	j := k << 1
+	for j <= d.heapLen {
+		if j < d.heapLen && d.smaller(tree, d.heap[j+1], d.heap[j]) {
+			j++
+		}
+		if d.smaller(tree, v, d.heap[j]) {
+			break
+		}
+		d.heap[k] = d.heap[j]
+		k = j
+		j <<= 1
+	}
+	d.heap[k] = v
+}
+
+func (d *deflater) buildTree(desc *treeDesc) {
+	tree := desc.dynTree
+	stree := desc.stat.tree
+	elems := desc.stat.elems
+	maxCode := -1
+
+	d.heapLen, d.heapMax = 0, heapSize
+	for n := 0; n < elems; n++ {
+		if tree[n].fc != 0 {
+			d.heapLen++
+			d.heap[d.heapLen] = n
+			maxCode = n
+			d.depth[n] = 0
+		} else {
+			tree[n].dl = 0
+		}
+	}
+
+	// Force at least two codes of non zero frequency
+	for d.heapLen < 2 {
+		node := 0
+		if maxCode < 2 {
+			maxCode++
+			node = maxCode
+		}
+		d.heapLen++
+		d.heap[d.heapLen] = node
+		tree[node].fc = 1
+		d.depth[node] = 0
+		d.optLen--
+		if stree != nil {
+			d.statLen -= int(stree[node].dl)
+		}
+	}
+	desc.maxCode = maxCode
+
+	for n := d.heapLen / 2; n >= 1; n-- {
+		d.pqDownHeap(tree, n)
+	}
+
+	node := elems
+	for {
+		n := d.heap[1]
+		d.heap[1] = d.heap[d.heapLen]
+		d.heapLen--
+		d.pqDownHeap(tree, 1)
+		m := d.heap[1]
+
+		d.heapMax--
+		d.heap[d.heapMax] = n
+		d.heapMax--
+		d.heap[d.heapMax] = m
+
+		tree[node].fc = tree[n].fc + tree[m].fc
+		d.depth[node] = max(d.depth[n], d.depth[m]) + 1
+		tree[n].dl = uint16(node)
+		tree[m].dl = uint16(node)
+
+		d.heap[1] = node
+		node++
+		d.pqDownHeap(tree, 1)
+		if d.heapLen < 2 {
+			break
+		}
+	}
+	d.heapMax--
+	d.heap[d.heapMax] = d.heap[1]
+
+	d.genBitLen(desc)
+	genCodes(tree, maxCode, d.blCount[:])
+}
+
+func (d *deflater) genBitLen(desc *treeDesc) {
+	tree := desc.dynTree
+	maxCode := desc.maxCode
+	stree := desc.stat.tree
+	extra := desc.stat.extraBits
+	base := desc.stat.extraBase
+	maxLength := desc.stat.maxLength
+	overflow := 0
+
+	clear(d.blCount[:])
+
+	tree[d.heap[d.heapMax]].dl = 0
+	h := d.heapMax + 1
+	for ; h < heapSize; h++ {
+		n := d.heap[h]
+		bits := int(tree[tree[n].dl].dl) + 1
+		if bits > maxLength {
+			bits = maxLength
+			overflow++
+		}
+		tree[n].dl = uint16(bits)
+		if n > maxCode {
+			continue
+		}
+
+		d.blCount[bits]++
+		xbits := 0
+		if n >= base {
+			xbits = extra[n-base]
+		}
+		f := int(tree[n].fc)
+		d.optLen += f * (bits + xbits)
+		if stree != nil {
+			d.statLen += f * (int(stree[n].dl) + xbits)
+		}
+	}
+	if overflow == 0 {
+		return
+	}
+
+	for overflow > 0 {
+		bits := maxLength - 1
+		for d.blCount[bits] == 0 {
+			bits--
+		}
+		d.blCount[bits]--
+		d.blCount[bits+1] += 2
+		d.blCount[maxLength]--
+		overflow -= 2
+	}
+	for bits := maxLength; bits != 0; bits-- {
+		n := int(d.blCount[bits])
+		for n != 0 {
+			h--
+			m := d.heap[h]
+			if m > maxCode {
+				continue
+			}
+			if int(tree[m].dl) != bits {
+				d.optLen += (bits - int(tree[m].dl)) * int(tree[m].fc)
+				tree[m].dl = uint16(bits)
+			}
+			n--
+		}
+	}
+}
+
+func genCodes(tree []ctData, maxCode int, blCount []uint16) {
+	var nextCode [maxBits + 1]int
+	code := 0
+	for bits := 1; bits <= maxBits; bits++ {
+		code = (code + int(blCount[bits-1])) << 1
+		nextCode[bits] = code
+	}
+	for n := 0; n <= maxCode; n++ {
+		length := int(tree[n].dl)
+		if length == 0 {
+			continue
+		}
+		tree[n].fc = uint16(biReverse(nextCode[length], length))
+		nextCode[length]++
+	}
+}
+
+func biReverse(code, length int) int {
+	res := 0
+	for {
+		res |= code & 1
+		code >>= 1
+		res <<= 1
+		length--
+		if length <= 0 {
+			break
+		}
+	}
+	return res >> 1
+}
+
+func (d *deflater) scanTree(tree []ctData, maxCode int) {
+	prevLen := -1
+	nextLen := int(tree[0].dl)
+	count := 0
+	maxCount, minCount := 7, 4
+	if nextLen == 0 {
+		maxCount, minCount = 138, 3
+	}
+	tree[maxCode+1].dl = 0xffff
+
+	for n := 0; n <= maxCode; n++ {
+		curLen := nextLen
+		nextLen = int(tree[n+1].dl)
+		count++
+		if count < maxCount && curLen == nextLen {
+			continue
+		} else if count < minCount {
+			d.blTree[curLen].fc += uint16(count)
+		} else if curLen != 0 {
+			if curLen != prevLen {
+				d.blTree[curLen].fc++
+			}
+			d.blTree[rep3To6].fc++
+		} else if count <= 10 {
+			d.blTree[repz3To10].fc++
+		} else {
+			d.blTree[repz11To138].fc++
+		}
+
+		count = 0
+		prevLen = curLen
+		switch {
+		case nextLen == 0:
+			maxCount, minCount = 138, 3
+		case curLen == nextLen:
+			maxCount, minCount = 6, 3
+		default:
+			maxCount, minCount = 7, 4
+		}
+	}
+}
+
+func (d *deflater) sendTree(tree []ctData, maxCode int) {
+	prevLen := -1
+	nextLen := int(tree[0].dl)
+	count := 0
+	maxCount, minCount := 7, 4
+	if nextLen == 0 {
+		maxCount, minCount = 138, 3
+	}
+
+	for n := 0; n <= maxCode; n++ {
+		curLen := nextLen
+		nextLen = int(tree[n+1].dl)
+		count++
+		if count < maxCount && curLen == nextLen {
+			continue
+		} else if count < minCount {
+			for ; count != 0; count-- {
+				d.sendCode(curLen, d.blTree[:])
+			}
+		} else if curLen != 0 {
+			if curLen != prevLen {
+				d.sendCode(curLen, d.blTree[:])
+				count--
+			}
+			d.sendCode(rep3To6, d.blTree[:])
+			d.sendBits(count-3, 2)
+		} else if count <= 10 {
+			d.sendCode(repz3To10, d.blTree[:])
+			d.sendBits(count-3, 3)
+		} else {
+			d.sendCode(repz11To138, d.blTree[:])
+			d.sendBits(count-11, 7)
+		}
+
+		count = 0
+		prevLen = curLen
+		switch {
+		case nextLen == 0:
+			maxCount, minCount = 138, 3
+		case curLen == nextLen:
+			maxCount, minCount = 6, 3
+		default:
+			maxCount, minCount = 7, 4
+		}
+	}
+}
+
+func (d *deflater) buildBLTree() int {
+	d.scanTree(d.dynLTree[:], d.lDesc.maxCode)
+	d.scanTree(d.dynDTree[:], d.dDesc.maxCode)
+	d.buildTree(&d.blDesc)
+
+	maxBLIndex := blCodes - 1
+	for ; maxBLIndex >= 3; maxBLIndex-- {
+		if d.blTree[blOrder[maxBLIndex]].dl != 0 {
+			break
+		}
+	}
+	d.optLen += 3*(maxBLIndex+1) + 5 + 5 + 4
+	return maxBLIndex
+}
+
+func (d *deflater) sendAllTrees(lcodes, dcodes, blcodes int) {
+	d.sendBits(lcodes-257, 5)
+	d.sendBits(dcodes-1, 5)
+	d.sendBits(blcodes-4, 4)
+	for rank := 0; rank < blcodes; rank++ {
+		d.sendBits(int(d.blTree[blOrder[rank]].dl), 3)
+	}
+	d.sendTree(d.dynLTree[:], lcodes-1)
+	d.sendTree(d.dynDTree[:], dcodes-1)
+}
@@ -0,0 +1,183 @@
+package glyph
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Colors of unlit and fully lit zones
+var (
+	OffColor = color.RGBA{48, 48, 48, 255}
+	OnColor  = color.RGBA{255, 255, 255, 255}
+)
+
+// Shape kinds
+const (
+	ShapeLine = "line"
+	ShapeArc  = "arc"
+	ShapeDot  = "dot"
+)
+
+// Shape is a light strip on the back of a phone. Coordinates are relative
+// to the back of the phone, (0,0) is the top left and (1,1) the bottom
+// right corner, seen from behind.
+type Shape struct {
+	Kind string `yaml:"kind"`
+	// X1, Y1, X2 and Y2 are the ends of a line, X1 and Y1 the centre of a dot
+	X1 float64 `yaml:"x1,omitempty"`
+	Y1 float64 `yaml:"y1,omitempty"`
+	X2 float64 `yaml:"x2,omitempty"`
+	Y2 float64 `yaml:"y2,omitempty"`
+	// CX, CY and R describe the circle of an arc, which runs clockwise from
+	// Start to End degrees, 0 being 3 o'clock. R is relative to the width.
+	CX    float64 `yaml:"cx,omitempty"`
+	CY    float64 `yaml:"cy,omitempty"`
+	R     float64 `yaml:"r,omitempty"`
+	Start float64 `yaml:"start,omitempty"`
+	End   float64 `yaml:"end,omitempty"`
+	// Segments splits the shape into consecutive zones, 0 counts as 1
+	Segments int `yaml:"segments,omitempty"`
+}
+
+// Layout is the geometry of a glyph interface
+type Layout struct {
+	// Aspect is the width of the phone back divided by its height
+	Aspect float64 `yaml:"aspect"`
+	// Shapes contribute their segments to the native zones in order
+	Shapes []Shape `yaml:"shapes"`
+}
+
+// Zones counts the native zones of the layout
+func (l *Layout) Zones() int {
+	n := 0
+	for _, s := range l.Shapes {
+		n += max(s.Segments, 1)
+	}
+	return n
+}
+
+// point returns the position at t (0-1) along the shape on a phone with
+// the given aspect ratio
+func (s *Shape) point(t, aspect float64) (float64, float64) {
+	switch s.Kind {
+	case ShapeArc:
+		a := (s.Start + (s.End-s.Start)*t) * math.Pi / 180
+		return s.CX + s.R*math.Cos(a), s.CY + s.R*aspect*math.Sin(a)
+	case ShapeDot:
+		return s.X1, s.Y1
+	}
+	return s.X1 + (s.X2-s.X1)*t, s.Y1 + (s.Y2-s.Y1)*t
+}
+
+// Canvas is a layout rasterized to a grid of pixels
+type Canvas struct {
+	Width, Height int
+	// Pixels holds the native zone of every pixel, row by row, -1 where
+	// there is no light
+	Pixels []int
+}
+
+// Rasterize draws the layout on a grid of the given height, the width
+// follows from the aspect ratio. stroke is the strip width in pixels.
+func (l *Layout) Rasterize(height int, stroke float64) *Canvas {
+	width := max(int(math.Round(float64(height)*l.Aspect)), 1)
+	c := &Canvas{Width: width, Height: height, Pixels: make([]int, width*height)}
+	for i := range c.Pixels {
+		c.Pixels[i] = -1
+	}
+
+	radius := max(stroke/2, 0.5)
+	zone := 0
+	for i := range l.Shapes {
+		s := &l.Shapes[i]
+		segments := max(s.Segments, 1)
+		r := radius
+		if s.Kind == ShapeDot {
+			r *= 1.6
+		}
+
+		// Sample finely enough to leave no gaps, with a small gap between
+		// segments so that they stay distinguishable
+		samples := 4 * (width + height) * segments
+		for j := 0; j <= samples; j++ {
+			t := float64(j) / float64(samples)
+			seg := min(int(t*float64(segments)), segments-1)
+			if segments > 1 && radius >= 1 {
+				if f := t*float64(segments) - float64(seg); f < 0.08 || f > 0.92 {
+					continue
+				}
+			}
+			x, y := s.point(t, l.Aspect)
+			c.stamp(x*float64(width), y*float64(height), r, zone+seg)
+		}
+		zone += segments
+	}
+	return c
+}
+
+// stamp assigns zone to all pixels within radius of (x, y)
+func (c *Canvas) stamp(x, y, radius float64, zone int) {
+	for py := int(y - radius); py <= int(y+radius); py++ {
+		for px := int(x - radius); px <= int(x+radius); px++ {
+			if px < 0 || py < 0 || px >= c.Width || py >= c.Height {
+				continue
+			}
+			dx, dy := float64(px)+0.5-x, float64(py)+0.5-y
+			if dx*dx+dy*dy <= radius*radius || radius < 1 {
+				c.Pixels[py*c.Width+px] = zone
+			}
+		}
+	}
+}
+
+// ZoneColumns maps every native zone of the model to the frame column
+// that drives it when frames have the given number of columns
+func (m *Model) ZoneColumns(columns int) ([]int, error) {
+	native := m.Layout.Zones()
+	if columns == native {
+		mapping := make([]int, native)
+		for i := range mapping {
+			mapping[i] = i
+		}
+		return mapping, nil
+	}
+	if mapping, ok := m.ZoneMap[columns]; ok && len(mapping) == native {
+		return mapping, nil
+	}
+	return nil, fmt.Errorf("%s has no layout for %d zones", m.DisplayName, columns)
+}
+
+// ZoneBrightness returns the brightness of every native zone for frame,
+// using a mapping from ZoneColumns. A nil frame leaves all zones unlit.
+func ZoneBrightness(frame []int, mapping []int) []int {
+	brightness := make([]int, len(mapping))
+	for zone, col := range mapping {
+		if col < len(frame) {
+			brightness[zone] = frame[col]
+		}
+	}
+	return brightness
+}
+
+// Shade blends from OffColor to OnColor
+func Shade(brightness int) color.RGBA {
+	f := float64(brightness) / MaxBrightness
+	mix := func(off, on uint8) uint8 {
+		return off + uint8(f*float64(on-off)+0.5)
+	}
+	return color.RGBA{mix(OffColor.R, OnColor.R), mix(OffColor.G, OnColor.G), mix(OffColor.B, OnColor.B), 255}
+}
+
+// arcSegments splits an arc into n zones
+func arcSegments(cx, cy, r, start, end float64, n int) Shape {
+	return Shape{Kind: ShapeArc, CX: cx, CY: cy, R: r, Start: start, End: end, Segments: n}
+}
+
+func line(x1, y1, x2, y2 float64, n int) Shape {
+	return Shape{Kind: ShapeLine, X1: x1, Y1: y1, X2: x2, Y2: y2, Segments: n}
+}
+
+func dot(x, y float64) Shape {
+	return Shape{Kind: ShapeDot, X1: x, Y1: y}
+}
@@ -0,0 +1,46 @@
+package glyph
+
+import "testing"
+
+func TestLayoutsCoverEveryZone(t *testing.T) {
+	for _, m := range Models {
+		if n := m.Layout.Zones(); n != m.Columns[0] {
+			t.Errorf("%s: layout has %d zones, native frames have %d", m.Name, n, m.Columns[0])
+			continue
+		}
+
+		c := m.Layout.Rasterize(300, 4)
+		seen := map[int]bool{}
+		for _, z := range c.Pixels {
+			seen[z] = true
+		}
+		for z := 0; z < m.Columns[0]; z++ {
+			if !seen[z] {
+				t.Errorf("%s: zone %d is not drawn", m.Name, z+1)
+			}
+		}
+
+		for _, columns := range m.Columns {
+			mapping, err := m.ZoneColumns(columns)
+			if err != nil {
+				t.Errorf("%s: %v", m.Name, err)
+				continue
+			}
+			for zone, col := range mapping {
+				if col < 0 || col >= columns {
+					t.Errorf("%s: zone %d maps to column %d of %d", m.Name, zone+1, col+1, columns)
+				}
+			}
+		}
+	}
+}
+
+func TestZoneColumnsRejectsUnknownCounts(t *testing.T) {
+	m, err := LookupModel("phone2a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ZoneColumns(5); err == nil {
+		t.Error("expected an error for a zone count without a layout")
+	}
+}
@@ -0,0 +1,389 @@
+// Package lint checks Audacity label files before GlyphTranslator.py runs
+// them.
+//
+// Every line of a label file is a label: its start and end in seconds and
+// its text, separated by tabs. Lines of spectral selections, which start
+// with a backslash, are ignored. The text is one of
+//
+//	LABEL_VERSION=1     required, before any other label
+//	PHONE_MODEL=PHONE2  the phone the labels are written for
+//	END                 the end of the composition, no label may follow it
+//	<glyph>-<level>[-<to>[-<effect>]]
+//
+// A glyph is a 1-based glyph number, <glyph>.<segment> for a single segment
+// of a glyph or #<zone> for a single zone. Levels are percentages, a
+// second level fades to it with the effect LIN (default), EXP or LOG.
+package lint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+)
+
+// Label directives
+const (
+	LabelVersion = "LABEL_VERSION"
+	PhoneModel   = "PHONE_MODEL"
+	End          = "END"
+)
+
+// SupportedVersion is the only LABEL_VERSION GlyphTranslator.py reads
+const SupportedVersion = 1
+
+// Effects lists the fade effects
+var Effects = []string{"LIN", "EXP", "LOG"}
+
+// Issue is a problem at a position in a label file
+type Issue struct {
+	// Line and Column are 1-based, Column counts characters
+	Line, Column int
+	Message      string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, i.Message)
+}
+
+// Result is the outcome of checking a label file
+type Result struct {
+	// Model is the phone the labels were checked against, nil when it is
+	// not known
+	Model  *glyph.Model
+	Issues []Issue
+}
+
+// OK reports whether no issues were found
+func (r *Result) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// File checks the label file at path, see Check
+func File(path string, model *glyph.Model) (*Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	return Check(f, model)
+}
+
+// Check reads labels from r and checks their syntax. Zones are checked
+// against model, or the model named by the PHONE_MODEL label when model is
+// nil.
+func Check(r io.Reader, model *glyph.Model) (*Result, error) {
+	c := &checker{result: &Result{Model: model}}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		c.line(n, strings.TrimRight(sc.Text(), "\r"))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read labels: %w", err)
+	}
+	c.finish()
+
+	sort.SliceStable(c.result.Issues, func(i, j int) bool {
+		a, b := c.result.Issues[i], c.result.Issues[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.result, nil
+}
+
+// field is a tab separated part of a line
+type field struct {
+	text   string
+	column int
+}
+
+// light is a label that lights zones
+type light struct {
+	line       int
+	field      field
+	start, end time.Duration
+	text       string
+}
+
+type checker struct {
+	result  *Result
+	version bool
+	// first is the line of the first label
+	first int
+	end   int
+	// modelLine is the line of the PHONE_MODEL label, 0 when there is none
+	modelLine int
+	lights    []light
+}
+
+func (c *checker) report(line, column int, format string, args ...any) {
+	c.result.Issues = append(c.result.Issues, Issue{Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// split splits a line at tabs and records the column of every field
+func split(line string) []field {
+	var fields []field
+	column := 1
+	for {
+		text, rest, found := strings.Cut(line, "\t")
+		fields = append(fields, field{text: text, column: column})
+		if !found {
+			return fields
+		}
+		column += utf8.RuneCountInString(text) + 1
+		line = rest
+	}
+}
+
+func (c *checker) line(n int, line string) {
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "\\") {
+		return
+	}
+
+	if c.first == 0 {
+		c.first = n
+	}
+	fields := split(line)
+	if len(fields) != 3 {
+		c.report(n, 1, "expected start, end and label separated by tabs, got %d fields", len(fields))
+		return
+	}
+	start, ok := c.time(n, fields[0])
+	end, ok2 := c.time(n, fields[1])
+	if !ok || !ok2 {
+		return
+	}
+	if end < start {
+		c.report(n, fields[1].column, "label ends before it starts")
+		return
+	}
+
+	if c.end != 0 {
+		c.report(n, fields[0].column, "label after END on line %d", c.end)
+	}
+
+	label := fields[2]
+	text := strings.TrimSpace(label.text)
+	label.column += strings.Index(label.text, text)
+	switch {
+	case text == "":
+		c.report(n, label.column, "empty label")
+	case strings.HasPrefix(text, LabelVersion+"="):
+		c.labelVersion(n, label, text)
+	case strings.HasPrefix(text, PhoneModel+"="):
+		c.phoneModel(n, label, text)
+	case text == End:
+		c.end = n
+	default:
+		if !c.version {
+			c.report(n, label.column, "%s=%d must come before the first light label", LabelVersion, SupportedVersion)
+			c.version = true
+		}
+		if end == start {
+			c.report(n, fields[1].column, "light label has no duration, select a range in Audacity")
+		} else if end-start < glyph.FrameInterval {
+			c.report(n, fields[1].column, "light label is shorter than one frame (%s)", glyph.FrameInterval)
+		}
+		c.lights = append(c.lights, light{line: n, field: label, start: start, end: end, text: text})
+	}
+}
+
+// time parses a label position in seconds
+func (c *checker) time(n int, f field) (time.Duration, bool) {
+	text := strings.TrimSpace(f.text)
+	if strings.Contains(text, ",") {
+		c.report(n, f.column, "invalid time %q, use a dot as decimal separator", text)
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		c.report(n, f.column, "invalid time %q", text)
+		return 0, false
+	}
+	if v < 0 {
+		c.report(n, f.column, "negative time %s", text)
+		return 0, false
+	}
+	return time.Duration(math.Round(v * float64(time.Second))), true
+}
+
+func (c *checker) labelVersion(n int, f field, text string) {
+	value := strings.TrimPrefix(text, LabelVersion+"=")
+	if c.version {
+		c.report(n, f.column, "duplicate or late %s label", LabelVersion)
+	}
+	c.version = true
+	if v, err := strconv.Atoi(value); err != nil || v != SupportedVersion {
+		c.report(n, f.column+len(LabelVersion)+1, "unsupported label version %q, expected %d", value, SupportedVersion)
+	}
+}
+
+func (c *checker) phoneModel(n int, f field, text string) {
+	value := strings.TrimPrefix(text, PhoneModel+"=")
+	if c.modelLine != 0 {
+		c.report(n, f.column, "duplicate %s label, the first one is on line %d", PhoneModel, c.modelLine)
+		return
+	}
+	c.modelLine = n
+
+	m, err := glyph.LookupModel(value)
+	if err != nil {
+		c.report(n, f.column+len(PhoneModel)+1, "%v", err)
+		return
+	}
+	if c.result.Model == nil {
+		c.result.Model = m
+	} else if c.result.Model != m {
+		c.report(n, f.column+len(PhoneModel)+1, "labels are written for %s, not %s", m.DisplayName, c.result.Model.DisplayName)
+	}
+}
+
+// finish checks what needs the whole file: the model dependent parts of
+// light labels and overlaps
+func (c *checker) finish() {
+	if c.first == 0 {
+		c.report(1, 1, "no labels found, export them with File > Export > Export Labels")
+		return
+	}
+	if !c.version {
+		c.report(c.first, 1, "missing %s=%d label", LabelVersion, SupportedVersion)
+	}
+
+	model := c.result.Model
+	if model == nil && len(c.lights) > 0 {
+		c.report(c.first, 1, "unknown phone model, add a %s label or pass --model", PhoneModel)
+	}
+
+	// lit holds the labels lighting every zone
+	lit := map[int][]light{}
+	for _, l := range c.lights {
+		zones := c.lightLabel(l, model)
+		for _, z := range zones {
+			lit[z] = append(lit[z], l)
+		}
+	}
+
+	var zones []int
+	for z := range lit {
+		zones = append(zones, z)
+	}
+	sort.Ints(zones)
+	// Report every pair of labels once, even when they share several zones
+	reported := map[[2]int]bool{}
+	for _, z := range zones {
+		labels := lit[z]
+		sort.SliceStable(labels, func(i, j int) bool { return labels[i].start < labels[j].start })
+		// prev is the label that stays lit the longest so far
+		prev := labels[0]
+		for _, cur := range labels[1:] {
+			if cur.start < prev.end && !reported[[2]int{prev.line, cur.line}] {
+				reported[[2]int{prev.line, cur.line}] = true
+				c.report(cur.line, cur.field.column, "zone %d is still lit by the label on line %d until %.3fs", z+1, prev.line, prev.end.Seconds())
+			}
+			if cur.end > prev.end {
+				prev = cur
+			}
+		}
+	}
+}
+
+// lightLabel checks a light label and returns the 0-based native zones it
+// lights. Zones are only checked when the model is known.
+func (c *checker) lightLabel(l light, model *glyph.Model) []int {
+	parts := strings.Split(l.text, "-")
+	columns := make([]int, len(parts))
+	column := l.field.column
+	for i, p := range parts {
+		columns[i] = column
+		column += utf8.RuneCountInString(p) + 1
+	}
+	if len(parts) < 2 || len(parts) > 4 {
+		c.report(l.line, l.field.column, "invalid light label %q, expected <glyph>-<level>[-<to>[-<effect>]]", l.text)
+		return nil
+	}
+
+	for i := 1; i < len(parts) && i < 3; i++ {
+		if v, err := strconv.Atoi(parts[i]); err != nil || v < 0 || v > 100 {
+			c.report(l.line, columns[i], "invalid light level %q, expected 0-100", parts[i])
+		}
+	}
+	if len(parts) == 4 && !isEffect(parts[3]) {
+		c.report(l.line, columns[3], "unknown effect %q, expected %s", parts[3], strings.Join(Effects, ", "))
+	}
+
+	if model == nil {
+		return nil
+	}
+	zones, err := Zones(model, parts[0])
+	if err != nil {
+		c.report(l.line, columns[0], "%v", err)
+	}
+	return zones
+}
+
+func isEffect(name string) bool {
+	for _, e := range Effects {
+		if name == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Zones returns the 0-based native zones addressed by a glyph of a label:
+// a glyph number, <glyph>.<segment> or #<zone>
+func Zones(model *glyph.Model, name string) ([]int, error) {
+	layout := &model.Layout
+	if zone, ok := strings.CutPrefix(name, "#"); ok {
+		n, err := strconv.Atoi(zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zone %q", name)
+		}
+		if n < 1 || n > layout.Zones() {
+			return nil, fmt.Errorf("zone %d is out of range, %s has zones 1-%d", n, model.DisplayName, layout.Zones())
+		}
+		return []int{n - 1}, nil
+	}
+
+	number, segment, hasSegment := strings.Cut(name, ".")
+	g, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glyph %q, expected a number, <glyph>.<segment> or #<zone>", name)
+	}
+	if g < 1 || g > len(layout.Shapes) {
+		return nil, fmt.Errorf("glyph %d is out of range, %s has glyphs 1-%d", g, model.DisplayName, len(layout.Shapes))
+	}
+
+	first := 0
+	for _, s := range layout.Shapes[:g-1] {
+		first += max(s.Segments, 1)
+	}
+	segments := max(layout.Shapes[g-1].Segments, 1)
+	if !hasSegment {
+		zones := make([]int, segments)
+		for i := range zones {
+			zones[i] = first + i
+		}
+		return zones, nil
+	}
+
+	s, err := strconv.Atoi(segment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid segment %q", name)
+	}
+	if s < 1 || s > segments {
+		return nil, fmt.Errorf("segment %d is out of range, glyph %d has segments 1-%d", s, g, segments)
+	}
+	return []int{first + s - 1}, nil
+}
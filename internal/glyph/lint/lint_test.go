@@ -0,0 +1,152 @@
+package lint
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+)
+
+func model(t *testing.T, name string) *glyph.Model {
+	t.Helper()
+	m, err := glyph.LookupModel(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func check(t *testing.T, labels string, m *glyph.Model) []string {
+	t.Helper()
+	r, err := Check(strings.NewReader(labels), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issues []string
+	for _, i := range r.Issues {
+		issues = append(issues, i.String())
+	}
+	return issues
+}
+
+func TestCheckValid(t *testing.T) {
+	labels := "0.000000\t0.000000\tLABEL_VERSION=1\r\n" +
+		"0.000000\t0.000000\tPHONE_MODEL=PHONE2\r\n" +
+		"\\\t0.000000\t20000.000000\r\n" +
+		"0.500000\t1.000000\t4-100\r\n" +
+		"1.000000\t1.500000\t4.16-100-0-EXP\r\n" +
+		"1.000000\t1.500000\t#1-50\r\n" +
+		"2.000000\t2.000000\tEND\r\n"
+	r, err := Check(strings.NewReader(labels), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() {
+		t.Errorf("unexpected issues %v", r.Issues)
+	}
+	if r.Model == nil || r.Model.Name != "phone2" {
+		t.Errorf("model = %v, want phone2 from PHONE_MODEL", r.Model)
+	}
+}
+
+func TestCheckIssues(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		labels string
+		want   []string
+	}{
+		{
+			name:   "missing version",
+			labels: "0\t1\t1-100\n",
+			want:   []string{"1:5: LABEL_VERSION=1 must come before the first light label"},
+		},
+		{
+			name:   "fields",
+			labels: "0 1 1-100\n0,5\t1\t1-100\n1\tx\t1-100\n",
+			want: []string{
+				"1:1: expected start, end and label separated by tabs, got 1 fields",
+				"1:1: missing LABEL_VERSION=1 label",
+				`2:1: invalid time "0,5", use a dot as decimal separator`,
+				`3:3: invalid time "x"`,
+			},
+		},
+		{
+			name:   "durations",
+			labels: "0\t0\tLABEL_VERSION=1\n2\t1\t1-100\n1\t1\t1-100\n1\t1.01\t2-100\n",
+			want: []string{
+				"2:3: label ends before it starts",
+				"3:3: light label has no duration, select a range in Audacity",
+				"4:3: light label is shorter than one frame (16.666ms)",
+			},
+		},
+		{
+			name:   "syntax",
+			labels: "0\t0\tLABEL_VERSION=2\n0\t1\t1-101\n1\t2\t 2-50-0-FADE\n2\t3\t3\n",
+			want: []string{
+				`1:19: unsupported label version "2", expected 1`,
+				`2:7: invalid light level "101", expected 0-100`,
+				`3:13: unknown effect "FADE", expected LIN, EXP, LOG`,
+				`4:5: invalid light label "3", expected <glyph>-<level>[-<to>[-<effect>]]`,
+			},
+		},
+		{
+			name:   "zones",
+			labels: "0\t0\tLABEL_VERSION=1\n0\t1\t12-100\n0\t1\t4.17-100\n0\t1\t#34-100\n",
+			want: []string{
+				"2:5: glyph 12 is out of range, Phone (2) has glyphs 1-11",
+				"3:5: segment 17 is out of range, glyph 4 has segments 1-16",
+				"4:5: zone 34 is out of range, Phone (2) has zones 1-33",
+			},
+		},
+		{
+			name:   "overlap",
+			labels: "0\t0\tLABEL_VERSION=1\n0\t1\t4-100\n0.5\t1.5\t4.3-100\n1\t2\t4-100\n",
+			want: []string{
+				"3:9: zone 6 is still lit by the label on line 2 until 1.000s",
+				"4:5: zone 6 is still lit by the label on line 3 until 1.500s",
+			},
+		},
+		{
+			name:   "end",
+			labels: "0\t0\tLABEL_VERSION=1\n1\t1\tEND\n1\t2\t1-100\n",
+			want:   []string{"3:1: label after END on line 2"},
+		},
+		{
+			name:   "model mismatch",
+			labels: "0\t0\tLABEL_VERSION=1\n0\t0\tPHONE_MODEL=PHONE1\n",
+			want:   []string{"2:17: labels are written for Phone (1), not Phone (2)"},
+		},
+	} {
+		got := check(t, tc.labels, model(t, "phone2"))
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckWithoutModel(t *testing.T) {
+	got := check(t, "0\t0\tLABEL_VERSION=1\n0\t1\t40-100\n", nil)
+	want := []string{"1:1: unknown phone model, add a PHONE_MODEL label or pass --model"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestZones(t *testing.T) {
+	m := model(t, "phone1")
+	for name, want := range map[string][]int{
+		"1":   {0},
+		"3":   {2},
+		"8":   {7, 8, 9, 10, 11, 12, 13, 14},
+		"8.2": {8},
+		"#15": {14},
+	} {
+		got, err := Zones(m, name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if !slices.Equal(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package glyph
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extensions of the files GlyphModder.py reads and writes
+const (
+	FrameFileExt   = ".glypha"
+	Custom1FileExt = ".glyphc1"
+)
+
+// ModderArgs are the GlyphModder.py arguments the native engine supports
+type ModderArgs struct {
+	File  string
+	Title string
+	// Write is a .glypha file whose light data replaces that of File. A
+	// .glyphc1 file next to it is written as CUSTOM1.
+	Write string
+}
+
+// ParseModderArgs parses GlyphModder.py style arguments. Options the
+// native engine does not implement are rejected.
+func ParseModderArgs(args []string) (*ModderArgs, error) {
+	a := &ModderArgs{}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case name == "-t" || name == "--title" || name == "-w" || name == "--write":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("%s expects a value", arg)
+				}
+				i++
+				value = args[i]
+			}
+			if name == "-t" || name == "--title" {
+				a.Title = value
+			} else {
+				a.Write = value
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return nil, fmt.Errorf("option %s is not supported by the native engine, use --engine=python", arg)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) != 1 {
+		return nil, fmt.Errorf("expected exactly one .ogg file, got %d arguments", len(positional))
+	}
+	a.File = positional[0]
+	return a, nil
+}
+
+// RunModder does what GlyphModder.py does for the same arguments: with
+// Write it replaces the light data and title, with only Title it sets the
+// title, and without either it extracts the light data into .glypha and
+// .glyphc1 files next to the composition.
+func RunModder(a *ModderArgs, w io.Writer) error {
+	if a.Write == "" && a.Title == "" {
+		return extractFrameFiles(a.File, w)
+	}
+
+	u := &Update{Title: a.Title}
+	if a.Write != "" {
+		frames, err := ParseFrameFile(a.Write)
+		if err != nil {
+			return err
+		}
+		u.Frames = frames
+
+		custom1, err := os.ReadFile(trimExt(a.Write) + Custom1FileExt)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s file: %w", Custom1FileExt, err)
+		}
+		u.Custom1 = string(custom1)
+
+		// GlyphModder.py names the composition after the file by default
+		if u.Title == "" {
+			u.Title = filepath.Base(trimExt(a.File))
+		}
+	}
+
+	if err := WriteFile(a.File, "", u); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "✅ Updated %s\n", a.File)
+	return nil
+}
+
+func extractFrameFiles(path string, w io.Writer) error {
+	c, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Write the decoded AUTHOR tag as is rather than re-formatting Frames
+	author, _ := c.Comments.Get(TagAuthor)
+	data, err := DecodePayload(author)
+	if err != nil {
+		return fmt.Errorf("invalid %s tag: %w", TagAuthor, err)
+	}
+	base := trimExt(path)
+	if err := os.WriteFile(base+FrameFileExt, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s file: %w", FrameFileExt, err)
+	}
+	fmt.Fprintf(w, "📄 Wrote %s\n", base+FrameFileExt)
+
+	if c.Custom1 != "" {
+		if err := os.WriteFile(base+Custom1FileExt, []byte(c.Custom1), 0644); err != nil {
+			return fmt.Errorf("failed to write %s file: %w", Custom1FileExt, err)
+		}
+		fmt.Fprintf(w, "📄 Wrote %s\n", base+Custom1FileExt)
+	}
+	return nil
+}
+
+// ParseFrameFile reads the light data of a .glypha file
+func ParseFrameFile(path string) ([][]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	frames, err := ParseFrames(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid light data in %s: %w", path, err)
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("%s contains no light data", path)
+	}
+	return frames, nil
+}
+
+func trimExt(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
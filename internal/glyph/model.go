@@ -0,0 +1,128 @@
+package glyph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Model is a phone with a glyph interface
+type Model struct {
+	// Name is the identifier used on the command line, e.g. phone2
+	Name        string `yaml:"name"`
+	DisplayName string `yaml:"display_name"`
+	// Codename appears in the COMPOSER tag as "v1-<Codename> Glyph Composer"
+	Codename string `yaml:"codename"`
+	// Columns lists the supported numbers of zones per frame, the first
+	// one is the native layout
+	Columns []int `yaml:"columns"`
+	// Layout is the approximate geometry of the native zones
+	Layout Layout `yaml:"layout"`
+	// ZoneMap maps the native zones to frame columns for compatibility
+	// modes with fewer zones, keyed by their number of columns
+	ZoneMap map[int][]int `yaml:"zone_map,omitempty"`
+	// Source is the model file that defined the model, empty for built-in
+	// models
+	Source string `yaml:"-"`
+}
+
+// Models are the known phone models, LoadModelFile adds to them. Zones are
+// listed in the order of the frame columns, following the Glyph Developer
+// Kit names in the comments.
+var Models = []Model{
+	{
+		Name: "phone1", DisplayName: "Phone (1)", Codename: "Spacewar", Columns: []int{15, 5},
+		Layout: Layout{Aspect: 0.48, Shapes: []Shape{
+			arcSegments(0.3, 0.14, 0.2, 10, 120, 1),  // A1 camera
+			line(0.66, 0.2, 0.84, 0.32, 1),           // B1 diagonal
+			arcSegments(0.5, 0.55, 0.4, 275, 355, 1), // C1-C4 ring
+			arcSegments(0.5, 0.55, 0.4, 5, 85, 1),
+			arcSegments(0.5, 0.55, 0.4, 95, 175, 1),
+			arcSegments(0.5, 0.55, 0.4, 185, 265, 1),
+			dot(0.5, 0.96),                // E1 USB dot
+			line(0.5, 0.81, 0.5, 0.92, 8), // D1_1-D1_8 USB line
+		}},
+		ZoneMap: map[int][]int{5: {0, 1, 2, 2, 2, 2, 4, 3, 3, 3, 3, 3, 3, 3, 3}},
+	},
+	{
+		Name: "phone2", DisplayName: "Phone (2)", Codename: "Pong", Columns: []int{33, 5},
+		Layout: Layout{Aspect: 0.48, Shapes: []Shape{
+			arcSegments(0.3, 0.14, 0.2, 200, 300, 1), // A1, A2 camera
+			arcSegments(0.3, 0.14, 0.2, 10, 120, 1),
+			line(0.66, 0.2, 0.84, 0.32, 1),            // B1 diagonal
+			arcSegments(0.5, 0.55, 0.4, 272, 358, 16), // C1_1-C1_16 ring, top right
+			arcSegments(0.5, 0.55, 0.4, 2, 43, 1),     // C2-C6 rest of the ring
+			arcSegments(0.5, 0.55, 0.4, 47, 133, 1),
+			arcSegments(0.5, 0.55, 0.4, 137, 178, 1),
+			arcSegments(0.5, 0.55, 0.4, 182, 223, 1),
+			arcSegments(0.5, 0.55, 0.4, 227, 268, 1),
+			dot(0.5, 0.96),                // E1 USB dot
+			line(0.5, 0.81, 0.5, 0.92, 8), // D1_1-D1_8 USB line
+		}},
+		ZoneMap: map[int][]int{5: {0, 0, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 4, 3, 3, 3, 3, 3, 3, 3, 3}},
+	},
+	{
+		Name: "phone2a", DisplayName: "Phone (2a)", Codename: "Pacman", Columns: []int{26},
+		Layout: Layout{Aspect: 0.47, Shapes: []Shape{
+			arcSegments(0.5, 0.25, 0.36, 140, 400, 24), // C1_1-C1_24 ring around the camera
+			line(0.22, 0.62, 0.22, 0.82, 1),            // A strip
+			line(0.62, 0.7, 0.8, 0.7, 1),               // B strip
+		}},
+	},
+	{
+		Name: "phone3a", DisplayName: "Phone (3a)", Codename: "Asteroids", Columns: []int{36},
+		Layout: Layout{Aspect: 0.47, Shapes: []Shape{
+			arcSegments(0.5, 0.26, 0.36, 190, 350, 20), // C1-C20 arc above the camera
+			line(0.18, 0.58, 0.82, 0.58, 11),           // A1-A11 middle strip
+			line(0.32, 0.74, 0.68, 0.74, 5),            // B1-B5 lower strip
+		}},
+	},
+}
+
+// LookupModel finds a model by name, display name or codename
+func LookupModel(name string) (*Model, error) {
+	for i, m := range Models {
+		if strings.EqualFold(m.Name, name) || strings.EqualFold(m.DisplayName, name) || strings.EqualFold(m.Codename, name) {
+			return &Models[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown phone model %q", name)
+}
+
+// ComposerTag returns the COMPOSER value that identifies the model
+func (m *Model) ComposerTag() string {
+	return fmt.Sprintf("v1-%s Glyph Composer", m.Codename)
+}
+
+// SupportsColumns reports whether frames with n zones are valid for the model
+func (m *Model) SupportsColumns(n int) bool {
+	for _, c := range m.Columns {
+		if c == n {
+			return true
+		}
+	}
+	return false
+}
+
+// modelFromComposer finds the model named by a COMPOSER tag
+func modelFromComposer(tag string) *Model {
+	for i, m := range Models {
+		if strings.EqualFold(tag, m.ComposerTag()) {
+			return &Models[i]
+		}
+	}
+	return nil
+}
+
+// modelFromColumns returns the only model whose native layout has n zones
+func modelFromColumns(n int) *Model {
+	var found *Model
+	for i, m := range Models {
+		if m.Columns[0] == n {
+			if found != nil {
+				return nil
+			}
+			found = &Models[i]
+		}
+	}
+	return found
+}
@@ -0,0 +1,199 @@
+package glyph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+var oggMagic = []byte("OggS")
+
+// Page header flags
+const (
+	flagContinued = 0x01
+	flagFirst     = 0x02
+	flagLast      = 0x04
+)
+
+// page is a single Ogg page of a logical bitstream
+type page struct {
+	Flags    byte
+	Granule  int64
+	Serial   uint32
+	Sequence uint32
+	// Lacing holds the segment sizes, a packet ends with a segment below 255
+	Lacing []byte
+	Data   []byte
+}
+
+// readPages reads all pages of an Ogg stream
+func readPages(r io.Reader) ([]page, error) {
+	var pages []page
+	header := make([]byte, 27)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return pages, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("truncated Ogg page header: %w", err)
+		}
+		if !bytes.Equal(header[:4], oggMagic) {
+			return nil, fmt.Errorf("not an Ogg file (bad page signature at page %d)", len(pages))
+		}
+		if header[4] != 0 {
+			return nil, fmt.Errorf("unsupported Ogg version %d", header[4])
+		}
+
+		p := page{
+			Flags:    header[5],
+			Granule:  int64(binary.LittleEndian.Uint64(header[6:14])),
+			Serial:   binary.LittleEndian.Uint32(header[14:18]),
+			Sequence: binary.LittleEndian.Uint32(header[18:22]),
+			Lacing:   make([]byte, header[26]),
+		}
+		if _, err := io.ReadFull(r, p.Lacing); err != nil {
+			return nil, fmt.Errorf("truncated Ogg page %d: %w", len(pages), err)
+		}
+		size := 0
+		for _, l := range p.Lacing {
+			size += int(l)
+		}
+		p.Data = make([]byte, size)
+		if _, err := io.ReadFull(r, p.Data); err != nil {
+			return nil, fmt.Errorf("truncated Ogg page %d: %w", len(pages), err)
+		}
+
+		if want := binary.LittleEndian.Uint32(header[22:26]); p.checksum() != want {
+			return nil, fmt.Errorf("Ogg page %d is corrupt (checksum mismatch)", len(pages))
+		}
+		pages = append(pages, p)
+	}
+}
+
+// encode serializes the page, computing its checksum
+func (p *page) encode() []byte {
+	buf := make([]byte, 27+len(p.Lacing)+len(p.Data))
+	copy(buf, oggMagic)
+	buf[5] = p.Flags
+	binary.LittleEndian.PutUint64(buf[6:14], uint64(p.Granule))
+	binary.LittleEndian.PutUint32(buf[14:18], p.Serial)
+	binary.LittleEndian.PutUint32(buf[18:22], p.Sequence)
+	buf[26] = byte(len(p.Lacing))
+	copy(buf[27:], p.Lacing)
+	copy(buf[27+len(p.Lacing):], p.Data)
+
+	binary.LittleEndian.PutUint32(buf[22:26], oggCRC(buf))
+	return buf
+}
+
+func (p *page) checksum() uint32 {
+	buf := p.encode()
+	binary.LittleEndian.PutUint32(buf[22:26], 0)
+	return oggCRC(buf)
+}
+
+// packet is a complete packet and the pages it was read from
+type packet struct {
+	Data      []byte
+	FirstPage int
+	LastPage  int
+}
+
+// readPackets joins the segments of the first n packets of the stream with
+// the given serial number. n <= 0 reads all packets.
+func readPackets(pages []page, serial uint32, n int) ([]packet, error) {
+	var packets []packet
+	var cur *packet
+	for i, p := range pages {
+		if p.Serial != serial {
+			continue
+		}
+		if p.Flags&flagContinued == 0 && cur != nil {
+			return nil, fmt.Errorf("Ogg page %d starts a new packet before the previous one ended", i)
+		}
+
+		offset := 0
+		for _, l := range p.Lacing {
+			if cur == nil {
+				cur = &packet{FirstPage: i}
+			}
+			cur.Data = append(cur.Data, p.Data[offset:offset+int(l)]...)
+			offset += int(l)
+			if l < 255 {
+				cur.LastPage = i
+				packets = append(packets, *cur)
+				cur = nil
+				if n > 0 && len(packets) == n {
+					return packets, nil
+				}
+			}
+		}
+	}
+	if n > 0 && len(packets) < n {
+		return nil, fmt.Errorf("Ogg stream ends after %d of %d header packets", len(packets), n)
+	}
+	return packets, nil
+}
+
+var crcTable = func() [256]uint32 {
+	var t [256]uint32
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+// oggCRC is the CRC-32 variant used by Ogg (polynomial 0x04c11db7, no
+// reflection, zero initial value and no final xor)
+func oggCRC(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// paginate splits a packet into pages of at most 255 segments, the way
+// ffmpeg writes header packets: each one starts on a fresh page
+func paginate(data []byte, serial, sequence uint32, granule int64) []page {
+	lacing := make([]byte, 0, len(data)/255+1)
+	for n := len(data); ; n -= 255 {
+		if n < 255 {
+			lacing = append(lacing, byte(n))
+			break
+		}
+		lacing = append(lacing, 255)
+	}
+
+	var pages []page
+	for offset := 0; len(lacing) > 0; sequence++ {
+		p := page{Serial: serial, Sequence: sequence, Granule: -1}
+		if len(pages) > 0 {
+			p.Flags = flagContinued
+		}
+		n := min(len(lacing), 255)
+		p.Lacing, lacing = lacing[:n], lacing[n:]
+
+		size := 0
+		for _, l := range p.Lacing {
+			size += int(l)
+		}
+		p.Data = data[offset : offset+size]
+		offset += size
+
+		// Only the page on which the packet ends gets a granule position
+		if len(lacing) == 0 {
+			p.Granule = granule
+		}
+		pages = append(pages, p)
+	}
+	return pages
+}
@@ -0,0 +1,58 @@
+package preview
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// audioPlayer plays the composition with an external program, restarted
+// at the current position after every pause or seek
+type audioPlayer struct {
+	name string
+	args func(path string, pos time.Duration) []string
+	cmd  *exec.Cmd
+}
+
+var audioPlayers = []audioPlayer{
+	{name: "ffplay", args: func(path string, pos time.Duration) []string {
+		return []string{"-nodisp", "-autoexit", "-loglevel", "quiet", "-ss", seconds(pos), path}
+	}},
+	{name: "mpv", args: func(path string, pos time.Duration) []string {
+		return []string{"--no-video", "--really-quiet", "--start=" + seconds(pos), path}
+	}},
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// findAudioPlayer returns the first installed player
+func findAudioPlayer() (*audioPlayer, error) {
+	for i := range audioPlayers {
+		if _, err := exec.LookPath(audioPlayers[i].name); err == nil {
+			p := audioPlayers[i]
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("no audio player found, install ffplay (ffmpeg) or mpv")
+}
+
+func (a *audioPlayer) start(path string, pos time.Duration) error {
+	a.stop()
+	a.cmd = exec.Command(a.name, a.args(path, pos)...)
+	if err := a.cmd.Start(); err != nil {
+		a.cmd = nil
+		return fmt.Errorf("failed to start %s: %w", a.name, err)
+	}
+	return nil
+}
+
+func (a *audioPlayer) stop() {
+	if a.cmd == nil {
+		return
+	}
+	a.cmd.Process.Kill()
+	a.cmd.Wait()
+	a.cmd = nil
+}
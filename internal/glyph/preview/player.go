@@ -0,0 +1,149 @@
+package preview
+
+import (
+	"time"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+)
+
+// Playback speed limits
+const (
+	MinSpeed = 0.125
+	MaxSpeed = 8.0
+)
+
+// SeekStep is how far the arrow keys move
+const SeekStep = time.Second
+
+// Keys understood by the player
+const (
+	KeyNone = iota
+	KeyQuit
+	KeyPause
+	KeyLeft
+	KeyRight
+	KeyStepBack
+	KeyStep
+	KeySlower
+	KeyFaster
+	KeyRestart
+)
+
+// player is the playback state, independent of the terminal
+type player struct {
+	pos    time.Duration
+	length time.Duration
+	speed  float64
+	paused bool
+	loop   bool
+}
+
+func newPlayer(c *glyph.Composition, speed float64, loop bool) *player {
+	return &player{length: max(c.Duration, c.LightDuration()), speed: speed, loop: loop}
+}
+
+// advance moves the position by elapsed wall time and reports whether the
+// end was reached
+func (p *player) advance(elapsed time.Duration) bool {
+	if p.paused {
+		return false
+	}
+	p.pos += time.Duration(float64(elapsed) * p.speed)
+	if p.pos < p.length {
+		return false
+	}
+	if p.loop {
+		p.pos = 0
+		return false
+	}
+	p.pos = p.length
+	p.paused = true
+	return true
+}
+
+// frame returns the index of the frame at the current position
+func (p *player) frame(frames int) int {
+	if frames == 0 {
+		return -1
+	}
+	return min(int(p.pos/glyph.FrameInterval), frames-1)
+}
+
+func (p *player) seek(d time.Duration) {
+	p.pos = min(max(p.pos+d, 0), p.length)
+}
+
+// handle applies a key and reports whether playback should stop
+func (p *player) handle(key int) bool {
+	switch key {
+	case KeyQuit:
+		return true
+	case KeyPause:
+		if p.paused && p.pos >= p.length {
+			p.pos = 0
+		}
+		p.paused = !p.paused
+	case KeyLeft:
+		p.seek(-SeekStep)
+	case KeyRight:
+		p.seek(SeekStep)
+	case KeyStepBack:
+		p.paused = true
+		p.seek(-glyph.FrameInterval)
+	case KeyStep:
+		p.paused = true
+		p.seek(glyph.FrameInterval)
+	case KeySlower:
+		p.speed = max(p.speed/2, MinSpeed)
+	case KeyFaster:
+		p.speed = min(p.speed*2, MaxSpeed)
+	case KeyRestart:
+		p.pos = 0
+	}
+	return false
+}
+
+// parseKeys turns terminal input into keys, arrow keys arrive as escape
+// sequences
+func parseKeys(input []byte) []int {
+	var keys []int
+	for i := 0; i < len(input); i++ {
+		switch b := input[i]; b {
+		case 'q', 'Q', 3:
+			keys = append(keys, KeyQuit)
+		case ' ', 'p', 'k':
+			keys = append(keys, KeyPause)
+		case 'h':
+			keys = append(keys, KeyLeft)
+		case 'l':
+			keys = append(keys, KeyRight)
+		case ',':
+			keys = append(keys, KeyStepBack)
+		case '.':
+			keys = append(keys, KeyStep)
+		case '-', '_':
+			keys = append(keys, KeySlower)
+		case '+', '=':
+			keys = append(keys, KeyFaster)
+		case '0':
+			keys = append(keys, KeyRestart)
+		case 0x1b:
+			if i+2 < len(input) && input[i+1] == '[' {
+				switch input[i+2] {
+				case 'D':
+					keys = append(keys, KeyLeft)
+				case 'C':
+					keys = append(keys, KeyRight)
+				case 'A':
+					keys = append(keys, KeyFaster)
+				case 'B':
+					keys = append(keys, KeySlower)
+				}
+				i += 2
+			} else {
+				keys = append(keys, KeyQuit)
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,171 @@
+// Package preview plays glyph compositions in the terminal
+package preview
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+	"golang.org/x/term"
+)
+
+// DefaultRows fits the phone and the status lines in a 24 line terminal
+const DefaultRows = 19
+
+// Options control playback
+type Options struct {
+	// Speed multiplies the playback rate, 1 is real time
+	Speed float64
+	// Rows is the height of the phone in terminal rows
+	Rows int
+	Loop bool
+	// Audio plays the sound with ffplay or mpv while at normal speed
+	Audio bool
+}
+
+// Run plays c until the user quits. path is the file the audio is played
+// from. Without a terminal on stdin there are no controls and playback
+// stops at the end.
+func Run(c *glyph.Composition, path string, opts Options) error {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("preview needs a terminal, use 'inspect' or 'render' instead")
+	}
+	if len(c.Frames) == 0 {
+		return fmt.Errorf("the composition has no light data")
+	}
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+	if opts.Rows <= 0 {
+		opts.Rows = DefaultRows
+	}
+	r, err := NewRenderer(c, opts.Rows)
+	if err != nil {
+		return err
+	}
+
+	var audio *audioPlayer
+	if opts.Audio {
+		if audio, err = findAudioPlayer(); err != nil {
+			return err
+		}
+		defer audio.stop()
+	}
+
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	keys := make(chan int, 16)
+	if interactive {
+		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("failed to configure terminal: %w", err)
+		}
+		defer term.Restore(int(os.Stdin.Fd()), state)
+		go readKeys(os.Stdin, keys)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	// Alternate screen, hidden cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	p := newPlayer(c, opts.Speed, opts.Loop)
+	title := c.Title
+	if title == "" {
+		title = path
+	}
+	header := fmt.Sprintf("%s · %s", title, c.Model.DisplayName)
+
+	syncAudio := func() {
+		if audio == nil {
+			return
+		}
+		if p.paused || p.speed != 1 {
+			audio.stop()
+		} else if err := audio.start(path, p.pos); err != nil {
+			audio = nil
+		}
+	}
+	syncAudio()
+
+	ticker := time.NewTicker(glyph.FrameInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		draw(os.Stdout, r, c, p, header, interactive)
+
+		select {
+		case <-interrupt:
+			return nil
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			if p.handle(key) {
+				return nil
+			}
+			syncAudio()
+		case now := <-ticker.C:
+			before := p.pos
+			ended := p.advance(now.Sub(last))
+			last = now
+			if ended && !interactive {
+				return nil
+			}
+			// Restart the audio when playback stopped or wrapped around
+			if ended || p.pos < before {
+				syncAudio()
+			}
+		}
+	}
+}
+
+func readKeys(r io.Reader, keys chan<- int) {
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+func draw(w io.Writer, r *Renderer, c *glyph.Composition, p *player, header string, interactive bool) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	fmt.Fprintf(&b, " %s\x1b[K\r\n\r\n", header)
+
+	var frame []int
+	if i := p.frame(len(c.Frames)); i >= 0 && p.pos < c.LightDuration() {
+		frame = c.Frames[i]
+	}
+	for _, line := range r.Render(frame) {
+		fmt.Fprintf(&b, " %s\x1b[K\r\n", line)
+	}
+
+	state := "▶"
+	if p.paused {
+		state = "⏸"
+	}
+	fmt.Fprintf(&b, "\r\n %s %s / %s  frame %d/%d  %gx\x1b[K\r\n", state,
+		clock(p.pos), clock(p.length), p.frame(len(c.Frames))+1, len(c.Frames), p.speed)
+	if interactive {
+		b.WriteString(" space pause · ←/→ seek · ,/. step · -/+ speed · 0 restart · q quit\x1b[K")
+	}
+	io.WriteString(w, b.String())
+}
+
+func clock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%05.2f", int(d.Minutes()), d.Seconds()-float64(int(d.Minutes())*60))
+}
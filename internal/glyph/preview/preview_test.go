@@ -0,0 +1,131 @@
+package preview
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+)
+
+func testComposition(t *testing.T, model string, columns, frames int) *glyph.Composition {
+	t.Helper()
+
+	m, err := glyph.LookupModel(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &glyph.Composition{Model: m, Columns: columns, Duration: time.Second}
+	for i := 0; i < frames; i++ {
+		frame := make([]int, columns)
+		frame[i%columns] = glyph.MaxBrightness
+		c.Frames = append(c.Frames, frame)
+	}
+	return c
+}
+
+func TestRenderer(t *testing.T) {
+	for _, tc := range []struct {
+		model   string
+		columns int
+	}{{"phone1", 15}, {"phone1", 5}, {"phone2", 33}, {"phone2a", 26}, {"phone3a", 36}} {
+		c := testComposition(t, tc.model, tc.columns, 1)
+		r, err := NewRenderer(c, 12)
+		if err != nil {
+			t.Fatalf("%s/%d: %v", tc.model, tc.columns, err)
+		}
+
+		lines := r.Render(c.Frames[0])
+		if len(lines) != 12 {
+			t.Errorf("%s: got %d lines, want 12", tc.model, len(lines))
+		}
+		output := strings.Join(lines, "\n")
+		if !strings.Contains(output, "255;255;255") {
+			t.Errorf("%s/%d: lit zone not drawn", tc.model, tc.columns)
+		}
+		if !strings.Contains(output, "48;48;48") {
+			t.Errorf("%s/%d: unlit zones not drawn", tc.model, tc.columns)
+		}
+	}
+
+	c := testComposition(t, "phone2a", 26, 1)
+	c.Columns = 5
+	if _, err := NewRenderer(c, 12); err == nil {
+		t.Error("expected an error for a zone count the model cannot show")
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	c := testComposition(t, "phone2", 33, 120)
+	p := newPlayer(c, 1, false)
+	// 120 frames of light data outlast the second of audio
+	if p.length != c.LightDuration() {
+		t.Errorf("length = %s, want %s", p.length, c.LightDuration())
+	}
+
+	p.advance(500 * time.Millisecond)
+	if got := p.frame(len(c.Frames)); got != 30 {
+		t.Errorf("frame after 500ms = %d, want 30", got)
+	}
+
+	p.handle(KeyFaster)
+	p.advance(100 * time.Millisecond)
+	if p.pos != 700*time.Millisecond {
+		t.Errorf("position at 2x = %s, want 700ms", p.pos)
+	}
+
+	p.handle(KeyPause)
+	p.advance(time.Second)
+	if p.pos != 700*time.Millisecond {
+		t.Error("paused player advanced")
+	}
+	p.handle(KeyStep)
+	if got := p.frame(len(c.Frames)); got != 43 {
+		t.Errorf("frame after stepping = %d, want 43", got)
+	}
+
+	p.handle(KeyLeft)
+	if p.pos != 0 {
+		t.Errorf("seeking before the start gave %s", p.pos)
+	}
+
+	p.handle(KeyPause)
+	if !p.advance(time.Minute) || p.pos != p.length || !p.paused {
+		t.Error("expected playback to stop at the end")
+	}
+	if got := p.frame(len(c.Frames)); got != 119 {
+		t.Errorf("frame at the end = %d, want 119", got)
+	}
+	p.handle(KeyPause)
+	if p.pos != 0 || p.paused {
+		t.Error("resuming at the end should restart")
+	}
+
+	loop := newPlayer(c, 1, true)
+	if loop.advance(loop.length+time.Millisecond) || loop.pos != 0 {
+		t.Error("looping player should wrap around")
+	}
+
+	for i := 0; i < 10; i++ {
+		p.handle(KeySlower)
+	}
+	if p.speed != MinSpeed {
+		t.Errorf("speed = %g, want %g", p.speed, MinSpeed)
+	}
+	if !p.handle(KeyQuit) {
+		t.Error("q should quit")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte(" \x1b[D\x1b[C+-,.0q\x1b"))
+	want := []int{KeyPause, KeyLeft, KeyRight, KeyFaster, KeySlower, KeyStepBack, KeyStep, KeyRestart, KeyQuit, KeyQuit}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("key %d = %d, want %d", i, got[i], want[i])
+		}
+	}
+}
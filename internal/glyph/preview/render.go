@@ -0,0 +1,83 @@
+package preview
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+)
+
+// Renderer draws frames of a composition as Unicode half blocks, two
+// pixels per character cell
+type Renderer struct {
+	canvas  *glyph.Canvas
+	columns []int
+}
+
+// NewRenderer rasterizes the layout of c to the given number of terminal
+// rows
+func NewRenderer(c *glyph.Composition, rows int) (*Renderer, error) {
+	if c.Model == nil {
+		return nil, fmt.Errorf("unknown phone model, cannot draw %d zones", c.Columns)
+	}
+	columns, err := c.Model.ZoneColumns(c.Columns)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{canvas: c.Model.Layout.Rasterize(rows*2, 1), columns: columns}, nil
+}
+
+// Width is the number of character columns of a rendered frame
+func (r *Renderer) Width() int {
+	return r.canvas.Width
+}
+
+// Render returns the lines of the layout lit by frame
+func (r *Renderer) Render(frame []int) []string {
+	brightness := glyph.ZoneBrightness(frame, r.columns)
+
+	c := r.canvas
+	pixel := func(x, y int) (int, bool) {
+		zone := c.Pixels[y*c.Width+x]
+		if zone < 0 {
+			return 0, false
+		}
+		return brightness[zone], true
+	}
+
+	lines := make([]string, 0, (c.Height+1)/2)
+	for y := 0; y < c.Height; y += 2 {
+		var b strings.Builder
+		for x := 0; x < c.Width; x++ {
+			top, hasTop := pixel(x, y)
+			bottom, hasBottom := 0, false
+			if y+1 < c.Height {
+				bottom, hasBottom = pixel(x, y+1)
+			}
+
+			switch {
+			case hasTop && hasBottom:
+				fmt.Fprintf(&b, "%s%s▀", fg(top), bg(bottom))
+			case hasTop:
+				fmt.Fprintf(&b, "%s\x1b[49m▀", fg(top))
+			case hasBottom:
+				fmt.Fprintf(&b, "%s\x1b[49m▄", fg(bottom))
+			default:
+				b.WriteString("\x1b[0m ")
+			}
+		}
+		b.WriteString("\x1b[0m")
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+func fg(brightness int) string {
+	c := glyph.Shade(brightness)
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", c.R, c.G, c.B)
+}
+
+func bg(brightness int) string {
+	c := glyph.Shade(brightness)
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", c.R, c.G, c.B)
+}
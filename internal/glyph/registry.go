@@ -0,0 +1,179 @@
+package glyph
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelFileName is the file in the data directory that adds phone models
+const ModelFileName = "models.yaml"
+
+// modelFile is the format of a model file
+type modelFile struct {
+	Models []Model `yaml:"models"`
+}
+
+// LoadModelFile adds the models defined in the YAML file at path to Models.
+// A model with the name of a known one replaces it. A missing file is not
+// an error, and an invalid one leaves Models unchanged.
+func LoadModelFile(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var file modelFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for _, m := range file.Models {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("invalid model in %s: %w", path, err)
+		}
+		if seen[strings.ToLower(m.Name)] {
+			return fmt.Errorf("invalid model in %s: %s is defined twice", path, m.Name)
+		}
+		seen[strings.ToLower(m.Name)] = true
+	}
+	for _, m := range file.Models {
+		m.Source = path
+		registerModel(m)
+	}
+	return nil
+}
+
+func registerModel(m Model) {
+	for i := range Models {
+		if strings.EqualFold(Models[i].Name, m.Name) {
+			Models[i] = m
+			return
+		}
+	}
+	Models = append(Models, m)
+}
+
+// Validate checks that the layout and zone maps of a model fit its columns
+func (m *Model) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("model without a name")
+	}
+	if strings.ContainsAny(m.Name, " \t") {
+		return fmt.Errorf("%s: names cannot contain spaces, use display_name", m.Name)
+	}
+	if m.DisplayName == "" || m.Codename == "" {
+		return fmt.Errorf("%s: display_name and codename are required", m.Name)
+	}
+	if len(m.Columns) == 0 {
+		return fmt.Errorf("%s: columns lists no zone counts", m.Name)
+	}
+	if m.Layout.Aspect <= 0 {
+		return fmt.Errorf("%s: layout aspect must be positive", m.Name)
+	}
+	for i, s := range m.Layout.Shapes {
+		switch s.Kind {
+		case ShapeLine, ShapeArc, ShapeDot:
+		default:
+			return fmt.Errorf("%s: shape %d has unknown kind %q, expected line, arc or dot", m.Name, i+1, s.Kind)
+		}
+		if s.Segments < 0 {
+			return fmt.Errorf("%s: shape %d has a negative segment count", m.Name, i+1)
+		}
+	}
+
+	native := m.Layout.Zones()
+	if m.Columns[0] != native {
+		return fmt.Errorf("%s: the layout has %d zones but the native column count is %d", m.Name, native, m.Columns[0])
+	}
+	for _, columns := range m.Columns[1:] {
+		mapping, ok := m.ZoneMap[columns]
+		if !ok {
+			return fmt.Errorf("%s: no zone_map for %d columns", m.Name, columns)
+		}
+		if len(mapping) != native {
+			return fmt.Errorf("%s: the zone_map for %d columns has %d entries, expected %d", m.Name, columns, len(mapping), native)
+		}
+		for _, col := range mapping {
+			if col < 0 || col >= columns {
+				return fmt.Errorf("%s: the zone_map for %d columns refers to column %d", m.Name, columns, col+1)
+			}
+		}
+	}
+	return nil
+}
+
+// PrintModel writes the details of a model: names, zone counts and the
+// glyphs that label files and lint address by number
+func PrintModel(w io.Writer, m *Model) {
+	source := "built-in"
+	if m.Source != "" {
+		source = m.Source
+	}
+	fmt.Fprintf(w, "Name:         %s\n", m.Name)
+	fmt.Fprintf(w, "Display name: %s\n", m.DisplayName)
+	fmt.Fprintf(w, "Codename:     %s\n", m.Codename)
+	fmt.Fprintf(w, "Composer tag: %s\n", m.ComposerTag())
+	fmt.Fprintf(w, "Source:       %s\n", source)
+	fmt.Fprintf(w, "Zones:        %d\n", m.Columns[0])
+
+	compat := append([]int{}, m.Columns[1:]...)
+	sort.Sort(sort.Reverse(sort.IntSlice(compat)))
+	for _, columns := range compat {
+		fmt.Fprintf(w, "Compat mode:  %d zones (column → zones: %s)\n", columns, describeZoneMap(m.ZoneMap[columns], columns))
+	}
+
+	fmt.Fprintf(w, "\n%-6s %-5s %s\n", "Glyph", "Kind", "Zones")
+	zone := 1
+	for i, s := range m.Layout.Shapes {
+		n := max(s.Segments, 1)
+		zones := fmt.Sprintf("%d", zone)
+		if n > 1 {
+			zones = fmt.Sprintf("%d-%d (%d segments)", zone, zone+n-1, n)
+		}
+		fmt.Fprintf(w, "%-6d %-5s %s\n", i+1, s.Kind, zones)
+		zone += n
+	}
+}
+
+// describeZoneMap summarizes which native zones every column drives, e.g.
+// "1 → 1-2, 2 → 3, ..."
+func describeZoneMap(mapping []int, columns int) string {
+	zones := make([][]int, columns)
+	for zone, col := range mapping {
+		zones[col] = append(zones[col], zone+1)
+	}
+	parts := make([]string, columns)
+	for col, z := range zones {
+		parts[col] = fmt.Sprintf("%d → %s", col+1, formatRanges(z))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatRanges formats sorted numbers as ranges, e.g. "1-3 7"
+func formatRanges(numbers []int) string {
+	var parts []string
+	for i := 0; i < len(numbers); {
+		j := i
+		for j+1 < len(numbers) && numbers[j+1] == numbers[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, fmt.Sprintf("%d", numbers[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", numbers[i], numbers[j]))
+		}
+		i = j + 1
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,120 @@
+package glyph
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testModelFile = `models:
+  - name: phone9
+    display_name: Phone (9)
+    codename: Tetris
+    columns: [3, 1]
+    layout:
+      aspect: 0.5
+      shapes:
+        - {kind: line, x1: 0.2, y1: 0.5, x2: 0.8, y2: 0.5, segments: 2}
+        - {kind: dot, x1: 0.5, y1: 0.9}
+    zone_map:
+      1: [0, 0, 0]
+  - name: phone2a
+    display_name: Phone (2a) Plus
+    codename: PacmanPro
+    columns: [1]
+    layout:
+      aspect: 0.47
+      shapes:
+        - {kind: arc, cx: 0.5, cy: 0.25, r: 0.36, start: 140, end: 400}
+`
+
+func restoreModels(t *testing.T) {
+	saved := append([]Model{}, Models...)
+	t.Cleanup(func() { Models = saved })
+}
+
+func TestLoadModelFile(t *testing.T) {
+	restoreModels(t)
+	path := filepath.Join(t.TempDir(), ModelFileName)
+	if err := os.WriteFile(path, []byte(testModelFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	builtin := len(Models)
+	if err := LoadModelFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(Models) != builtin+1 {
+		t.Fatalf("got %d models, want %d", len(Models), builtin+1)
+	}
+
+	m, err := LookupModel("tetris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Source != path || !m.SupportsColumns(1) {
+		t.Errorf("unexpected model %+v", m)
+	}
+	if m, _ := LookupModel("phone2a"); m.DisplayName != "Phone (2a) Plus" {
+		t.Errorf("phone2a was not replaced: %+v", m)
+	}
+
+	var buf bytes.Buffer
+	PrintModel(&buf, m)
+	for _, want := range []string{"Compat mode:  1 zones (column → zones: 1 → 1-3)", "1      line  1-2 (2 segments)", "2      dot   3"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("summary lacks %q:\n%s", want, buf.String())
+		}
+	}
+
+	if err := LoadModelFile(filepath.Join(t.TempDir(), "missing.yaml")); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+func TestLoadModelFileInvalid(t *testing.T) {
+	restoreModels(t)
+	path := filepath.Join(t.TempDir(), ModelFileName)
+	// The valid models of the file are not added either
+	if err := os.WriteFile(path, []byte(testModelFile+"  - name: broken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	builtin := len(Models)
+	if err := LoadModelFile(path); err == nil {
+		t.Fatal("expected an error for a model without display_name")
+	}
+	if len(Models) != builtin {
+		t.Errorf("got %d models, want the %d built-in ones", len(Models), builtin)
+	}
+	if _, err := LookupModel("tetris"); err == nil {
+		t.Error("models of an invalid file were registered")
+	}
+}
+
+func TestValidateModel(t *testing.T) {
+	for _, m := range Models {
+		if err := m.Validate(); err != nil {
+			t.Errorf("built-in model: %v", err)
+		}
+	}
+
+	valid := func() Model {
+		return Model{Name: "x", DisplayName: "X", Codename: "X", Columns: []int{2, 1},
+			Layout:  Layout{Aspect: 0.5, Shapes: []Shape{line(0, 0, 1, 1, 2)}},
+			ZoneMap: map[int][]int{1: {0, 0}}}
+	}
+	for want, change := range map[string]func(m *Model){
+		"the layout has 2 zones but the native column count is 3": func(m *Model) { m.Columns[0] = 3 },
+		"no zone_map for 1 columns":                               func(m *Model) { m.ZoneMap = nil },
+		"refers to column 2":                                      func(m *Model) { m.ZoneMap[1] = []int{0, 1} },
+		`unknown kind "circle"`:                                   func(m *Model) { m.Layout.Shapes[0].Kind = "circle" },
+		"names cannot contain spaces":                             func(m *Model) { m.Name = "my phone" },
+	} {
+		m := valid()
+		change(&m)
+		if err := m.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, want an error containing %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// APNG frame control values
+const (
+	apngDisposeNone = 0
+	apngBlendSource = 0
+)
+
+// pngChunk is a chunk of an encoded PNG
+type pngChunk struct {
+	Type string
+	Data []byte
+}
+
+// splitPNG returns the chunks of a PNG written by image/png
+func splitPNG(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("missing PNG signature")
+	}
+	data = data[len(pngSignature):]
+
+	var chunks []pngChunk
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+		n := binary.BigEndian.Uint32(data)
+		if uint64(n)+12 > uint64(len(data)) {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{Type: string(data[4:8]), Data: data[8 : 8+n]})
+		data = data[12+n:]
+	}
+	return chunks, nil
+}
+
+// apngWriter writes the chunks of an animated PNG
+type apngWriter struct {
+	w   io.Writer
+	seq uint32
+	err error
+}
+
+func (a *apngWriter) chunk(typ string, data []byte) {
+	if a.err != nil {
+		return
+	}
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+
+	for _, b := range [][]byte{header[:], data, sum[:]} {
+		if _, err := a.w.Write(b); err != nil {
+			a.err = err
+			return
+		}
+	}
+}
+
+// sequenced prefixes data with the next sequence number, which fcTL and
+// fdAT chunks share
+func (a *apngWriter) sequenced(data []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, a.seq)
+	a.seq++
+	return append(out, data...)
+}
+
+// frameControl writes the fcTL chunk of a full size frame shown for
+// num/den seconds
+func (a *apngWriter) frameControl(bounds image.Rectangle, num, den int) {
+	data := binary.BigEndian.AppendUint32(nil, uint32(bounds.Dx()))
+	data = binary.BigEndian.AppendUint32(data, uint32(bounds.Dy()))
+	data = binary.BigEndian.AppendUint32(data, 0)
+	data = binary.BigEndian.AppendUint32(data, 0)
+	data = binary.BigEndian.AppendUint16(data, uint16(num))
+	data = binary.BigEndian.AppendUint16(data, uint16(den))
+	data = append(data, apngDisposeNone, apngBlendSource)
+	a.chunk("fcTL", a.sequenced(data))
+}
+
+func writeAPNG(out string, c *glyph.Composition, d *drawer, steps []step, fps int) error {
+	return writeFile(out, func(f *os.File) error {
+		return encodeAPNG(f, c, d, steps, fps)
+	})
+}
+
+// encodeAPNG encodes every step with image/png and reassembles the chunks
+// into an animation. All frames share the palette of the first one.
+func encodeAPNG(w io.Writer, c *glyph.Composition, d *drawer, steps []step, fps int) error {
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+	a := &apngWriter{w: w}
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+
+	for i, s := range steps {
+		img := d.draw(c, s.Light)
+		var buf bytes.Buffer
+		if err := encoder.Encode(&buf, img); err != nil {
+			return err
+		}
+		chunks, err := splitPNG(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			// Everything up to the image data describes the whole animation
+			for _, ch := range chunks {
+				if ch.Type == "IDAT" {
+					break
+				}
+				a.chunk(ch.Type, ch.Data)
+				if ch.Type == "IHDR" {
+					actl := binary.BigEndian.AppendUint32(nil, uint32(len(steps)))
+					// 0 plays the animation forever
+					actl = binary.BigEndian.AppendUint32(actl, 0)
+					a.chunk("acTL", actl)
+				}
+			}
+		}
+
+		a.frameControl(img.Bounds(), s.Frames, fps)
+		for _, ch := range chunks {
+			if ch.Type != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				a.chunk("IDAT", ch.Data)
+			} else {
+				a.chunk("fdAT", a.sequenced(ch.Data))
+			}
+		}
+	}
+	a.chunk("IEND", nil)
+	return a.err
+}
@@ -0,0 +1,245 @@
+// Package render turns glyph compositions into animated images
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+)
+
+// Output formats
+const (
+	FormatGIF    = "gif"
+	FormatAPNG   = "apng"
+	FormatPNGSeq = "png-seq"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatGIF, FormatAPNG, FormatPNGSeq}
+
+// Defaults for Options
+const (
+	DefaultHeight = 480
+	DefaultFPS    = 30
+	// MaxGIFFPS is the highest rate GIF delays (in 1/100 s) can express
+	// without browsers slowing the animation down
+	MaxGIFFPS = 50
+)
+
+// Options control the output
+type Options struct {
+	Format string
+	// Height is the image height in pixels, the width follows from the
+	// phone's aspect ratio
+	Height int
+	FPS    int
+	// Background fills everything but the light strips
+	Background color.RGBA
+}
+
+// Render writes c to out and returns the number of images written. For
+// png-seq, out is a directory that receives one PNG per frame.
+func Render(c *glyph.Composition, out string, opts Options) (int, error) {
+	if opts.Height <= 0 {
+		opts.Height = DefaultHeight
+	}
+	if opts.FPS <= 0 {
+		opts.FPS = DefaultFPS
+	}
+	if opts.Format == FormatGIF && opts.FPS > MaxGIFFPS {
+		return 0, fmt.Errorf("GIF supports at most %d frames per second", MaxGIFFPS)
+	}
+	if len(c.Frames) == 0 {
+		return 0, fmt.Errorf("the composition has no light data")
+	}
+	if c.Model == nil {
+		return 0, fmt.Errorf("unknown phone model, cannot draw %d zones", c.Columns)
+	}
+	mapping, err := c.Model.ZoneColumns(c.Columns)
+	if err != nil {
+		return 0, err
+	}
+
+	d := &drawer{
+		canvas:  c.Model.Layout.Rasterize(opts.Height, max(float64(opts.Height)/60, 1)),
+		mapping: mapping,
+		palette: newPalette(opts.Background),
+	}
+
+	switch opts.Format {
+	case FormatGIF:
+		steps := timeline(c, opts.FPS, true)
+		return len(steps), writeGIF(out, c, d, steps, opts.FPS)
+	case FormatAPNG:
+		steps := timeline(c, opts.FPS, true)
+		return len(steps), writeAPNG(out, c, d, steps, opts.FPS)
+	case FormatPNGSeq:
+		steps := timeline(c, opts.FPS, false)
+		return len(steps), writePNGSeq(out, c, d, steps)
+	}
+	return 0, fmt.Errorf("unknown format %q, expected %s", opts.Format, strings.Join(Formats, ", "))
+}
+
+// ParseColor parses #rgb, #rrggbb, #rrggbbaa, black, white or transparent
+func ParseColor(s string) (color.RGBA, error) {
+	switch strings.ToLower(s) {
+	case "black":
+		return color.RGBA{0, 0, 0, 255}, nil
+	case "white":
+		return color.RGBA{255, 255, 255, 255}, nil
+	case "transparent":
+		return color.RGBA{}, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected e.g. #000000 or transparent", s)
+	}
+	// color.RGBA holds premultiplied values
+	a := uint8(v)
+	premultiply := func(c uint8) uint8 { return uint8(uint32(c) * uint32(a) / 255) }
+	return color.RGBA{premultiply(uint8(v >> 24)), premultiply(uint8(v >> 16)), premultiply(uint8(v >> 8)), a}, nil
+}
+
+// step is an image shown for Frames output frames
+type step struct {
+	// Light is the index of the light data row, -1 once it has ended
+	Light  int
+	Start  int
+	Frames int
+}
+
+// timeline samples the composition at fps. With merge, consecutive
+// samples showing the same light data become a single step.
+func timeline(c *glyph.Composition, fps int, merge bool) []step {
+	length := max(c.Duration, c.LightDuration())
+	total := max(int((length*time.Duration(fps)+time.Second-1)/time.Second), 1)
+
+	var steps []step
+	for i := 0; i < total; i++ {
+		light := int(time.Duration(i) * time.Second / time.Duration(fps) / glyph.FrameInterval)
+		if light >= len(c.Frames) {
+			light = -1
+		}
+		if merge && len(steps) > 0 {
+			last := &steps[len(steps)-1]
+			// Delays are 16 bit in both GIF and APNG
+			if sameLight(c, last.Light, light) && last.Frames < math.MaxUint16/100 {
+				last.Frames++
+				continue
+			}
+		}
+		steps = append(steps, step{Light: light, Start: i, Frames: 1})
+	}
+	return steps
+}
+
+func sameLight(c *glyph.Composition, a, b int) bool {
+	if a == b {
+		return true
+	}
+	if a < 0 || b < 0 {
+		return false
+	}
+	return slices.Equal(c.Frames[a], c.Frames[b])
+}
+
+// drawer paints frames onto paletted images
+type drawer struct {
+	canvas  *glyph.Canvas
+	mapping []int
+	palette color.Palette
+}
+
+// Palette index 0 is the background, 1-256 would be the shades from unlit
+// to fully lit, squeezed into the remaining 255 entries
+const shades = 255
+
+func newPalette(background color.RGBA) color.Palette {
+	p := color.Palette{background}
+	for i := 0; i < shades; i++ {
+		p = append(p, glyph.Shade(i*glyph.MaxBrightness/(shades-1)))
+	}
+	return p
+}
+
+func (d *drawer) draw(c *glyph.Composition, light int) *image.Paletted {
+	var frame []int
+	if light >= 0 {
+		frame = c.Frames[light]
+	}
+	brightness := glyph.ZoneBrightness(frame, d.mapping)
+
+	canvas := d.canvas
+	img := image.NewPaletted(image.Rect(0, 0, canvas.Width, canvas.Height), d.palette)
+	for i, zone := range canvas.Pixels {
+		if zone >= 0 {
+			img.Pix[i] = uint8(1 + brightness[zone]*(shades-1)/glyph.MaxBrightness)
+		}
+	}
+	return img
+}
+
+func writeGIF(out string, c *glyph.Composition, d *drawer, steps []step, fps int) error {
+	anim := &gif.GIF{}
+	for _, s := range steps {
+		// Round the start and end separately so that delays do not drift
+		start := (s.Start*100 + fps/2) / fps
+		end := ((s.Start+s.Frames)*100 + fps/2) / fps
+		anim.Image = append(anim.Image, d.draw(c, s.Light))
+		anim.Delay = append(anim.Delay, end-start)
+	}
+
+	return writeFile(out, func(f *os.File) error {
+		return gif.EncodeAll(f, anim)
+	})
+}
+
+func writePNGSeq(dir string, c *glyph.Composition, d *drawer, steps []step) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for i, s := range steps {
+		path := filepath.Join(dir, fmt.Sprintf("frame_%05d.png", i+1))
+		err := writeFile(path, func(f *os.File) error {
+			return png.Encode(f, d.draw(c, s.Light))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, write func(*os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+)
+
+// testComposition lights zone 0 for the first half of one second of light
+// data and nothing for the second half
+func testComposition(t *testing.T) *glyph.Composition {
+	t.Helper()
+
+	m, err := glyph.LookupModel("phone2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &glyph.Composition{Model: m, Columns: 33, Duration: time.Second}
+	for i := 0; i < 60; i++ {
+		frame := make([]int, 33)
+		if i < 30 {
+			frame[0] = glyph.MaxBrightness
+		}
+		c.Frames = append(c.Frames, frame)
+	}
+	return c
+}
+
+func TestTimeline(t *testing.T) {
+	c := testComposition(t)
+
+	steps := timeline(c, 10, true)
+	if len(steps) != 2 || steps[0].Frames != 5 || steps[1].Start != 5 || steps[1].Frames != 5 {
+		t.Errorf("merged timeline = %+v, want two steps of 5 frames", steps)
+	}
+	if steps := timeline(c, 10, false); len(steps) != 10 {
+		t.Errorf("got %d steps without merging, want 10", len(steps))
+	}
+
+	// Audio that outlasts the light data shows unlit zones at the end
+	c.Duration = 2 * time.Second
+	steps = timeline(c, 10, true)
+	if last := steps[len(steps)-1]; last.Light != -1 || last.Start != 10 || last.Frames != 10 {
+		t.Errorf("last step = %+v, want 10 frames after the light data", last)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	for in, want := range map[string]color.RGBA{
+		"black":       {0, 0, 0, 255},
+		"transparent": {},
+		"#102030":     {0x10, 0x20, 0x30, 255},
+		"#fff":        {255, 255, 255, 255},
+		"ff000080":    {128, 0, 0, 128},
+	} {
+		got, err := ParseColor(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+		} else if got != want {
+			t.Errorf("%s = %v, want %v", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "red", "#12345", "#gggggg"} {
+		if _, err := ParseColor(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestRenderGIF(t *testing.T) {
+	c := testComposition(t)
+	out := filepath.Join(t.TempDir(), "out.gif")
+	n, err := Render(c, out, Options{Format: FormatGIF, Height: 120, FPS: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || len(anim.Image) != 2 {
+		t.Fatalf("got %d images (reported %d), want 2", len(anim.Image), n)
+	}
+	if anim.Delay[0] != 50 || anim.Delay[1] != 50 {
+		t.Errorf("delays = %v, want [50 50]", anim.Delay)
+	}
+	if h := anim.Image[0].Bounds().Dy(); h != 120 {
+		t.Errorf("height = %d, want 120", h)
+	}
+	if anim.Image[0].ColorIndexAt(0, 0) != 0 {
+		t.Error("corner is not the background")
+	}
+
+	if _, err := Render(c, out, Options{Format: FormatGIF, FPS: 60}); err == nil {
+		t.Error("expected an error for 60 fps GIFs")
+	}
+}
+
+func TestRenderAPNG(t *testing.T) {
+	c := testComposition(t)
+	out := filepath.Join(t.TempDir(), "out.png")
+	if _, err := Render(c, out, Options{Format: FormatAPNG, Height: 60, FPS: 30, Background: color.RGBA{}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Viewers without APNG support show the first frame
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Error("background is not transparent")
+	}
+
+	chunks, err := splitPNG(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	var seq []uint32
+	for _, ch := range chunks {
+		types = append(types, ch.Type)
+		switch ch.Type {
+		case "acTL":
+			if frames := binary.BigEndian.Uint32(ch.Data); frames != 2 {
+				t.Errorf("acTL frame count = %d, want 2", frames)
+			}
+		case "fcTL":
+			if num, den := binary.BigEndian.Uint16(ch.Data[20:]), binary.BigEndian.Uint16(ch.Data[22:]); num != 15 || den != 30 {
+				t.Errorf("frame delay = %d/%d, want 15/30", num, den)
+			}
+			fallthrough
+		case "fdAT":
+			seq = append(seq, binary.BigEndian.Uint32(ch.Data))
+		}
+	}
+	if types[0] != "IHDR" || types[1] != "acTL" || types[len(types)-1] != "IEND" {
+		t.Errorf("unexpected chunk order %v", types)
+	}
+	for i, s := range seq {
+		if s != uint32(i) {
+			t.Fatalf("sequence numbers = %v, want consecutive from 0", seq)
+		}
+	}
+}
+
+func TestRenderPNGSeq(t *testing.T) {
+	c := testComposition(t)
+	dir := filepath.Join(t.TempDir(), "frames")
+	n, err := Render(c, dir, Options{Format: FormatPNGSeq, Height: 40, FPS: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "frame_*.png"))
+	if n != 10 || len(files) != 10 {
+		t.Errorf("got %d files (reported %d), want 10", len(files), n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "frame_00001.png")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,219 @@
+package glyph
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// AlbumCustom is the ALBUM value of compositions not made by Nothing
+const AlbumCustom = "custom"
+
+// EncodePayload compresses and base64 encodes a tag value. Like the Glyph
+// Composer and GlyphModder.py it uses zlib level 9 and drops the padding.
+func EncodePayload(data []byte) string {
+	return base64.RawStdEncoding.EncodeToString(zlibCompress(data))
+}
+
+// FormatFrames is the inverse of ParseFrames: one line per frame, each
+// value followed by a comma and every line ending in CRLF
+func FormatFrames(frames [][]int) []byte {
+	var buf bytes.Buffer
+	for _, frame := range frames {
+		for _, v := range frame {
+			buf.WriteString(strconv.Itoa(v))
+			buf.WriteByte(',')
+		}
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// Update lists the tags to replace in a composition
+type Update struct {
+	// Title replaces TITLE when not empty
+	Title string
+	// Artist replaces ARTIST when not empty
+	Artist string
+	// Frames replaces the light data when not nil, along with COMPOSER,
+	// CUSTOM2 and ALBUM
+	Frames [][]int
+	// Custom1 is the Glyph Composer payload written with Frames. It is
+	// removed when empty, since it would no longer match the light data.
+	Custom1 string
+	// Model sets COMPOSER, it is derived from the zone count when nil
+	Model *Model
+}
+
+func (u *Update) apply(c *Comments) error {
+	if u.Title != "" {
+		c.Set(TagTitle, u.Title)
+	}
+	if u.Artist != "" {
+		c.Set(TagArtist, u.Artist)
+	}
+	if u.Frames == nil {
+		return nil
+	}
+
+	columns := 0
+	if len(u.Frames) > 0 {
+		columns = len(u.Frames[0])
+	}
+	model := u.Model
+	if model == nil {
+		if model = modelFromColumns(columns); model == nil {
+			return fmt.Errorf("no supported phone has %d zones", columns)
+		}
+	} else if !model.SupportsColumns(columns) {
+		return fmt.Errorf("%s does not have %d zones", model.DisplayName, columns)
+	}
+
+	c.Set(TagAlbum, AlbumCustom)
+	c.Set(TagAuthor, EncodePayload(FormatFrames(u.Frames)))
+	c.Set(TagComposer, model.ComposerTag())
+	if u.Custom1 != "" {
+		c.Set(TagCustom1, EncodePayload([]byte(u.Custom1)))
+	} else {
+		c.Delete(TagCustom1)
+	}
+	c.Set(TagCustom2, fmt.Sprintf("%dcols", columns))
+	return nil
+}
+
+// Rewrite copies an Ogg stream to w with u applied to its comment header.
+// The audio pages are copied unchanged, only their sequence numbers and
+// checksums change when the comment header needs more or fewer pages.
+func Rewrite(r io.Reader, w io.Writer, u *Update) error {
+	pages, err := readPages(r)
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("empty Ogg file")
+	}
+
+	serial := pages[0].Serial
+	ident, err := readPackets(pages, serial, 1)
+	if err != nil {
+		return err
+	}
+	info, err := parseIdentHeader(ident[0].Data)
+	if err != nil {
+		return err
+	}
+	count := 2
+	if info.Codec == CodecVorbis {
+		count = 3
+	}
+	headers, err := readPackets(pages, serial, count)
+	if err != nil {
+		return err
+	}
+
+	first, last := headers[1].FirstPage, headers[count-1].LastPage
+	if first == headers[0].LastPage || !endsPage(pages[last], serial) {
+		return fmt.Errorf("unsupported Ogg layout: header packets share pages with other packets")
+	}
+
+	comments, err := parseComments(info.Codec, headers[1].Data)
+	if err != nil {
+		return err
+	}
+	if err := u.apply(comments); err != nil {
+		return err
+	}
+
+	// Pages of other streams before the first header page stay in place,
+	// those in between follow the new header pages
+	out := append([]page{}, pages[:first]...)
+	seq := pages[first].Sequence
+	var others []page
+	var replaced int
+	for _, p := range pages[first : last+1] {
+		if p.Serial != serial {
+			others = append(others, p)
+		} else {
+			replaced++
+		}
+	}
+
+	packets := [][]byte{encodeComments(info.Codec, comments)}
+	for _, h := range headers[2:] {
+		packets = append(packets, h.Data)
+	}
+	var written int
+	for _, data := range packets {
+		header := paginate(data, serial, seq, 0)
+		seq += uint32(len(header))
+		written += len(header)
+		out = append(out, header...)
+	}
+	out = append(out, others...)
+
+	shift := uint32(written - replaced)
+	for _, p := range pages[last+1:] {
+		if p.Serial == serial {
+			p.Sequence += shift
+		}
+		out = append(out, p)
+	}
+
+	for i := range out {
+		if _, err := w.Write(out[i].encode()); err != nil {
+			return fmt.Errorf("failed to write Ogg page: %w", err)
+		}
+	}
+	return nil
+}
+
+// endsPage reports whether the last segment of p completes a packet
+func endsPage(p page, serial uint32) bool {
+	return p.Serial == serial && len(p.Lacing) > 0 && p.Lacing[len(p.Lacing)-1] < 255
+}
+
+// WriteFile applies u to the composition at path and writes the result to
+// out, or back to path when out is empty. The file is replaced atomically.
+func WriteFile(path, out string, u *Update) error {
+	if out == "" {
+		out = path
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(out); err == nil {
+		perm = info.Mode().Perm()
+	}
+	var buf bytes.Buffer
+	if err := Rewrite(bytes.NewReader(data), &buf, u); err != nil {
+		return fmt.Errorf("failed to rewrite %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(out), "."+filepath.Base(out)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	if err := os.Rename(tmp.Name(), out); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", out, err)
+	}
+	return nil
+}
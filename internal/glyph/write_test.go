@@ -0,0 +1,257 @@
+package glyph
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// The .author and .custom1 fixtures were encoded by Python with
+// base64.b64encode(zlib.compress(data, 9)) minus the padding
+func TestEncodePayloadMatchesPython(t *testing.T) {
+	for _, tc := range []struct{ input, want string }{
+		{"phone1.glypha", "phone1.author"},
+		{"phone1.glyphc1", "phone1.custom1"},
+	} {
+		got := EncodePayload(readFixture(t, tc.input))
+		if want := string(readFixture(t, tc.want)); got != want {
+			t.Errorf("EncodePayload(%s) differs from %s", tc.input, tc.want)
+		}
+	}
+
+	if got := EncodePayload(nil); got != "eNoDAAAAAAE" {
+		t.Errorf("EncodePayload(nil) = %q", got)
+	}
+}
+
+func TestZlibCompressRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	rng.Read(random)
+	repetitive := bytes.Repeat([]byte("0,0,4095,0,\r\n"), 20000)
+
+	for _, data := range [][]byte{random, repetitive, []byte("a")} {
+		encoded := base64.RawStdEncoding.EncodeToString(zlibCompress(data))
+		decoded, err := DecodePayload(encoded)
+		if err != nil {
+			t.Fatalf("DecodePayload failed: %v", err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("round trip of %d bytes changed the data", len(data))
+		}
+	}
+}
+
+func TestWriteFileReplacesGlyphData(t *testing.T) {
+	path := copyFixture(t, "phone2.ogg")
+	original, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := ParseFrameFile(filepath.Join("testdata", "phone1.glypha"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom1 := string(readFixture(t, "phone1.glyphc1"))
+	if err := WriteFile(path, "", &Update{Title: "Replaced", Frames: frames, Custom1: custom1}); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	c, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading the rewritten file failed: %v", err)
+	}
+	if c.Title != "Replaced" || c.Model.Name != "phone1" || c.Columns != 15 {
+		t.Errorf("unexpected metadata: title %q, model %s, %d zones", c.Title, c.Model.Name, c.Columns)
+	}
+	if c.Duration != original.Duration || c.Comments.Vendor != original.Comments.Vendor {
+		t.Error("audio stream or vendor changed")
+	}
+	if c.Custom1 != custom1 || len(c.Frames) != len(frames) {
+		t.Errorf("light data not replaced")
+	}
+
+	want := map[string]string{
+		TagAlbum:    AlbumCustom,
+		TagAuthor:   string(readFixture(t, "phone1.author")),
+		TagComposer: "v1-Spacewar Glyph Composer",
+		TagCustom1:  string(readFixture(t, "phone1.custom1")),
+		TagCustom2:  "15cols",
+		"ENCODER":   "Lavf60.16.100",
+	}
+	for key, value := range want {
+		if got, _ := c.Comments.Get(key); got != value {
+			t.Errorf("%s = %.40q, want %.40q", key, got, value)
+		}
+	}
+	// Replaced tags keep their position
+	if c.Comments.Fields[0].Key != TagTitle || c.Comments.Fields[len(c.Comments.Fields)-1].Key != TagCustom1 {
+		t.Errorf("unexpected tag order %v", c.Comments.Fields)
+	}
+}
+
+func TestRewriteRenumbersPages(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	frames := make([][]int, 20000)
+	for i := range frames {
+		frames[i] = make([]int, 33)
+		for j := range frames[i] {
+			frames[i][j] = rng.Intn(MaxBrightness + 1)
+		}
+	}
+
+	src := readFixture(t, "phone2.ogg")
+	var grown bytes.Buffer
+	if err := Rewrite(bytes.NewReader(src), &grown, &Update{Frames: frames}); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	assertSequential(t, grown.Bytes())
+
+	// Shrinking back to the original light data restores the file
+	original, err := Read(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shrunk bytes.Buffer
+	if err := Rewrite(bytes.NewReader(grown.Bytes()), &shrunk, &Update{Frames: original.Frames}); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	if !bytes.Equal(shrunk.Bytes(), src) {
+		t.Error("rewriting with the original light data did not reproduce the fixture")
+	}
+}
+
+func assertSequential(t *testing.T, data []byte) {
+	t.Helper()
+
+	pages, err := readPages(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("rewritten stream is invalid: %v", err)
+	}
+	if len(pages) < 4 {
+		t.Fatalf("expected the comment header to span several pages, got %d pages", len(pages))
+	}
+	for i, p := range pages {
+		if p.Sequence != uint32(i) {
+			t.Fatalf("page %d has sequence number %d", i, p.Sequence)
+		}
+	}
+}
+
+func TestRewriteVorbis(t *testing.T) {
+	src := readFixture(t, "vorbis.ogg")
+	frames, err := ParseFrameFile(filepath.Join("testdata", "phone1.glypha"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Rewrite(bytes.NewReader(src), &out, &Update{Frames: frames}); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	c, err := Read(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("reading the rewritten file failed: %v", err)
+	}
+	if c.Codec != CodecVorbis || c.Title != "Plain" || c.Duration.Seconds() != 2 {
+		t.Errorf("unexpected stream %s %q %s", c.Codec, c.Title, c.Duration)
+	}
+
+	pages, _ := readPages(bytes.NewReader(out.Bytes()))
+	headers, err := readPackets(pages, pages[0].Serial, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(headers[2].Data, []byte("\x05vorbis")) || len(headers[2].Data) != 3007 {
+		t.Error("setup header was not preserved")
+	}
+	if tags := headers[1].Data; tags[len(tags)-1] != 1 {
+		t.Error("comment header lacks the framing bit")
+	}
+}
+
+func TestRunModder(t *testing.T) {
+	path := copyFixture(t, "phone2.ogg")
+	dir := filepath.Dir(path)
+	var out bytes.Buffer
+
+	// Without options the light data is extracted next to the file
+	if err := RunModder(&ModderArgs{File: path}, &out); err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	extracted, err := os.ReadFile(filepath.Join(dir, "phone2.glypha"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(extracted), ",\r\n") {
+		t.Errorf("unexpected .glypha content %.40q", extracted)
+	}
+
+	// Writing the extracted data back keeps the AUTHOR tag byte for byte
+	before, _ := os.ReadFile(path)
+	if err := RunModder(&ModderArgs{File: path, Write: filepath.Join(dir, "phone2.glypha"), Title: "Sweep"}, &out); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	after, _ := os.ReadFile(path)
+	if !bytes.Equal(before, after) {
+		t.Error("writing the extracted light data changed the file")
+	}
+
+	// The title defaults to the file name
+	if err := RunModder(&ModderArgs{File: path, Write: filepath.Join(dir, "phone2.glypha")}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := ReadFile(path); err != nil || c.Title != "phone2" {
+		t.Errorf("expected title phone2, got %v", err)
+	}
+}
+
+func TestParseModderArgs(t *testing.T) {
+	a, err := ParseModderArgs([]string{"-t", "My tone", "--write=tone.glypha", "tone.ogg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Title != "My tone" || a.Write != "tone.glypha" || a.File != "tone.ogg" {
+		t.Errorf("unexpected arguments %+v", a)
+	}
+
+	for _, args := range [][]string{
+		{"--auto-fix-audio", "tone.ogg"},
+		{"-t"},
+		{"a.ogg", "b.ogg"},
+		{},
+	} {
+		if _, err := ParseModderArgs(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
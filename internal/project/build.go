@@ -0,0 +1,393 @@
+package project
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/snupai/cngt-cli/internal/batch"
+	"github.com/snupai/cngt-cli/internal/glyph"
+	"github.com/snupai/cngt-cli/internal/glyph/lint"
+)
+
+// StateDir holds the build state and logs, below the project directory
+const StateDir = ".cngt-build"
+
+const stateFileName = "state.json"
+
+// Build statuses
+const (
+	StatusBuilt    = "built"
+	StatusUpToDate = "up to date"
+	StatusFailed   = "failed"
+	StatusSkipped  = "skipped"
+)
+
+// CNGT scripts run by a build
+const (
+	TranslatorScript = "GlyphTranslator.py"
+	MigrateScript    = "GlyphMigrate.py"
+)
+
+// Scripts are the CNGT scripts a build may run, for callers that prepare
+// them before building
+var Scripts = []string{TranslatorScript, MigrateScript}
+
+// ScriptFunc runs a CNGT script with its output going to log
+type ScriptFunc func(ctx context.Context, script string, args []string, log io.Writer) error
+
+// BuildOptions control a build
+type BuildOptions struct {
+	// Only limits the build to the named compositions
+	Only []string
+	// Force rebuilds compositions whose inputs did not change
+	Force bool
+	// Jobs is the number of compositions built at a time
+	Jobs int
+	// Fingerprint identifies the tools, changing it rebuilds everything
+	Fingerprint string
+	Run         ScriptFunc
+	// Progress is called for every composition once its status is known
+	Progress func(*Result)
+}
+
+// Result is the outcome of building a composition
+type Result struct {
+	Composition *Composition
+	Status      string
+	Err         error
+	// Outputs are relative to the project directory
+	Outputs  []string
+	Log      string
+	Duration time.Duration
+}
+
+// state records the input hash and outputs of every composition built
+type state struct {
+	Compositions map[string]stateEntry `json:"compositions"`
+}
+
+type stateEntry struct {
+	Hash    string   `json:"hash"`
+	Outputs []string `json:"outputs"`
+}
+
+// Build builds the compositions whose inputs changed since the last build.
+// Every composition runs GlyphTranslator.py on its labels, writes the glyph
+// data into the audio for the phone the labels are written for and runs
+// GlyphMigrate.py for every other target model.
+func (p *Project) Build(ctx context.Context, opts BuildOptions) ([]*Result, error) {
+	compositions, err := p.selection(opts.Only)
+	if err != nil {
+		return nil, err
+	}
+	st := p.readState()
+
+	results := make([]*Result, len(compositions))
+	var jobs []*batch.Job
+	pending := map[int]*Result{}
+	hashes := map[string]string{}
+	for i, c := range compositions {
+		r := &Result{Composition: c, Log: filepath.Join(p.Dir, StateDir, "logs", c.Name+".log")}
+		results[i] = r
+
+		hash, err := p.hash(c, opts.Fingerprint)
+		if err != nil {
+			r.Status, r.Err = StatusFailed, err
+			progress(opts, r)
+			continue
+		}
+		hashes[c.Name] = hash
+		if entry, ok := st.Compositions[c.Name]; ok && !opts.Force && entry.Hash == hash && p.exist(entry.Outputs) {
+			r.Status, r.Outputs = StatusUpToDate, entry.Outputs
+			progress(opts, r)
+			continue
+		}
+
+		job := &batch.Job{Index: len(jobs) + 1, Input: c.Name, Log: r.Log}
+		jobs = append(jobs, job)
+		pending[job.Index] = r
+	}
+
+	run := func(ctx context.Context, job *batch.Job, log io.Writer) error {
+		r := pending[job.Index]
+		outputs, err := p.buildComposition(ctx, r.Composition, opts.Run, log)
+		r.Outputs = outputs
+		return err
+	}
+	batch.Run(ctx, jobs, opts.Jobs, run, func(br *batch.Result) {
+		r := pending[br.Job.Index]
+		r.Err, r.Duration = br.Err, br.Duration
+		switch br.Status {
+		case batch.StatusOK:
+			r.Status = StatusBuilt
+			st.Compositions[r.Composition.Name] = stateEntry{Hash: hashes[r.Composition.Name], Outputs: r.Outputs}
+		case batch.StatusSkipped:
+			r.Status = StatusSkipped
+		default:
+			r.Status = StatusFailed
+			delete(st.Compositions, r.Composition.Name)
+		}
+		progress(opts, r)
+	})
+
+	if err := p.writeState(st); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+func progress(opts BuildOptions, r *Result) {
+	if opts.Progress != nil {
+		opts.Progress(r)
+	}
+}
+
+// selection returns the named compositions, each once, or all of them
+func (p *Project) selection(names []string) ([]*Composition, error) {
+	var compositions []*Composition
+	if len(names) == 0 {
+		for i := range p.Compositions {
+			compositions = append(compositions, &p.Compositions[i])
+		}
+		return compositions, nil
+	}
+	for _, name := range names {
+		c, err := p.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(compositions, c) {
+			compositions = append(compositions, c)
+		}
+	}
+	return compositions, nil
+}
+
+// hash covers everything the outputs of a composition depend on
+func (p *Project) hash(c *Composition, fingerprint string) (string, error) {
+	h := sha256.New()
+	declaration, err := json.Marshal(struct {
+		Composition *Composition
+		Dist        string
+		MigrateArgs []string
+		Fingerprint string
+	}{c, p.Dist, p.MigrateArgs, fingerprint})
+	if err != nil {
+		return "", err
+	}
+	h.Write(declaration)
+
+	for _, path := range []string{c.Audio, c.Labels} {
+		f, err := os.Open(p.Path(path))
+		if err != nil {
+			return "", fmt.Errorf("failed to read input: %w", err)
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (p *Project) exist(outputs []string) bool {
+	for _, output := range outputs {
+		if _, err := os.Stat(p.Path(output)); err != nil {
+			return false
+		}
+	}
+	return len(outputs) > 0
+}
+
+func (p *Project) readState() *state {
+	st := &state{}
+	if data, err := os.ReadFile(filepath.Join(p.Dir, StateDir, stateFileName)); err == nil {
+		// A damaged state only costs a full rebuild
+		json.Unmarshal(data, st)
+	}
+	if st.Compositions == nil {
+		st.Compositions = map[string]stateEntry{}
+	}
+	return st
+}
+
+func (p *Project) writeState(st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode build state: %w", err)
+	}
+	dir := filepath.Join(p.Dir, StateDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, stateFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write build state: %w", err)
+	}
+	return nil
+}
+
+// OutputPath is where a composition is built for a model, relative to the
+// project directory
+func (p *Project) OutputPath(c *Composition, model *glyph.Model) string {
+	return filepath.Join(p.Dist, c.Name+"-"+model.Name+".ogg")
+}
+
+// buildComposition translates, writes and migrates a single composition
+// and returns its outputs
+func (p *Project) buildComposition(ctx context.Context, c *Composition, run ScriptFunc, log io.Writer) ([]string, error) {
+	labels := p.Path(c.Labels)
+	source, err := lintLabels(c, labels, log)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := c.targets(source)
+	if err != nil {
+		return nil, err
+	}
+
+	work, err := os.MkdirTemp("", "cngt-build-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(work)
+
+	// GlyphTranslator.py writes its output next to the labels
+	input := filepath.Join(work, c.Name+".txt")
+	if err := copyFile(labels, input); err != nil {
+		return nil, err
+	}
+	if err := runScript(ctx, run, log, TranslatorScript, input); err != nil {
+		return nil, err
+	}
+	frameFile := filepath.Join(work, c.Name+glyph.FrameFileExt)
+	if _, err := os.Stat(frameFile); err != nil {
+		return nil, fmt.Errorf("GlyphTranslator.py did not write %s", filepath.Base(frameFile))
+	}
+	frames, err := glyph.ParseFrameFile(frameFile)
+	if err != nil {
+		return nil, err
+	}
+	custom1, err := os.ReadFile(filepath.Join(work, c.Name+glyph.Custom1FileExt))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s file: %w", glyph.Custom1FileExt, err)
+	}
+
+	if err := os.MkdirAll(p.Path(p.Dist), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", p.Dist, err)
+	}
+	// The composition for the model of the labels is an output, or only
+	// the input of GlyphMigrate.py when it is not a target
+	var outputs []string
+	written := filepath.Join(work, c.Name+".ogg")
+	if slices.Contains(targets, source) {
+		output := p.OutputPath(c, source)
+		outputs = append(outputs, output)
+		written = p.Path(output)
+	}
+	title := c.Title
+	if title == "" {
+		title = c.Name
+	}
+	update := &glyph.Update{Title: title, Artist: c.Author, Frames: frames, Custom1: string(custom1), Model: source}
+	if err := glyph.WriteFile(p.Path(c.Audio), written, update); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(log, "Wrote %s for %s\n", written, source.DisplayName)
+
+	for _, m := range targets {
+		if m == source {
+			continue
+		}
+		output := p.OutputPath(c, m)
+		args := expandArgs(p.MigrateArgs, written, m, p.Path(output))
+		if err := runScript(ctx, run, log, MigrateScript, args...); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(p.Path(output)); err != nil {
+			return nil, fmt.Errorf("GlyphMigrate.py did not write %s, check migrate_args in %s", output, FileName)
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// lintLabels checks the labels and returns the model they are written for:
+// the one of the PHONE_MODEL label, or the first target
+func lintLabels(c *Composition, path string, log io.Writer) (*glyph.Model, error) {
+	r, err := lint.File(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.Model == nil && len(c.Models) > 0 {
+		m, _ := glyph.LookupModel(c.Models[0])
+		if r, err = lint.File(path, m); err != nil {
+			return nil, err
+		}
+	}
+	for _, issue := range r.Issues {
+		fmt.Fprintf(log, "%s:%s\n", path, issue)
+	}
+	if !r.OK() {
+		return nil, fmt.Errorf("%s has %d label issues", filepath.Base(path), len(r.Issues))
+	}
+	if r.Model == nil {
+		return nil, fmt.Errorf("%s names no phone model, add a PHONE_MODEL label or models", filepath.Base(path))
+	}
+	return r.Model, nil
+}
+
+// targets resolves the models to build for
+func (c *Composition) targets(source *glyph.Model) ([]*glyph.Model, error) {
+	if len(c.Models) == 0 {
+		return []*glyph.Model{source}, nil
+	}
+	var targets []*glyph.Model
+	for _, name := range c.Models {
+		m, err := glyph.LookupModel(name)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, m)
+	}
+	return targets, nil
+}
+
+// expandArgs fills in the {input}, {model} and {output} placeholders. The
+// model is written like in PHONE_MODEL labels, e.g. PHONE2A.
+func expandArgs(template []string, input string, model *glyph.Model, output string) []string {
+	r := strings.NewReplacer("{input}", input, "{model}", strings.ToUpper(model.Name), "{output}", output)
+	args := make([]string, len(template))
+	for i, arg := range template {
+		args[i] = r.Replace(arg)
+	}
+	return args
+}
+
+func runScript(ctx context.Context, run ScriptFunc, log io.Writer, script string, args ...string) error {
+	fmt.Fprintf(log, "$ %s %s\n", script, strings.Join(args, " "))
+	if err := run(ctx, script, args, log); err != nil {
+		return fmt.Errorf("%s failed: %w", script, err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return nil
+}
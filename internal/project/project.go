@@ -0,0 +1,151 @@
+// Package project reads cngt.yaml project files and builds the
+// compositions they declare
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the project file searched for from the working directory
+// upwards
+const FileName = "cngt.yaml"
+
+// DefaultDist is the output directory when the project does not set one
+const DefaultDist = "dist"
+
+// DefaultMigrateArgs are the GlyphMigrate.py arguments that convert
+// {input} for {model} into {output}
+var DefaultMigrateArgs = []string{"{input}", "{model}", "-o", "{output}"}
+
+// Project is a set of compositions built together
+type Project struct {
+	// Dir is the directory of the project file, relative paths start there
+	Dir string `yaml:"-"`
+	// Dist receives the built compositions
+	Dist string `yaml:"dist,omitempty"`
+	// MigrateArgs replaces DefaultMigrateArgs, see there
+	MigrateArgs  []string      `yaml:"migrate_args,omitempty"`
+	Compositions []Composition `yaml:"compositions"`
+}
+
+// Composition is a ringtone made from an audio file and Audacity labels
+type Composition struct {
+	// Name is the base name of the outputs, it defaults to the name of the
+	// audio file
+	Name   string `yaml:"name,omitempty"`
+	Audio  string `yaml:"audio"`
+	Labels string `yaml:"labels"`
+	Title  string `yaml:"title,omitempty"`
+	// Author is written to the ARTIST tag
+	Author string `yaml:"author,omitempty"`
+	// Models lists the phones to build for, it defaults to the phone the
+	// labels are written for
+	Models []string `yaml:"models,omitempty"`
+}
+
+// Find looks for cngt.yaml in dir and its parents
+func Find(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return Load(path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("no %s found in the current directory or its parents", FileName)
+		}
+		dir = parent
+	}
+}
+
+// Load reads and validates a project file
+func Load(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var p Project
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if p.Dir, err = filepath.Abs(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+func (p *Project) validate() error {
+	if p.Dist == "" {
+		p.Dist = DefaultDist
+	}
+	if p.MigrateArgs == nil {
+		p.MigrateArgs = DefaultMigrateArgs
+	}
+	if len(p.Compositions) == 0 {
+		return fmt.Errorf("no compositions declared")
+	}
+
+	names := map[string]bool{}
+	for i := range p.Compositions {
+		c := &p.Compositions[i]
+		if c.Audio == "" || c.Labels == "" {
+			return fmt.Errorf("composition %d: audio and labels are required", i+1)
+		}
+		if c.Name == "" {
+			c.Name = strings.TrimSuffix(filepath.Base(c.Audio), filepath.Ext(c.Audio))
+		}
+		if strings.ContainsAny(c.Name, `/\`) {
+			return fmt.Errorf("composition %s: names cannot contain slashes", c.Name)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("composition %s is declared twice, set distinct names", c.Name)
+		}
+		names[c.Name] = true
+		models := map[*glyph.Model]bool{}
+		for _, name := range c.Models {
+			m, err := glyph.LookupModel(name)
+			if err != nil {
+				return fmt.Errorf("composition %s: %w", c.Name, err)
+			}
+			if models[m] {
+				return fmt.Errorf("composition %s lists %s twice", c.Name, m.DisplayName)
+			}
+			models[m] = true
+		}
+	}
+	return nil
+}
+
+// Path resolves a path of the project file
+func (p *Project) Path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.Dir, path)
+}
+
+// Lookup finds a composition by name
+func (p *Project) Lookup(name string) (*Composition, error) {
+	for i := range p.Compositions {
+		if p.Compositions[i].Name == name {
+			return &p.Compositions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no composition named %s", name)
+}
@@ -0,0 +1,199 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snupai/cngt-cli/internal/glyph"
+)
+
+const testLabels = "0\t0\tLABEL_VERSION=1\n0\t0\tPHONE_MODEL=PHONE2\n0\t1\t1-100\n"
+
+const testProject = `compositions:
+  - audio: audio/sunrise.ogg
+    labels: labels/sunrise.txt
+    title: Sunrise
+    author: Jane
+    models: [phone2, phone1]
+  - name: beep
+    audio: audio/sunrise.ogg
+    labels: labels/sunrise.txt
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testDir(t *testing.T) string {
+	t.Helper()
+	audio, err := os.ReadFile("../glyph/testdata/phone2.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		FileName:             testProject,
+		"audio/sunrise.ogg":  string(audio),
+		"labels/sunrise.txt": testLabels,
+	})
+	return dir
+}
+
+// fakeScripts stands in for GlyphTranslator.py, which writes a .glypha file
+// next to the labels, and GlyphMigrate.py, which copies its input
+type fakeScripts struct {
+	calls []string
+	fail  string
+}
+
+func (f *fakeScripts) run(ctx context.Context, script string, args []string, log io.Writer) error {
+	f.calls = append(f.calls, script)
+	if script == f.fail {
+		return fmt.Errorf("exit status 1")
+	}
+	switch script {
+	case "GlyphTranslator.py":
+		frame := strings.Repeat("0,", 32) + "4095,\r\n"
+		out := strings.TrimSuffix(args[0], ".txt") + glyph.FrameFileExt
+		return os.WriteFile(out, []byte(strings.Repeat(frame, 60)), 0644)
+	case "GlyphMigrate.py":
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(args[3], data, 0644)
+	}
+	return fmt.Errorf("unexpected script %s", script)
+}
+
+func TestLoad(t *testing.T) {
+	dir := testDir(t)
+	writeFiles(t, dir, map[string]string{"songs/deep/.keep": ""})
+	p, err := Find(filepath.Join(dir, "songs", "deep"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Dist != DefaultDist || p.Compositions[0].Name != "sunrise" || len(p.MigrateArgs) != 4 {
+		t.Errorf("defaults not applied: %+v", p)
+	}
+
+	for content, want := range map[string]string{
+		"compositions: []":                  "no compositions",
+		"compositions:\n  - audio: a.ogg\n": "audio and labels are required",
+		"compositions:\n  - {audio: a.ogg, labels: a.txt}\n  - {audio: b/a.ogg, labels: b.txt}": "declared twice",
+		"compositions:\n  - {audio: a.ogg, labels: a.txt, models: [phone9]}":                    "unknown phone model",
+		"compositions:\n  - {audio: a.ogg, labels: a.txt, models: [phone2, Pong]}":              "lists Phone (2) twice",
+	} {
+		path := filepath.Join(t.TempDir(), FileName)
+		writeFiles(t, filepath.Dir(path), map[string]string{FileName: content})
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, want an error containing %q", err, want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := testDir(t)
+	p, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts := &fakeScripts{}
+	build := func(opts BuildOptions) []*Result {
+		t.Helper()
+		scripts.calls = nil
+		opts.Run = scripts.run
+		opts.Jobs = 2
+		results, err := p.Build(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	results := build(BuildOptions{})
+	for _, r := range results {
+		if r.Status != StatusBuilt {
+			t.Fatalf("%s: %s %v", r.Composition.Name, r.Status, r.Err)
+		}
+	}
+	want := []string{filepath.Join("dist", "sunrise-phone2.ogg"), filepath.Join("dist", "sunrise-phone1.ogg")}
+	if fmt.Sprint(results[0].Outputs) != fmt.Sprint(want) {
+		t.Errorf("outputs = %v, want %v", results[0].Outputs, want)
+	}
+	if len(scripts.calls) != 3 {
+		t.Errorf("ran %v, want two translator runs and one migrate run", scripts.calls)
+	}
+
+	c, err := glyph.ReadFile(filepath.Join(dir, "dist", "sunrise-phone2.ogg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artist, _ := c.Comments.Get(glyph.TagArtist); c.Title != "Sunrise" || artist != "Jane" || len(c.Frames) != 60 {
+		t.Errorf("unexpected composition: title %q, artist %q, %d frames", c.Title, artist, len(c.Frames))
+	}
+	if c, err := glyph.ReadFile(filepath.Join(dir, "dist", "beep-phone2.ogg")); err != nil || c.Title != "beep" {
+		t.Errorf("beep: %v", err)
+	}
+
+	// Nothing changed
+	results = build(BuildOptions{})
+	if results[0].Status != StatusUpToDate || results[1].Status != StatusUpToDate || len(scripts.calls) != 0 {
+		t.Errorf("second build ran %v", scripts.calls)
+	}
+
+	// A deleted output or a changed fingerprint rebuilds
+	os.Remove(filepath.Join(dir, "dist", "sunrise-phone1.ogg"))
+	if results = build(BuildOptions{}); results[0].Status != StatusBuilt || results[1].Status != StatusUpToDate {
+		t.Errorf("deleted output: %s, %s", results[0].Status, results[1].Status)
+	}
+	if results = build(BuildOptions{Fingerprint: "new commit", Only: []string{"beep", "beep"}}); len(results) != 1 || results[0].Status != StatusBuilt {
+		t.Errorf("changed fingerprint: %s", results[0].Status)
+	}
+
+	// Failures are logged and rebuilt next time
+	writeFiles(t, dir, map[string]string{"labels/sunrise.txt": testLabels + "1\t2\t1-50\n"})
+	scripts.fail = "GlyphMigrate.py"
+	results = build(BuildOptions{})
+	if results[0].Status != StatusFailed || !strings.Contains(results[0].Err.Error(), "GlyphMigrate.py failed") {
+		t.Errorf("failed migrate: %s %v", results[0].Status, results[0].Err)
+	}
+	if log, _ := os.ReadFile(results[0].Log); !strings.Contains(string(log), "$ GlyphMigrate.py") {
+		t.Errorf("unexpected log:\n%s", log)
+	}
+	scripts.fail = ""
+	if results = build(BuildOptions{}); results[0].Status != StatusBuilt {
+		t.Errorf("retry: %s %v", results[0].Status, results[0].Err)
+	}
+}
+
+func TestBuildStopsOnLabelIssues(t *testing.T) {
+	dir := testDir(t)
+	writeFiles(t, dir, map[string]string{"labels/sunrise.txt": testLabels + "1\t2\t40-100\n"})
+	p, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts := &fakeScripts{}
+	results, err := p.Build(context.Background(), BuildOptions{Run: scripts.run, Only: []string{"beep"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != StatusFailed || len(scripts.calls) != 0 {
+		t.Errorf("got %s after running %v, want a failure before any script", results[0].Status, scripts.calls)
+	}
+}
@@ -0,0 +1,56 @@
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// stdin is shared by all prompts so that input read ahead by one prompt,
+// e.g. several piped answers, is still there for the next one
+var stdin = sync.OnceValue(func() *bufio.Reader { return bufio.NewReader(os.Stdin) })
+
+// readAnswer prints prompt and reads one line. ok is false when there was
+// nothing to read, e.g. because stdin is closed.
+func readAnswer(prompt string) (string, bool) {
+	fmt.Print(prompt)
+	response, err := stdin().ReadString('\n')
+	if err != nil && response == "" {
+		fmt.Println()
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(response)), true
+}
+
+// Confirm asks a yes/no question. An empty answer returns def; without an
+// answer at all, e.g. when stdin is not a terminal, it returns false.
+func Confirm(prompt string, def bool) bool {
+	response, ok := readAnswer(prompt)
+	switch {
+	case !ok:
+		return false
+	case response == "":
+		return def
+	case def:
+		return response != "n" && response != "no"
+	default:
+		return response == "y" || response == "yes"
+	}
+}
+
+// Choose asks for one of options, given in full or by its first letter.
+// Empty, unknown or missing answers return def.
+func Choose(prompt, def string, options ...string) string {
+	response, ok := readAnswer(prompt)
+	if !ok || response == "" {
+		return def
+	}
+	for _, option := range options {
+		if response == option || response == option[:1] {
+			return option
+		}
+	}
+	return def
+}
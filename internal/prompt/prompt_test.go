@@ -0,0 +1,51 @@
+package prompt
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func withInput(t *testing.T, input string) {
+	t.Helper()
+	r := bufio.NewReader(strings.NewReader(input))
+	orig := stdin
+	stdin = func() *bufio.Reader { return r }
+	t.Cleanup(func() { stdin = orig })
+}
+
+func TestPromptsShareInput(t *testing.T) {
+	withInput(t, "y\n\nNo\nyes\nD\nmaybe\n")
+
+	for i, want := range []bool{true, true, false} {
+		if got := Confirm("? (Y/n): ", true); got != want {
+			t.Errorf("answer %d: got %v, want %v", i+1, got, want)
+		}
+	}
+	if !Confirm("? (y/N): ", false) {
+		t.Error("yes should confirm a prompt that defaults to no")
+	}
+	if got := Choose("? (s/d/A): ", "abort", "stash", "discard", "abort"); got != "discard" {
+		t.Errorf("got %q, want discard", got)
+	}
+	if got := Choose("? (s/d/A): ", "abort", "stash", "discard", "abort"); got != "abort" {
+		t.Errorf("got %q for an unknown answer, want abort", got)
+	}
+
+	// Without input nothing is confirmed, not even a default of yes
+	if Confirm("? (Y/n): ", true) {
+		t.Error("end of input should not confirm")
+	}
+	if got := Choose("? (s/d/A): ", "abort", "stash", "discard", "abort"); got != "abort" {
+		t.Errorf("got %q at end of input, want abort", got)
+	}
+}
+
+func TestConfirmDefaultsToNo(t *testing.T) {
+	withInput(t, "\nn\nwhatever\n")
+	for i := 0; i < 3; i++ {
+		if Confirm("? (y/N): ", false) {
+			t.Errorf("answer %d confirmed a prompt that defaults to no", i+1)
+		}
+	}
+}
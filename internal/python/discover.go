@@ -0,0 +1,239 @@
+package python
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// interpreterName matches the interpreter commands looked for on PATH,
+// e.g. python, python3 and python3.12, but not python3-config
+var interpreterName = regexp.MustCompile(`^python([23](\.[0-9]+)?)?(\.exe)?$`)
+
+// candidate is an interpreter path that has not been probed yet
+type candidate struct {
+	path   string
+	source string
+	// shim is set for version manager shims, which fail for versions that
+	// are installed but not selected
+	shim bool
+}
+
+// Discover probes every interpreter on PATH, in the pyenv and asdf
+// installation directories, those installed by the CLI in managedDir and,
+// on Windows, those registered with the py launcher. Interpreters reached
+// through several paths are listed once, under the path found first, and
+// shims that fail are left out.
+func Discover(managedDir string) []*Interpreter {
+	var candidates []candidate
+	for _, list := range [][]candidate{pathCandidates(), managerCandidates(), managedCandidates(managedDir), launcherCandidates()} {
+		candidates = append(candidates, list...)
+	}
+	candidates = dedupePaths(candidates)
+
+	found := make([]*Interpreter, len(candidates))
+	var wg sync.WaitGroup
+	for n, c := range candidates {
+		wg.Add(1)
+		go func(n int, c candidate) {
+			defer wg.Done()
+			found[n] = Probe(c.path, c.source)
+		}(n, c)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	var list []*Interpreter
+	for n, i := range found {
+		if i.Err != nil && candidates[n].shim {
+			continue
+		}
+		key := i.Path
+		if i.Err == nil && i.Executable != "" {
+			key = i.Executable
+		}
+		if !seen[key] {
+			seen[key] = true
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+// pathCandidates lists the interpreter commands in the PATH directories,
+// attributing pyenv and asdf shims to their version manager
+func pathCandidates() []candidate {
+	var list []candidate
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !interpreterName.MatchString(strings.ToLower(e.Name())) {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			source := sourceOf(path)
+			list = append(list, candidate{path: path, source: source, shim: source != SourcePath})
+		}
+	}
+	return list
+}
+
+// managerCandidates lists the interpreters installed by pyenv and asdf,
+// including those not selected by their shims
+func managerCandidates() []candidate {
+	var list []candidate
+	for _, m := range []struct {
+		root     string
+		versions string
+		source   string
+	}{
+		{pyenvRoot(), "versions", SourcePyenv},
+		{asdfRoot(), filepath.Join("installs", "python"), SourceAsdf},
+	} {
+		if m.root != "" {
+			list = append(list, versionCandidates(filepath.Join(m.root, m.versions), m.source)...)
+		}
+	}
+	return list
+}
+
+// managedCandidates lists the interpreters installed by 'cngt-cli python
+// install' in dir
+func managedCandidates(dir string) []candidate {
+	if dir == "" {
+		return nil
+	}
+	return versionCandidates(dir, SourceManaged)
+}
+
+// versionCandidates lists the interpreters in the subdirectories of dir,
+// one installation each. Installations still being extracted are skipped.
+func versionCandidates(dir, source string) []candidate {
+	dirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var list []candidate
+	for _, d := range dirs {
+		if !d.IsDir() || strings.HasSuffix(d.Name(), ".partial") {
+			continue
+		}
+		for _, name := range []string{filepath.Join("bin", "python3"), filepath.Join("bin", "python"), "python.exe"} {
+			if path := filepath.Join(dir, d.Name(), name); isExecutable(path) {
+				list = append(list, candidate{path: path, source: source})
+				break
+			}
+		}
+	}
+	return list
+}
+
+// launcherCandidates lists the interpreters registered with the Windows py
+// launcher
+func launcherCandidates() []candidate {
+	if runtime.GOOS != "windows" {
+		return nil
+	}
+	py, err := exec.LookPath("py")
+	if err != nil {
+		return nil
+	}
+	out, err := exec.Command(py, "--list-paths").Output()
+	if err != nil {
+		return nil
+	}
+	var list []candidate
+	for _, path := range parseLauncherList(string(out)) {
+		list = append(list, candidate{path: path, source: SourceLauncher})
+	}
+	return list
+}
+
+// parseLauncherList extracts the paths from the output of py --list-paths,
+// whose lines look like " -V:3.12 *        C:\Python312\python.exe" or, for
+// older launchers, " -3.9-64        C:\Python39\python.exe"
+func parseLauncherList(out string) []string {
+	var paths []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "-") {
+			continue
+		}
+		// The path starts after the version tag and the default marker
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, "*"))
+		if rest != "" {
+			paths = append(paths, rest)
+		}
+	}
+	return paths
+}
+
+// sourceOf attributes a PATH entry to a version manager when it lives in
+// that manager's directory
+func sourceOf(path string) string {
+	for _, m := range []struct{ root, source string }{{pyenvRoot(), SourcePyenv}, {asdfRoot(), SourceAsdf}} {
+		if m.root != "" && strings.HasPrefix(path, m.root+string(filepath.Separator)) {
+			return m.source
+		}
+	}
+	return SourcePath
+}
+
+func pyenvRoot() string {
+	if root := os.Getenv("PYENV_ROOT"); root != "" {
+		return filepath.Clean(root)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".pyenv")
+	}
+	return ""
+}
+
+func asdfRoot() string {
+	if root := os.Getenv("ASDF_DATA_DIR"); root != "" {
+		return filepath.Clean(root)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".asdf")
+	}
+	return ""
+}
+
+// dedupePaths drops repeated paths, e.g. from PATH entries listed twice
+func dedupePaths(list []candidate) []candidate {
+	seen := map[string]bool{}
+	var out []candidate
+	for _, c := range list {
+		if !seen[c.path] {
+			seen[c.path] = true
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
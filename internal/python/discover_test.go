@@ -0,0 +1,106 @@
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// fakeInterpreter writes a script that answers the probe like a Python of
+// the given version whose executable is target
+func fakeInterpreter(t *testing.T, path, target, version string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	bits := 64
+	if runtime.GOARCH == "386" {
+		bits = 32
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+echo '{"executable": "%s", "version": [%s], "level": "final", "implementation": "cpython", "machine": "%s", "bits": %d, "venv": false}'
+`, target, version, nativeMachine(), bits)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake interpreters are shell scripts")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	pyenv := filepath.Join(dir, "pyenv")
+	t.Setenv("PYENV_ROOT", pyenv)
+	t.Setenv("ASDF_DATA_DIR", filepath.Join(dir, "asdf"))
+	t.Setenv("PATH", filepath.Join(pyenv, "shims")+string(os.PathListSeparator)+bin)
+
+	system := filepath.Join(bin, "python3")
+	fakeInterpreter(t, system, system, "3, 11, 2")
+	// python3.11 is the same interpreter under another name
+	fakeInterpreter(t, filepath.Join(bin, "python3.11"), system, "3, 11, 2")
+	fakeInterpreter(t, filepath.Join(bin, "python2"), filepath.Join(bin, "python2"), "2, 7, 18")
+	if err := os.WriteFile(filepath.Join(bin, "python3-config"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	managed := filepath.Join(pyenv, "versions", "3.12.1", "bin", "python3")
+	fakeInterpreter(t, managed, managed, "3, 12, 1")
+	fakeInterpreter(t, filepath.Join(pyenv, "shims", "python3.12"), managed, "3, 12, 1")
+	// A shim of a version that is not selected fails
+	if err := os.WriteFile(filepath.Join(pyenv, "shims", "python3.9"), []byte("#!/bin/sh\nexit 127\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	asdf := filepath.Join(dir, "asdf", "installs", "python", "3.10.4", "bin", "python3")
+	fakeInterpreter(t, asdf, asdf, "3, 10, 4")
+
+	var got []string
+	for _, i := range Discover("") {
+		if i.Err != nil {
+			t.Errorf("unexpected probe error: %v", i.Err)
+			continue
+		}
+		got = append(got, fmt.Sprintf("%s %s %s", i.Source, i.Version.Short(), filepath.Base(i.Path)))
+	}
+	want := []string{
+		"pyenv 3.12.1 python3.12",
+		"PATH 2.7.18 python2",
+		"PATH 3.11.2 python3",
+		"asdf 3.10.4 python3",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	best := Best(Discover(""), Version{Major: 3, Minor: 8})
+	if best == nil || best.Command() != managed {
+		t.Errorf("expected %s to be chosen, got %+v", managed, best)
+	}
+
+	if _, err := Use(filepath.Join(bin, "python2"), Version{Major: 3, Minor: 8}); err == nil {
+		t.Error("Use should reject Python 2")
+	}
+	if i, err := Use("python3", Version{Major: 3, Minor: 8}); err != nil || i.Path != system {
+		t.Errorf("Use(python3) = %+v, %v", i, err)
+	}
+}
+
+func TestParseLauncherList(t *testing.T) {
+	out := ` -V:3.12 *        C:\Users\me\AppData\Local\Programs\Python\Python312\python.exe
+ -V:3.11          C:\Program Files\Python311\python.exe
+ -3.9-64          C:\Python39\python.exe
+
+`
+	want := []string{
+		`C:\Users\me\AppData\Local\Programs\Python\Python312\python.exe`,
+		`C:\Program Files\Python311\python.exe`,
+		`C:\Python39\python.exe`,
+	}
+	if got := parseLauncherList(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
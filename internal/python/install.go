@@ -0,0 +1,494 @@
+package python
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/snupai/cngt-cli/internal/cngt/progress"
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+// DefaultInstallVersion is the Python installed when no version is given
+const DefaultInstallVersion = "3.12"
+
+const (
+	standaloneReleases = "https://github.com/astral-sh/python-build-standalone/releases/download"
+	standaloneLatest   = "https://api.github.com/repos/astral-sh/python-build-standalone/releases/latest"
+	// checksumsName is the file of a release with the SHA-256 of every
+	// archive in it
+	checksumsName = "SHA256SUMS"
+)
+
+// archiveName matches the python-build-standalone archives that contain
+// only the installation, e.g.
+// cpython-3.12.7+20241016-x86_64-unknown-linux-gnu-install_only.tar.gz
+var archiveName = regexp.MustCompile(`^cpython-([0-9]+\.[0-9]+\.[0-9]+)\+([0-9]+)-(.+?)(?:-shared)?-install_only\.tar\.gz$`)
+
+// ManagedDir returns the directory of the interpreters installed by the CLI
+func ManagedDir(cfg *config.Config) string {
+	return filepath.Join(cfg.DataDir, "python")
+}
+
+// InstallVersion returns the version installed when none is given:
+// DefaultInstallVersion, or python_min_version if that is newer
+func InstallVersion(cfg *config.Config) string {
+	def, _ := ParseVersion(DefaultInstallVersion)
+	if min := MinVersion(cfg); def.Less(min) {
+		return min.Short()
+	}
+	return DefaultInstallVersion
+}
+
+// Install provides a standalone CPython build of version, e.g. 3.12 or
+// 3.12.7, in ManagedDir and returns it. uv installs it when it is available
+// and python_mirror is not set. Otherwise the archive is downloaded from the
+// latest python-build-standalone release or python_mirror and checked
+// against the SHA256SUMS of the release. An installed build of the version
+// is reused.
+func Install(cfg *config.Config, version string, p *progress.Reporter) (*Interpreter, error) {
+	want, err := ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	parts := len(strings.Split(version, "."))
+	dir := ManagedDir(cfg)
+	if i := installed(dir, want, parts); i != nil {
+		return i, nil
+	}
+
+	p.Start("python", "Installing Python "+version)
+	if cfg.PythonMirror == "" && hasUv() {
+		err = installWithUv(dir, version, p)
+	} else {
+		err = installStandalone(cfg.PythonMirror, dir, want, parts, p)
+	}
+	var i *Interpreter
+	if err == nil {
+		if i = installed(dir, want, parts); i == nil {
+			err = fmt.Errorf("no Python %s in %s after installing it", version, dir)
+		}
+	}
+	p.Done("python", err)
+	return i, err
+}
+
+// installed returns the newest interpreter in dir matching the first parts
+// numbers of want, nil if there is none
+func installed(dir string, want Version, parts int) *Interpreter {
+	var match []*Interpreter
+	for _, c := range managedCandidates(dir) {
+		if i := Probe(c.path, c.source); i.Err == nil && versionMatches(i.Version, want, parts) {
+			match = append(match, i)
+		}
+	}
+	return Best(match, Version{})
+}
+
+// versionMatches reports whether the first parts numbers of v and want are
+// equal, so that 3.12 matches 3.12.7
+func versionMatches(v, want Version, parts int) bool {
+	a := []int{v.Major, v.Minor, v.Micro}
+	b := []int{want.Major, want.Minor, want.Micro}
+	for n := 0; n < parts && n < 3; n++ {
+		if a[n] != b[n] {
+			return false
+		}
+	}
+	return true
+}
+
+func hasUv() bool {
+	return exec.Command("uv", "--version").Run() == nil
+}
+
+// installWithUv runs uv python install with dir as its installation
+// directory. uv verifies the downloads against its own checksums.
+func installWithUv(dir, version string, p *progress.Reporter) error {
+	cmd := exec.Command("uv", "python", "install", version)
+	cmd.Env = append(os.Environ(), "UV_PYTHON_INSTALL_DIR="+dir)
+	cmd.Stdout = p.Writer("python")
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to install Python %s with uv: %w", version, err)
+	}
+	return nil
+}
+
+// installStandalone downloads, verifies and extracts the newest build of
+// the release at mirror that matches want
+func installStandalone(mirror, dir string, want Version, parts int, p *progress.Reporter) error {
+	triple, err := standaloneTriple()
+	if err != nil {
+		return err
+	}
+	r, err := openRelease(mirror)
+	if err != nil {
+		return err
+	}
+	sums, err := r.checksums()
+	if err != nil {
+		return err
+	}
+	name, version, err := pickArchive(sums, triple, want, parts)
+	if err != nil {
+		return err
+	}
+
+	p.Log("python", "Downloading "+name)
+	archive, err := r.download(name, sums[name], p)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive)
+
+	p.Log("python", "Extracting Python "+version.String())
+	return extractArchive(archive, filepath.Join(dir, fmt.Sprintf("cpython-%s-%s", version, triple)))
+}
+
+// standaloneTriple returns the target triple of the builds for this
+// machine, e.g. x86_64-unknown-linux-gnu
+func standaloneTriple() (string, error) {
+	arch := map[string]string{"amd64": "x86_64", "arm64": "aarch64", "386": "i686"}[runtime.GOARCH]
+	switch {
+	case arch == "":
+	case runtime.GOOS == "linux":
+		libc := "gnu"
+		if musl, _ := filepath.Glob("/lib/ld-musl-*.so.1"); len(musl) > 0 {
+			libc = "musl"
+		}
+		return arch + "-unknown-linux-" + libc, nil
+	case runtime.GOOS == "darwin":
+		return arch + "-apple-darwin", nil
+	case runtime.GOOS == "windows":
+		return arch + "-pc-windows-msvc", nil
+	}
+	return "", fmt.Errorf("no standalone Python builds for %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// release is a python-build-standalone release on GitHub, on a web server
+// or in a local directory
+type release struct {
+	// base is the URL or directory the files of the release are in
+	base  string
+	local bool
+}
+
+// openRelease returns the release at mirror, or the latest one on GitHub
+// when mirror is empty
+func openRelease(mirror string) (*release, error) {
+	if mirror == "" {
+		tag, err := latestStandaloneTag()
+		if err != nil {
+			return nil, err
+		}
+		return &release{base: standaloneReleases + "/" + tag}, nil
+	}
+	if strings.HasPrefix(mirror, "http://") || strings.HasPrefix(mirror, "https://") {
+		return &release{base: strings.TrimSuffix(mirror, "/")}, nil
+	}
+	if info, err := os.Stat(mirror); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("python_mirror %s is neither a URL nor a directory", mirror)
+	}
+	return &release{base: mirror, local: true}, nil
+}
+
+func latestStandaloneTag() (string, error) {
+	resp, err := http.Get(standaloneLatest)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the latest Python builds (network error): %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to look up the latest Python builds: GitHub API returned status %d", resp.StatusCode)
+	}
+	var latest struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&latest); err != nil || latest.TagName == "" {
+		return "", fmt.Errorf("failed to decode the latest Python builds release")
+	}
+	return latest.TagName, nil
+}
+
+// open returns the contents of a file of the release and its size, -1 if
+// unknown
+func (r *release) open(name string) (io.ReadCloser, int64, error) {
+	if r.local {
+		f, err := os.Open(filepath.Join(r.base, name))
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+	url := r.base + "/" + name
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// checksums reads the SHA256SUMS of the release, which also serves as the
+// list of its archives
+func (r *release) checksums() (map[string]string, error) {
+	rc, _, err := r.open(checksumsName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the checksums of the Python builds: %w", err)
+	}
+	defer rc.Close()
+	return parseChecksums(rc)
+}
+
+// parseChecksums reads lines of a hex SHA-256 and a file name, as written
+// by sha256sum
+func parseChecksums(r io.Reader) (map[string]string, error) {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sum := strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", checksumsName, err)
+	}
+	return sums, nil
+}
+
+// pickArchive returns the newest archive for triple whose version matches
+// the first parts numbers of want
+func pickArchive(sums map[string]string, triple string, want Version, parts int) (string, Version, error) {
+	var name string
+	var best Version
+	seen := map[string]bool{}
+	var available []Version
+	for n := range sums {
+		m := archiveName.FindStringSubmatch(n)
+		if m == nil || m[3] != triple {
+			continue
+		}
+		v, err := ParseVersion(m[1])
+		if err != nil {
+			continue
+		}
+		if !seen[v.String()] {
+			seen[v.String()] = true
+			available = append(available, v)
+		}
+		if versionMatches(v, want, parts) && (name == "" || best.Less(v)) {
+			name, best = n, v
+		}
+	}
+	if name != "" {
+		return name, best, nil
+	}
+	if len(available) == 0 {
+		return "", Version{}, fmt.Errorf("no standalone Python builds for %s in the release", triple)
+	}
+	sort.Slice(available, func(a, b int) bool { return available[a].Less(available[b]) })
+	list := make([]string, len(available))
+	for n, v := range available {
+		list[n] = v.String()
+	}
+	return "", Version{}, fmt.Errorf("no standalone build of Python %s for %s (available: %s)", want.Short(), triple, strings.Join(list, ", "))
+}
+
+// download copies an archive of the release to a temporary file and checks
+// it against sum. The caller removes the file.
+func (r *release) download(name, sum string, p *progress.Reporter) (string, error) {
+	rc, size, err := r.open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp("", "cngt-python-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	counter := &downloadCounter{p: p, total: size}
+	_, err = io.Copy(io.MultiWriter(tmp, h, counter), rc)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, sum, got)
+	}
+	return tmp.Name(), nil
+}
+
+// downloadCounter reports the bytes written to it as download progress
+type downloadCounter struct {
+	p       *progress.Reporter
+	current int64
+	total   int64
+}
+
+func (c *downloadCounter) Write(data []byte) (int, error) {
+	c.current += int64(len(data))
+	if c.total > 0 {
+		c.p.Update("python", "download", c.current, c.total)
+	}
+	return len(data), nil
+}
+
+// extractArchive unpacks an install_only archive, whose entries are in a
+// python directory, into target. It extracts to a temporary directory next
+// to target first, so that an interrupted extraction leaves nothing behind.
+func extractArchive(archive, target string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read Python archive: %w", err)
+	}
+	defer gz.Close()
+
+	tmp := target + ".partial"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := extractTar(tar.NewReader(gz), tmp); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("failed to extract Python archive: %w", err)
+	}
+	if err := os.RemoveAll(target); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("failed to move Python to %s: %w", target, err)
+	}
+	return nil
+}
+
+func extractTar(tr *tar.Reader, dest string) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel, ok := archivePath(hdr.Name)
+		if !ok {
+			return fmt.Errorf("unexpected entry %s outside of python/", hdr.Name)
+		}
+		if rel == "" {
+			continue
+		}
+		target, err := safeJoin(dest, rel)
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("entry %s links to absolute path %s", hdr.Name, hdr.Linkname)
+			}
+			if _, err := safeJoin(dest, path.Join(path.Dir(rel), hdr.Linkname)); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			linkRel, ok := archivePath(hdr.Linkname)
+			if !ok || linkRel == "" {
+				return fmt.Errorf("entry %s links to %s outside of python/", hdr.Name, hdr.Linkname)
+			}
+			source, err := safeJoin(dest, linkRel)
+			if err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0777|0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// archivePath strips the python directory from an archive entry name
+func archivePath(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	if name == "python" {
+		return "", true
+	}
+	rel := strings.TrimPrefix(name, "python/")
+	return rel, rel != name
+}
+
+func safeJoin(dest, name string) (string, error) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dest, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %s escapes the target directory", name)
+	}
+	return target, nil
+}
@@ -0,0 +1,187 @@
+package python
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/snupai/cngt-cli/internal/cngt/progress"
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+// standaloneArchive returns an install_only archive with an interpreter
+// script that answers the probe like the given version, reporting its own
+// path as the executable
+func standaloneArchive(t *testing.T, version string) []byte {
+	t.Helper()
+	bits := 64
+	if runtime.GOARCH == "386" {
+		bits = 32
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+echo "{\"executable\": \"$0\", \"version\": [%s], \"level\": \"final\", \"implementation\": \"cpython\", \"machine\": \"%s\", \"bits\": %d, \"venv\": false}"
+`, strings.ReplaceAll(version, ".", ", "), nativeMachine(), bits)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, hdr := range []*tar.Header{
+		{Name: "python/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "python/bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "python/bin/python3.12", Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(script))},
+		{Name: "python/bin/python3", Typeflag: tar.TypeSymlink, Linkname: "python3.12"},
+		{Name: "python/bin/python", Typeflag: tar.TypeLink, Linkname: "python/bin/python3.12"},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(script)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// standaloneMirror writes archives to a mirror directory with a SHA256SUMS
+// listing them and, without a file, builds that are not in the mirror
+func standaloneMirror(t *testing.T, archives map[string][]byte, listed ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	var sums strings.Builder
+	for name, data := range archives {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	for _, name := range listed {
+		fmt.Fprintf(&sums, "%s  %s\n", strings.Repeat("0", 64), name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, checksumsName), []byte(sums.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestInstallFromMirror(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake interpreters are shell scripts")
+	}
+	triple, err := standaloneTriple()
+	if err != nil {
+		t.Skip(err)
+	}
+	name := "cpython-3.12.7+20241016-" + triple + "-install_only.tar.gz"
+	mirror := standaloneMirror(t, map[string][]byte{name: standaloneArchive(t, "3.12.7")},
+		"cpython-3.11.10+20241016-"+triple+"-install_only.tar.gz",
+		"cpython-3.13.0+20241016-other-triple-install_only.tar.gz",
+		"cpython-3.12.7+20241016-"+triple+"-debug-full.tar.zst",
+	)
+	cfg := &config.Config{DataDir: t.TempDir(), PythonMirror: mirror}
+	p := progress.NewReporter(progress.NewPlainRenderer(io.Discard))
+
+	i, err := Install(cfg, "3.12", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Version.String() != "3.12.7" || i.Source != SourceManaged {
+		t.Errorf("got Python %s from %s, want 3.12.7 from %s", i.Version, i.Source, SourceManaged)
+	}
+	want := filepath.Join(ManagedDir(cfg), "cpython-3.12.7-"+triple, "bin", "python3.12")
+	if i.Command() != want {
+		t.Errorf("got %s, want %s", i.Command(), want)
+	}
+
+	// Installed builds are reused without the mirror
+	if err := os.Remove(filepath.Join(mirror, name)); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := Install(cfg, "3.12.7", p); err != nil || again.Command() != want {
+		t.Errorf("reinstall returned %v, %v", again, err)
+	}
+
+	found := false
+	for _, d := range Discover(ManagedDir(cfg)) {
+		found = found || d.Command() == want && d.Source == SourceManaged
+	}
+	if !found {
+		t.Errorf("Discover does not list %s", want)
+	}
+
+	_, err = Install(cfg, "3.9", p)
+	if err == nil || !strings.Contains(err.Error(), "available: 3.11.10, 3.12.7") {
+		t.Errorf("got %v for an unavailable version", err)
+	}
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	triple, err := standaloneTriple()
+	if err != nil {
+		t.Skip(err)
+	}
+	name := "cpython-3.12.7+20241016-" + triple + "-install_only.tar.gz"
+	mirror := standaloneMirror(t, map[string][]byte{name: standaloneArchive(t, "3.12.7")})
+	if err := os.WriteFile(filepath.Join(mirror, name), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{DataDir: t.TempDir(), PythonMirror: mirror}
+
+	_, err = Install(cfg, "3.12", progress.NewReporter(progress.NewPlainRenderer(io.Discard)))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("got %v, want a checksum mismatch", err)
+	}
+	if entries, _ := os.ReadDir(ManagedDir(cfg)); len(entries) != 0 {
+		t.Errorf("left %d entries in %s", len(entries), ManagedDir(cfg))
+	}
+}
+
+func TestExtractTarRejectsEscapes(t *testing.T) {
+	for _, hdr := range []*tar.Header{
+		{Name: "python/../evil", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "other/file", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "python/bin/link", Typeflag: tar.TypeSymlink, Linkname: "../../../evil"},
+		{Name: "python/bin/abs", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		{Name: "python/bin/hard", Typeflag: tar.TypeLink, Linkname: "etc/passwd"},
+	} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Close()
+
+		dest := filepath.Join(t.TempDir(), "python")
+		if err := extractTar(tar.NewReader(&buf), dest); err == nil {
+			t.Errorf("%s (%s) was extracted", hdr.Name, hdr.Linkname)
+		}
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	sums, err := parseChecksums(strings.NewReader(sum + "  a.tar.gz\n" + strings.ToUpper(sum) + " *b.tar.gz\nnot a checksum line\nxyz  c.tar.gz\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 2 || sums["a.tar.gz"] != sum || sums["b.tar.gz"] != sum {
+		t.Errorf("got %v", sums)
+	}
+}
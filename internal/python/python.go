@@ -0,0 +1,295 @@
+// Package python finds Python interpreters and picks the one the managed
+// environment is created from.
+package python
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+// Where an interpreter was found
+const (
+	SourceConfig   = "config"
+	SourcePath     = "PATH"
+	SourcePyenv    = "pyenv"
+	SourceAsdf     = "asdf"
+	SourceLauncher = "py launcher"
+	SourceManaged  = "managed"
+)
+
+// probeTimeout bounds a single interpreter probe, e.g. for Windows app
+// execution aliases that open the Store instead of running Python
+const probeTimeout = 10 * time.Second
+
+// probeScript prints what the CLI needs to know about an interpreter. It
+// runs on Python 2 as well, so that old interpreters can be reported.
+const probeScript = `import sys, platform, struct, json
+v = sys.version_info
+print(json.dumps({
+    "executable": sys.executable,
+    "version": [v[0], v[1], v[2]],
+    "level": v[3],
+    "implementation": platform.python_implementation().lower(),
+    "machine": platform.machine().lower(),
+    "bits": struct.calcsize("P") * 8,
+    "venv": sys.prefix != getattr(sys, "base_prefix", sys.prefix),
+}))
+`
+
+// Version is a Python version such as 3.11.7
+type Version struct {
+	Major, Minor, Micro int
+	// Level is final for releases, alpha, beta or candidate otherwise
+	Level string
+}
+
+// ParseVersion parses a version with up to three numbers, e.g. 3 or 3.10
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid Python version %q", s)
+	}
+	var n [3]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 {
+			return Version{}, fmt.Errorf("invalid Python version %q", s)
+		}
+		n[i] = v
+	}
+	return Version{Major: n[0], Minor: n[1], Micro: n[2], Level: "final"}, nil
+}
+
+// String returns the version as Python prints it, e.g. 3.13.0b1
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Micro)
+	switch v.Level {
+	case "alpha":
+		s += "a"
+	case "beta":
+		s += "b"
+	case "candidate":
+		s += "rc"
+	}
+	return s
+}
+
+// Short returns the version without a zero micro number, e.g. 3.10
+func (v Version) Short() string {
+	if v.Micro == 0 {
+		return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Micro)
+}
+
+// Less reports whether v is an older release than o, ignoring the level
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Micro < o.Micro
+}
+
+// Interpreter is a Python installation found on the system
+type Interpreter struct {
+	// Path is the command as it was found, e.g. a pyenv shim
+	Path string
+	// Executable is the interpreter that actually runs, with symlinks
+	// resolved
+	Executable     string
+	Version        Version
+	Implementation string
+	// Machine and Bits describe the architecture the interpreter was
+	// built for, e.g. x86_64 and 64
+	Machine string
+	Bits    int
+	// Venv is set for interpreters of virtual environments
+	Venv   bool
+	Source string
+	// Err is set when the interpreter could not be run
+	Err error
+}
+
+// Probe runs the interpreter at path and reads its version and
+// architecture
+func Probe(path, source string) *Interpreter {
+	i := &Interpreter{Path: path, Source: source}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, "-c", probeScript).Output()
+	if err != nil {
+		i.Err = fmt.Errorf("failed to run %s: %w", path, err)
+		return i
+	}
+	var info struct {
+		Executable     string `json:"executable"`
+		Version        []int  `json:"version"`
+		Level          string `json:"level"`
+		Implementation string `json:"implementation"`
+		Machine        string `json:"machine"`
+		Bits           int    `json:"bits"`
+		Venv           bool   `json:"venv"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil || len(info.Version) != 3 {
+		i.Err = fmt.Errorf("unexpected output from %s", path)
+		return i
+	}
+
+	i.Executable = info.Executable
+	if resolved, err := filepath.EvalSymlinks(info.Executable); err == nil {
+		i.Executable = resolved
+	}
+	i.Version = Version{Major: info.Version[0], Minor: info.Version[1], Micro: info.Version[2], Level: info.Level}
+	i.Implementation = info.Implementation
+	i.Machine = info.Machine
+	i.Bits = info.Bits
+	i.Venv = info.Venv
+	return i
+}
+
+// Command returns the path to run the interpreter with. Shims are
+// bypassed, so that the choice does not depend on the working directory.
+func (i *Interpreter) Command() string {
+	if i.Executable != "" {
+		return i.Executable
+	}
+	return i.Path
+}
+
+// Native reports whether the interpreter is built for the architecture of
+// the CLI, e.g. not an x86_64 build running under Rosetta
+func (i *Interpreter) Native() bool {
+	machines := map[string][]string{
+		"amd64": {"x86_64", "amd64"},
+		"arm64": {"arm64", "aarch64"},
+		"386":   {"i386", "i686", "x86"},
+	}
+	bits := 64
+	if runtime.GOARCH == "386" || runtime.GOARCH == "arm" {
+		bits = 32
+	}
+	names, ok := machines[runtime.GOARCH]
+	if !ok {
+		return i.Bits == bits
+	}
+	for _, m := range names {
+		if i.Machine == m {
+			return i.Bits == bits
+		}
+	}
+	return false
+}
+
+// Problem explains why the interpreter is not suitable as the base of the
+// managed environment, empty when it is
+func (i *Interpreter) Problem(min Version) string {
+	switch {
+	case i.Err != nil:
+		return "does not run"
+	case i.Version.Less(min):
+		return "older than " + min.Short()
+	case i.Venv:
+		return "virtual environment"
+	}
+	return ""
+}
+
+// Best returns the most suitable interpreter: usable ones first, then
+// native builds, CPython, final releases and newer versions. Ties keep the
+// discovery order. It returns nil when no interpreter is usable.
+func Best(list []*Interpreter, min Version) *Interpreter {
+	var usable []*Interpreter
+	for _, i := range list {
+		if i.Problem(min) == "" {
+			usable = append(usable, i)
+		}
+	}
+	if len(usable) == 0 {
+		return nil
+	}
+	sort.SliceStable(usable, func(a, b int) bool {
+		x, y := usable[a], usable[b]
+		if x.Native() != y.Native() {
+			return x.Native()
+		}
+		if (x.Implementation == "cpython") != (y.Implementation == "cpython") {
+			return x.Implementation == "cpython"
+		}
+		if (x.Version.Level == "final") != (y.Version.Level == "final") {
+			return x.Version.Level == "final"
+		}
+		return y.Version.Less(x.Version)
+	})
+	return usable[0]
+}
+
+// MinVersion returns the configured minimum version
+func MinVersion(cfg *config.Config) Version {
+	min, err := ParseVersion(cfg.PythonMinVersion)
+	if err != nil {
+		min, _ = ParseVersion(config.DefaultPythonMinVersion)
+	}
+	return min
+}
+
+// Find returns the interpreter the managed environment is created from:
+// the configured one if set, the best discovered one otherwise
+func Find(cfg *config.Config) (*Interpreter, error) {
+	min := MinVersion(cfg)
+	if cfg.Python != "" {
+		i, err := Use(cfg.Python, min)
+		if err != nil {
+			return nil, fmt.Errorf("configured Python %s: %w", cfg.Python, err)
+		}
+		return i, nil
+	}
+
+	list := Discover(ManagedDir(cfg))
+	if best := Best(list, min); best != nil {
+		return best, nil
+	}
+	var found []string
+	for _, i := range list {
+		if i.Err == nil {
+			found = append(found, fmt.Sprintf("%s %s at %s", i.Implementation, i.Version, i.Path))
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("Python is not installed. Please install Python %s or newer first, or run 'cngt-cli python install'", min.Short())
+	}
+	return nil, fmt.Errorf("no Python %s or newer found (found %s)", min.Short(), strings.Join(found, ", "))
+}
+
+// Use probes an interpreter given by the user as a path or command name
+// and checks that it is suitable
+func Use(command string, min Version) (*Interpreter, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("%s not found", command)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	i := Probe(path, SourceConfig)
+	if i.Err != nil {
+		return nil, i.Err
+	}
+	if problem := i.Problem(min); problem != "" {
+		return nil, fmt.Errorf("Python %s at %s is not suitable: %s", i.Version, path, problem)
+	}
+	return i, nil
+}
@@ -0,0 +1,91 @@
+package python
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/snupai/cngt-cli/internal/config"
+)
+
+// nativeMachine returns the platform.machine() value of a native build
+func nativeMachine() string {
+	switch runtime.GOARCH {
+	case "arm64":
+		return "arm64"
+	case "386":
+		return "i686"
+	}
+	return "x86_64"
+}
+
+func TestParseVersion(t *testing.T) {
+	for input, want := range map[string]Version{
+		"3":       {Major: 3, Level: "final"},
+		"3.10":    {Major: 3, Minor: 10, Level: "final"},
+		"3.11.7 ": {Major: 3, Minor: 11, Micro: 7, Level: "final"},
+	} {
+		got, err := ParseVersion(input)
+		if err != nil || got != want {
+			t.Errorf("ParseVersion(%q) = %+v, %v, want %+v", input, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "3.x", "3.1.2.4", "-1"} {
+		if _, err := ParseVersion(bad); err == nil {
+			t.Errorf("ParseVersion(%q) should fail", bad)
+		}
+	}
+
+	if s := (Version{3, 13, 0, "beta"}).String(); s != "3.13.0b" {
+		t.Errorf("unexpected String() %q", s)
+	}
+	if s := (Version{3, 10, 0, "final"}).Short(); s != "3.10" {
+		t.Errorf("unexpected Short() %q", s)
+	}
+}
+
+func TestBest(t *testing.T) {
+	bits := 64
+	if runtime.GOARCH == "386" {
+		bits = 32
+	}
+	interpreter := func(path string, minor int, apply func(i *Interpreter)) *Interpreter {
+		i := &Interpreter{Path: path, Version: Version{3, minor, 0, "final"}, Implementation: "cpython", Machine: nativeMachine(), Bits: bits}
+		if apply != nil {
+			apply(i)
+		}
+		return i
+	}
+	min := Version{Major: 3, Minor: 8}
+
+	list := []*Interpreter{
+		interpreter("old", 7, nil),
+		interpreter("venv", 13, func(i *Interpreter) { i.Venv = true }),
+		interpreter("foreign", 13, func(i *Interpreter) { i.Machine = "ppc64le" }),
+		interpreter("pypy", 13, func(i *Interpreter) { i.Implementation = "pypy" }),
+		interpreter("alpha", 14, func(i *Interpreter) { i.Version.Level = "alpha" }),
+		interpreter("first", 11, nil),
+		interpreter("newest", 12, nil),
+		interpreter("second", 12, nil),
+	}
+	if best := Best(list, min); best == nil || best.Path != "newest" {
+		t.Errorf("expected newest, got %+v", best)
+	}
+	if best := Best(list[:5], min); best == nil || best.Path != "alpha" {
+		t.Errorf("expected the native pre-release, got %+v", best)
+	}
+	if best := Best(list[:2], min); best != nil {
+		t.Errorf("expected no usable interpreter, got %+v", best)
+	}
+	if p := list[0].Problem(min); p != "older than 3.8" {
+		t.Errorf("unexpected problem %q", p)
+	}
+}
+
+func TestMinVersion(t *testing.T) {
+	if v := MinVersion(&config.Config{PythonMinVersion: "3.10"}); v.Minor != 10 {
+		t.Errorf("unexpected minimum %+v", v)
+	}
+	if v := MinVersion(&config.Config{}); v.Short() != config.DefaultPythonMinVersion {
+		t.Errorf("expected the default minimum, got %+v", v)
+	}
+}
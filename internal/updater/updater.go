@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"strings"
 	
+	"github.com/snupai/cngt-cli/internal/prompt"
 	"github.com/snupai/cngt-cli/internal/version"
 )
 
@@ -170,12 +171,7 @@ func SelfUpdate() error {
 	}
 
 	fmt.Printf("🆕 New version available: %s\n", release.TagName)
-	fmt.Print("Would you like to update? (y/N): ")
-	
-	var response string
-	fmt.Scanln(&response)
-	
-	if strings.ToLower(response) == "y" || strings.ToLower(response) == "yes" {
+	if prompt.Confirm("Would you like to update? (y/N): ", false) {
 		fmt.Println("⬇️  Downloading and installing update...")
 		return Update()
 	}